@@ -0,0 +1,365 @@
+package gojsonlex
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+// SizeByKey reads JSON from r and reports the number of bytes occupied by the members of
+// all objects found at prefix, aggregated by member key. Size of a member is measured from
+// the beginning of its key to the end of its value in the input, so it includes the
+// original whitespaces but not the separating commas. Use an empty prefix to get the
+// sizes of top-level keys and wildcards to aggregate across array elements (e.g.
+// "cells.*").
+func SizeByKey(r io.Reader, prefix Path) (map[string]int64, error) {
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return sizeByKey(l, prefix)
+}
+
+func sizeByKey(l *JSONLexer, prefix Path) (map[string]int64, error) {
+	l.SetSkipDelims(false)
+
+	sizes := make(map[string]int64)
+	tr := PathTracker{}
+
+	var currKey []byte
+	memberDepth := -1 // depth of the object containing the member being measured
+	var memberStart int64
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		role, err := tr.Feed(&token)
+		if err != nil {
+			return nil, err
+		}
+
+		start, end := l.currTokenOffsets()
+
+		switch {
+		case role == TokenRoleKey && tr.containerMatches(prefix):
+			currKey = append(currKey[:0], token.str...)
+			memberDepth = tr.depth
+			memberStart = start
+		case role == TokenRoleScalar && tr.valueDepth == memberDepth,
+			role == TokenRoleClose && tr.depth == memberDepth:
+			sizes[string(currKey)] += end - memberStart
+			memberDepth = -1
+		}
+	}
+
+	return sizes, nil
+}
+
+// Aggregation is a function computed over values of a group of records, see GroupBy
+type Aggregation byte
+
+const (
+	// AggregateCount counts records having a value at the aggregated path (all records
+	// if the path is empty)
+	AggregateCount Aggregation = iota
+	// AggregateSum sums numbers at the aggregated path
+	AggregateSum
+	// AggregateMin finds the minimum of numbers at the aggregated path
+	AggregateMin
+	// AggregateMax finds the maximum of numbers at the aggregated path
+	AggregateMax
+)
+
+// Grouping groups records by their value at some path, see GroupBy
+type Grouping struct {
+	by Path
+}
+
+// GroupBy groups top-level values (e.g. NDJSON records) by the scalar at path by inside
+// them. Records without a scalar at by are skipped.
+func GroupBy(by Path) Grouping {
+	return Grouping{by: by}
+}
+
+// groupKey returns the textual representation of the grouping value
+func groupKey(t *TokenGeneric) string {
+	switch t.t {
+	case LexerTokenTypeString:
+		return t.str
+	case LexerTokenTypeNumber:
+		if t.lossy {
+			return t.str
+		}
+
+		return strconv.FormatFloat(t.number, 'g', -1, 64)
+	case LexerTokenTypeBool:
+		return strconv.FormatBool(t.boolean)
+	}
+
+	return "null"
+}
+
+// Aggregate reads records from r and computes agg over values at path of (relative to
+// records) within every group. The result maps the textual representation of grouping
+// values (strings as is, other scalars formatted as in JSON) to the aggregated value.
+// Groups without any values to aggregate are omitted.
+func (g Grouping) Aggregate(r io.Reader, agg Aggregation, of Path) (map[string]float64, error) {
+	s, err := NewRecordScanner(r, Path{}, g.by, of)
+	if err != nil {
+		return nil, err
+	}
+
+	// values are updated via pointers since assigning to a map replaces the stored key
+	// as well, and keys point into the scanner's memory
+	groups := make(map[string]*float64)
+
+	for {
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !s.found[0] {
+			continue
+		}
+
+		value := &s.values[1]
+
+		if agg == AggregateCount && (s.found[1] || of.Len() == 0) {
+			value = &TokenGeneric{t: LexerTokenTypeNumber, number: 1}
+		} else if !s.found[1] || value.t != LexerTokenTypeNumber {
+			continue
+		}
+
+		curr, ok := groups[groupKey(&s.values[0])]
+		if !ok {
+			curr = new(float64)
+			*curr = value.number
+			groups[StringDeepCopy(groupKey(&s.values[0]))] = curr
+
+			continue
+		}
+
+		switch agg {
+		case AggregateCount, AggregateSum:
+			*curr += value.number
+		case AggregateMin:
+			*curr = math.Min(*curr, value.number)
+		case AggregateMax:
+			*curr = math.Max(*curr, value.number)
+		}
+	}
+
+	result := make(map[string]float64, len(groups))
+	for key, value := range groups {
+		result[key] = *value
+	}
+
+	return result, nil
+}
+
+// SizeHistogram counts sizes in power of two buckets: Buckets[0] counts zero sizes and
+// Buckets[i] counts sizes in [2^(i-1), 2^i)
+type SizeHistogram struct {
+	Count   int64
+	Sum     int64
+	Max     int
+	Buckets []int64
+}
+
+func (h *SizeHistogram) add(size int) {
+	bucket := bits.Len(uint(size))
+	for len(h.Buckets) <= bucket {
+		h.Buckets = append(h.Buckets, 0)
+	}
+
+	h.Buckets[bucket]++
+	h.Count++
+	h.Sum += int64(size)
+
+	if size > h.Max {
+		h.Max = size
+	}
+}
+
+// Quantile returns the upper bound of the bucket containing the q-th quantile of sizes
+// (0 <= q <= 1), e.g. Quantile(0.99) is a buffer size fitting at least 99% of tokens
+func (h *SizeHistogram) Quantile(q float64) int {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(q * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+
+	for i, n := range h.Buckets {
+		if seen += n; seen >= rank {
+			if i == 0 {
+				return 0
+			}
+
+			return 1<<uint(i) - 1
+		}
+	}
+
+	return h.Max
+}
+
+// TokenStats are statistics of tokens collected by CollectTokenStats
+type TokenStats struct {
+	// Counts are numbers of tokens by type, object keys are not counted as strings
+	Counts map[TokenType]int64
+	// Sizes are histograms of lengths of unescaped strings (LexerTokenTypeString) in
+	// bytes and of numbers of digits in numbers (LexerTokenTypeNumber)
+	Sizes map[TokenType]*SizeHistogram
+	// Keys is the histogram of lengths of unescaped object keys in bytes, Keys.Count is
+	// the number of keys
+	Keys SizeHistogram
+}
+
+func (s *TokenStats) addSize(t TokenType, size int) {
+	h, ok := s.Sizes[t]
+	if !ok {
+		h = &SizeHistogram{}
+		s.Sizes[t] = h
+	}
+
+	h.add(size)
+}
+
+func countDigits(number []byte) int {
+	digits := 0
+
+	for _, c := range number {
+		if '0' <= c && c <= '9' {
+			digits++
+		}
+	}
+
+	return digits
+}
+
+// CollectTokenStats reads JSON from r and collects statistics of its tokens, e.g. to choose
+// buffer sizes from the data
+func CollectTokenStats(r io.Reader) (*TokenStats, error) {
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l.SetSkipDelims(false)
+
+	stats := &TokenStats{
+		Counts: make(map[TokenType]int64),
+		Sizes:  make(map[TokenType]*SizeHistogram),
+	}
+
+	tr := PathTracker{}
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		role, err := tr.Feed(&token)
+		if err != nil {
+			return nil, err
+		}
+
+		t := token.t
+
+		switch {
+		case role == TokenRoleKey:
+			stats.Keys.add(len(token.str))
+			continue
+		case t == LexerTokenTypeString:
+			stats.addSize(t, len(token.str))
+		case t == LexerTokenTypeNumber:
+			// the literal is still in the buffer until the next Token() call
+			stats.addSize(t, countDigits(l.buf[l.currTokenStart:l.currTokenEnd]))
+		}
+
+		stats.Counts[t]++
+	}
+
+	return stats, nil
+}
+
+// EstimateCompactSize reads JSON from r and computes the exact size in bytes of its
+// minified form: the input with insignificant whitespaces removed and top-level values
+// separated with newlines. Tokens are measured as they appear in the input, numbers and
+// escape sequences are not reformatted. It works in one pass without keeping the output
+// and is useful for capacity planning and for pre-sizing destination buffers.
+func EstimateCompactSize(r io.Reader) (int64, error) {
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		return 0, err
+	}
+
+	l.SetSkipDelims(false)
+	l.SetRawNumbers(true)
+
+	// the writer only validates the structure
+	w := NewTokenWriter(ioutil.Discard)
+
+	var size, values int64
+	depth := 0
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if err := w.WriteToken(token); err != nil {
+			return 0, err
+		}
+
+		start, end := l.currTokenOffsets()
+		size += end - start
+
+		switch token.delim {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ':', ',':
+			continue
+		}
+
+		if depth == 0 {
+			values++
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+
+	if values > 1 {
+		size += values - 1
+	}
+
+	return size, nil
+}
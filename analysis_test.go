@@ -0,0 +1,199 @@
+package gojsonlex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sizeByKeyTestCase struct {
+	input  string
+	prefix string
+	output map[string]int64
+}
+
+func TestSizeByKey(t *testing.T) {
+	testcases := []sizeByKeyTestCase{
+		{
+			`{"a": 1, "bb": "hello", "c": [1, 2, 3], "d": {"e": null}}`,
+			"",
+			map[string]int64{"a": 6, "bb": 13, "c": 14, "d": 16},
+		},
+		{
+			`{"a": 1} {"a": 22, "b": true}`,
+			"",
+			map[string]int64{"a": 13, "b": 9},
+		},
+		{
+			`{"a": {"x": 1, "y": [{}]}, "b": {"x": 10}}`,
+			"a",
+			map[string]int64{"x": 6, "y": 9},
+		},
+		{
+			`{"cells": [{"name": "ip", "value": "5.61.233.11"}, {"name": "id", "value": 253}]}`,
+			"cells.*",
+			map[string]int64{"name": 24, "value": 34},
+		},
+		{
+			`["a", "b"]`,
+			"",
+			map[string]int64{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		for _, bufSize := range []int{4, defaultBufSize} {
+			l, err := NewJSONLexer(strings.NewReader(testcase.input))
+			if err != nil {
+				t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+				continue
+			}
+
+			l.SetBufSize(bufSize)
+
+			sizes, err := sizeByKey(l, ParsePath(testcase.prefix))
+			if err != nil {
+				t.Errorf("testcase '%s': %v", testcase.input, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(sizes, testcase.output) {
+				t.Errorf("testcase '%s': got %v, expected %v", testcase.input, sizes, testcase.output)
+			}
+		}
+	}
+}
+
+type groupByTestCase struct {
+	by     string
+	agg    Aggregation
+	of     string
+	output map[string]float64
+}
+
+func TestGroupBy(t *testing.T) {
+	input := `{"ip": "10.0.0.1", "bytes": 100, "user": {"id": 1}}
+{"ip": "10.0.0.2", "bytes": 50, "user": {"id": 2}}
+{"ip": "10.0.0.1", "bytes": 20}
+{"ip": "10.0.0.1", "bytes": "n/a", "user": {"id": 1}}
+{"bytes": 1000}
+{"ip": "10.0.0.3"}
+`
+
+	testcases := []groupByTestCase{
+		{"ip", AggregateCount, "", map[string]float64{"10.0.0.1": 3, "10.0.0.2": 1, "10.0.0.3": 1}},
+		{"ip", AggregateCount, "bytes", map[string]float64{"10.0.0.1": 3, "10.0.0.2": 1}},
+		{"ip", AggregateSum, "bytes", map[string]float64{"10.0.0.1": 120, "10.0.0.2": 50}},
+		{"ip", AggregateMin, "bytes", map[string]float64{"10.0.0.1": 20, "10.0.0.2": 50}},
+		{"ip", AggregateMax, "bytes", map[string]float64{"10.0.0.1": 100, "10.0.0.2": 50}},
+		{"user.id", AggregateSum, "bytes", map[string]float64{"1": 100, "2": 50}},
+		{"missing", AggregateCount, "", map[string]float64{}},
+	}
+
+	for _, testcase := range testcases {
+		groups, err := GroupBy(ParsePath(testcase.by)).Aggregate(
+			strings.NewReader(input), testcase.agg, ParsePath(testcase.of))
+		if err != nil {
+			t.Errorf("testcase '%s' of '%s': %v", testcase.by, testcase.of, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(groups, testcase.output) {
+			t.Errorf("testcase '%s' of '%s': got %v, expected %v",
+				testcase.by, testcase.of, groups, testcase.output)
+		}
+	}
+}
+
+func TestGroupByKeyTypes(t *testing.T) {
+	input := `{"k": true} {"k": null} {"k": 1.5} {"k": "1.5"} {"k": [1]} {"k": true}`
+
+	groups, err := GroupBy(ParsePath("k")).Aggregate(strings.NewReader(input), AggregateCount, Path{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := map[string]float64{"true": 2, "null": 1, "1.5": 2}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("got %v, expected %v", groups, expected)
+	}
+}
+
+func TestCollectTokenStats(t *testing.T) {
+	input := `{"id": 12345, "name": "Bob", "tags": ["", "abcdefgh", "A"], "pi": -3.14e10, "ok": true}`
+
+	stats, err := CollectTokenStats(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expectedCounts := map[TokenType]int64{
+		LexerTokenTypeDelim:  15,
+		LexerTokenTypeString: 4,
+		LexerTokenTypeNumber: 2,
+		LexerTokenTypeBool:   1,
+	}
+
+	if !reflect.DeepEqual(stats.Counts, expectedCounts) {
+		t.Errorf("got counts %v, expected %v", stats.Counts, expectedCounts)
+	}
+
+	expectedSizes := map[TokenType]*SizeHistogram{
+		// 3, 0, 8, 1
+		LexerTokenTypeString: {Count: 4, Sum: 12, Max: 8, Buckets: []int64{1, 1, 1, 0, 1}},
+		// 5, 5
+		LexerTokenTypeNumber: {Count: 2, Sum: 10, Max: 5, Buckets: []int64{0, 0, 0, 2}},
+	}
+
+	if !reflect.DeepEqual(stats.Sizes, expectedSizes) {
+		for tokenType, h := range stats.Sizes {
+			t.Errorf("got %s sizes %+v, expected %+v", tokenType, *h, expectedSizes[tokenType])
+		}
+	}
+
+	// 2, 4, 4, 2, 2
+	expectedKeys := SizeHistogram{Count: 5, Sum: 14, Max: 4, Buckets: []int64{0, 0, 3, 2}}
+
+	if !reflect.DeepEqual(stats.Keys, expectedKeys) {
+		t.Errorf("got key sizes %+v, expected %+v", stats.Keys, expectedKeys)
+	}
+
+	h := stats.Sizes[LexerTokenTypeString]
+	for q, expected := range map[float64]int{0: 0, 0.25: 0, 0.5: 1, 0.75: 3, 1: 15} {
+		if got := h.Quantile(q); got != expected {
+			t.Errorf("got quantile(%v) = %d, expected %d", q, got, expected)
+		}
+	}
+}
+
+func TestEstimateCompactSize(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected string
+	}{
+		{``, ``},
+		{`{ "a" : [ 1 , 2.50 , 1e3 ] ,
+		    "b" : { } }`, `{"a":[1,2.50,1e3],"b":{}}`},
+		{`"é\n"`, `"é\n"`},
+		{`["\u00e9", 12345678901234567890]`, `["\u00e9",12345678901234567890]`},
+		{`1 2  [ ]`, "1\n2\n[]"},
+	}
+
+	for _, testcase := range testcases {
+		size, err := EstimateCompactSize(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if size != int64(len(testcase.expected)) {
+			t.Errorf("testcase '%s': got %d, expected %d", testcase.input, size, len(testcase.expected))
+		}
+	}
+
+	for _, input := range []string{`[1}`, `{"a"`} {
+		if _, err := EstimateCompactSize(strings.NewReader(input)); err == nil {
+			t.Errorf("testcase '%s': must have failed", input)
+		}
+	}
+}
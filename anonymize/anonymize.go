@@ -0,0 +1,140 @@
+// Package anonymize replaces values in JSON streams with keyed pseudonyms. It lives in
+// a separate package, so that the core lexer does not depend on the crypto packages.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+	"github.com/gibsn/gojsonlex/transform"
+)
+
+// pseudonymLen is the number of bytes of HMAC in pseudonyms that do not preserve format
+const pseudonymLen = 16
+
+// Flags tune the behaviour of Pseudonymizer
+type Flags byte
+
+const (
+	// PreserveFormat makes pseudonyms keep the length and the charset class of
+	// every character of the value: digits are replaced with digits, lowercase and
+	// uppercase ASCII letters with letters of the same case, other characters are kept.
+	// Short values get short pseudonyms, which are easier to brute force and collide more
+	// often.
+	PreserveFormat Flags = 1 << iota
+)
+
+type pseudonymizer struct {
+	mac   hash.Hash
+	flags Flags
+
+	sum    []byte
+	stream []byte
+	buf    []byte
+}
+
+// keystream returns n pseudorandom bytes derived from the HMAC of the value, which is
+// already in p.sum
+func (p *pseudonymizer) keystream(n int) []byte {
+	stream := append(p.stream[:0], p.sum...)
+
+	var counter [4]byte
+
+	for i := uint32(1); len(stream) < n; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+
+		p.mac.Reset()
+		p.mac.Write(p.sum)
+		p.mac.Write(counter[:])
+		stream = p.mac.Sum(stream)
+	}
+
+	p.stream = stream
+
+	return stream[:n]
+}
+
+func (p *pseudonymizer) pseudonym(value []byte) string {
+	p.mac.Reset()
+	p.mac.Write(value)
+	p.sum = p.mac.Sum(p.sum[:0])
+
+	if p.flags&PreserveFormat == 0 {
+		if n := hex.EncodedLen(pseudonymLen); cap(p.buf) < n {
+			p.buf = make([]byte, n)
+		}
+
+		p.buf = p.buf[:hex.EncodedLen(pseudonymLen)]
+		hex.Encode(p.buf, p.sum[:pseudonymLen])
+
+		return string(p.buf)
+	}
+
+	p.buf = append(p.buf[:0], value...)
+	stream := p.keystream(len(value))
+
+	for i, c := range p.buf {
+		switch {
+		case '0' <= c && c <= '9':
+			p.buf[i] = '0' + stream[i]%10
+		case 'a' <= c && c <= 'z':
+			p.buf[i] = 'a' + stream[i]%26
+		case 'A' <= c && c <= 'Z':
+			p.buf[i] = 'A' + stream[i]%26
+		}
+	}
+
+	return string(p.buf)
+}
+
+// Pseudonymizer returns a ValueTransformer replacing strings and numbers with pseudonyms
+// derived from HMAC-SHA256 of the value with the given key: equal values get equal
+// pseudonyms, so pseudonymized datasets stay joinable on them, while the original values
+// can not be recovered without the key. Pseudonyms are strings of 32 hex digits unless
+// PreserveFormat is set. Numbers are pseudonymized by their canonical textual
+// form (e.g. 42 and "42" get the same pseudonym) and become strings. Bools and nulls are
+// copied intact. The transformer must not be shared between goroutines.
+func Pseudonymizer(key []byte, flags Flags) transform.ValueTransformer {
+	p := &pseudonymizer{
+		mac:   hmac.New(sha256.New, key),
+		flags: flags,
+	}
+
+	var numBuf []byte
+
+	return func(ctx gojsonlex.TokenContext, tok gojsonlex.TokenGeneric) (gojsonlex.TokenGeneric, bool) {
+		switch tok.Type() {
+		case gojsonlex.LexerTokenTypeString:
+			return gojsonlex.NewTokenGenericFromString(p.pseudonym(tok.BytesUnsafe())), true
+		case gojsonlex.LexerTokenTypeNumber, gojsonlex.LexerTokenTypeInt:
+			var err error
+			if numBuf, err = jsonutil.AppendNumber(numBuf[:0], tok.Number()); err != nil {
+				return tok, false
+			}
+
+			return gojsonlex.NewTokenGenericFromString(p.pseudonym(numBuf)), true
+		}
+
+		return tok, false
+	}
+}
+
+// Anonymize copies JSON from src to dst replacing scalar values found at the given paths
+// with pseudonyms, see Pseudonymizer. It allows to share datasets (e.g. for debugging)
+// without disclosing personal data. The output is compact JSON.
+func Anonymize(dst io.Writer, src io.Reader, key []byte, paths []gojsonlex.Path, flags Flags) error {
+	p := Pseudonymizer(key, flags)
+
+	transformers := make([]transform.PathTransformer, len(paths))
+	for i, path := range paths {
+		transformers[i] = transform.PathTransformer{Path: path, Transformer: p}
+	}
+
+	return transform.TransformValues(dst, src, transformers)
+}
@@ -0,0 +1,96 @@
+package anonymize
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+func TestAnonymize(t *testing.T) {
+	input := `{"users": [{"email": "Ivan.Petrov@mail.ru", "phone": "+7 (916) 123-45-67", "id": 42},` +
+		` {"email": "Ivan.Petrov@mail.ru", "phone": "8-800", "id": "42", "admin": true}]}`
+
+	paths := []gojsonlex.Path{gojsonlex.ParsePath("users.*.email"), gojsonlex.ParsePath("users.*.phone"), gojsonlex.ParsePath("users.*.id"),
+		gojsonlex.ParsePath("users.*.admin")}
+
+	type user struct {
+		Email string
+		Phone string
+		ID    string
+		Admin bool
+	}
+
+	anonymize := func(key string, flags Flags) []user {
+		out := &bytes.Buffer{}
+		if err := Anonymize(out, strings.NewReader(input), []byte(key), paths, flags); err != nil {
+			t.Fatalf("could not anonymize: %v", err)
+		}
+
+		var doc struct{ Users []user }
+		if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid output '%s': %v", out.String(), err)
+		}
+
+		return doc.Users
+	}
+
+	users := anonymize("secret", 0)
+
+	if users[0].Email != users[1].Email || users[0].ID != users[1].ID {
+		t.Errorf("equal values must get equal pseudonyms: %+v", users)
+	}
+	if len(users[0].Email) != 32 || strings.Contains(users[0].Email, "Ivan") {
+		t.Errorf("got pseudonym '%s'", users[0].Email)
+	}
+	if users[0].Phone == users[1].Phone {
+		t.Errorf("different values must get different pseudonyms: %+v", users)
+	}
+	if !users[1].Admin {
+		t.Errorf("bools must be copied intact")
+	}
+
+	if other := anonymize("other", 0); other[0].Email == users[0].Email {
+		t.Errorf("pseudonyms must depend on the key")
+	}
+
+	users = anonymize("secret", PreserveFormat)
+
+	if users[0].Email != users[1].Email {
+		t.Errorf("equal values must get equal pseudonyms: %+v", users)
+	}
+
+	for _, testcase := range []struct{ original, pseudonym string }{
+		{"Ivan.Petrov@mail.ru", users[0].Email},
+		{"+7 (916) 123-45-67", users[0].Phone},
+		{"42", users[0].ID},
+	} {
+		if testcase.pseudonym == testcase.original || len(testcase.pseudonym) != len(testcase.original) {
+			t.Errorf("testcase '%s': got pseudonym '%s'", testcase.original, testcase.pseudonym)
+			continue
+		}
+
+		for i := 0; i < len(testcase.original); i++ {
+			if charClassOf(testcase.original[i]) != charClassOf(testcase.pseudonym[i]) {
+				t.Errorf("testcase '%s': got pseudonym '%s' of another format",
+					testcase.original, testcase.pseudonym)
+				break
+			}
+		}
+	}
+}
+
+func charClassOf(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return '0'
+	case 'a' <= c && c <= 'z':
+		return 'a'
+	case 'A' <= c && c <= 'Z':
+		return 'A'
+	}
+
+	return c
+}
@@ -0,0 +1,259 @@
+// Package bench provides corpora of representative JSON and helpers measuring the
+// performance of gojsonlex on them, so that settings (buffer size, modes, dialects) can
+// be evaluated on data resembling the real one. Running
+//
+//	go test -bench=. -benchmem github.com/gibsn/gojsonlex/bench
+//
+// measures the default settings and some alternatives on all built-in corpora.
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+// Corpus is a named sample of JSON input
+type Corpus struct {
+	Name string
+	Data []byte
+}
+
+// Generator generates a corpus of about size bytes, the output is deterministic
+type Generator func(size int) Corpus
+
+// all values are generated from a fixed seed, so that results are comparable
+const seed = 1
+
+var words = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+	"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore",
+}
+
+func randomWords(rnd *rand.Rand, n int) string {
+	var b bytes.Buffer
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(words[rnd.Intn(len(words))])
+	}
+
+	return b.String()
+}
+
+// generate writes elements of a top-level array with write until the corpus reaches size
+func generate(name string, size int, write func(b *bytes.Buffer, rnd *rand.Rand)) Corpus {
+	rnd := rand.New(rand.NewSource(seed))
+
+	b := bytes.Buffer{}
+	b.Grow(size + size/8)
+	b.WriteByte('[')
+
+	for i := 0; b.Len() < size || i == 0; i++ {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+
+		write(&b, rnd)
+	}
+
+	b.WriteString("]\n")
+
+	return Corpus{Name: name, Data: b.Bytes()}
+}
+
+// SSTableDump generates rows similar to the output of Cassandra's sstabledump: pretty
+// printed objects with many short keys and a mix of all kinds of scalars
+func SSTableDump(size int) Corpus {
+	return generate("sstabledump", size, func(b *bytes.Buffer, rnd *rand.Rand) {
+		fmt.Fprintf(b, `{
+  "type" : "row",
+  "position" : %d,
+  "clustering" : [ "%08x-8f99-11ea-8e8d-fa163e4302ba" ],
+  "liveness_info" : { "tstamp" : "2020-05-06T12:57:%02d.193447Z" },
+  "cells" : [
+    { "name" : "event_id", "value" : %d },
+    { "name" : "ip", "value" : "10.%d.%d.%d" },
+    { "name" : "is_valid", "value" : %t },
+    { "name" : "session_id", "value" : null },
+    { "name" : "delta", "value" : %.3f },
+    { "name" : "args", "path" : [ "h" ], "value" : "%s" }
+  ]
+}`, rnd.Intn(1e6), rnd.Uint32(), rnd.Intn(60), rnd.Int63(), rnd.Intn(256), rnd.Intn(256),
+			rnd.Intn(256), rnd.Intn(2) == 0, rnd.Float64()*100, words[rnd.Intn(len(words))])
+	})
+}
+
+// Tweets generates compact objects similar to tweets: nested objects, long texts with
+// non-ASCII characters, 64-bit ids and arrays of objects
+func Tweets(size int) Corpus {
+	return generate("tweets", size, func(b *bytes.Buffer, rnd *rand.Rand) {
+		id := rnd.Int63()
+
+		fmt.Fprintf(b, `{"created_at":"Wed Oct 10 20:19:%02d +0000 2018","id":%d,"id_str":"%d",`+
+			`"text":"%s — привет 👋 #%s","truncated":false,"user":{"id":%d,"name":"%s",`+
+			`"screen_name":"%s","followers_count":%d,"verified":%t},"entities":{"hashtags":`+
+			`[{"text":"%s","indices":[%d,%d]}],"urls":[]},"retweet_count":%d,"lang":"en"}`,
+			rnd.Intn(60), id, id, randomWords(rnd, 12+rnd.Intn(12)), words[rnd.Intn(len(words))],
+			rnd.Int63(), randomWords(rnd, 2), words[rnd.Intn(len(words))], rnd.Intn(1e6),
+			rnd.Intn(10) == 0, words[rnd.Intn(len(words))], rnd.Intn(100), rnd.Intn(100)+100,
+			rnd.Intn(1000))
+	})
+}
+
+// Numbers generates arrays of numbers of all forms: integers, decimals, exponents
+func Numbers(size int) Corpus {
+	return generate("numbers", size, func(b *bytes.Buffer, rnd *rand.Rand) {
+		b.WriteByte('[')
+
+		for i := 0; i < 16; i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+
+			switch i % 4 {
+			case 0:
+				b.WriteString(strconv.Itoa(rnd.Intn(1e9) - 5e8))
+			case 1:
+				b.WriteString(strconv.FormatFloat(rnd.Float64()*1e4, 'f', 6, 64))
+			case 2:
+				b.WriteString(strconv.FormatFloat(rnd.NormFloat64()*1e20, 'e', -1, 64))
+			case 3:
+				b.WriteString(strconv.FormatUint(rnd.Uint64(), 10))
+			}
+		}
+
+		b.WriteByte(']')
+	})
+}
+
+var escapes = []string{`\n`, `\t`, `\"`, `\\`, `\/`, `\u00e9`, `\u041f\u0440`, `\ud83d\udca9`, `\u0000`}
+
+// Escapes generates objects with strings full of escape sequences, in particular
+// '\u' escapes and surrogate pairs
+func Escapes(size int) Corpus {
+	return generate("escapes", size, func(b *bytes.Buffer, rnd *rand.Rand) {
+		b.WriteString(`{"key `)
+		b.WriteString(strconv.Itoa(rnd.Intn(100)))
+		b.WriteString(`":"`)
+
+		for i := 0; i < 32; i++ {
+			if rnd.Intn(2) == 0 {
+				b.WriteString(escapes[rnd.Intn(len(escapes))])
+			} else {
+				b.WriteString(words[rnd.Intn(len(words))])
+			}
+		}
+
+		b.WriteString(`"}`)
+	})
+}
+
+// Generators are all built-in corpus generators
+var Generators = []Generator{SSTableDump, Tweets, Numbers, Escapes}
+
+// Corpora generates all built-in corpora of about size bytes each
+func Corpora(size int) []Corpus {
+	corpora := make([]Corpus, 0, len(Generators))
+	for _, generator := range Generators {
+		corpora = append(corpora, generator(size))
+	}
+
+	return corpora
+}
+
+// LoadCorpus loads a corpus from a file, the corpus is named after the file
+func LoadCorpus(path string) (Corpus, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Corpus{}, err
+	}
+
+	return Corpus{Name: filepath.Base(path), Data: data}, nil
+}
+
+// Lex lexes the corpus with a lexer configured by configure (if not nil) and returns the
+// number of tokens
+func Lex(c Corpus, configure func(*gojsonlex.JSONLexer)) (int, error) {
+	l, err := gojsonlex.NewJSONLexer(bytes.NewReader(c.Data))
+	if err != nil {
+		return 0, err
+	}
+
+	if configure != nil {
+		configure(l)
+	}
+
+	tokens := 0
+
+	for {
+		_, err := l.TokenFast()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return tokens, fmt.Errorf("corpus '%s': %w", c.Name, err)
+		}
+
+		tokens++
+	}
+}
+
+// Result is the outcome of Measure
+type Result struct {
+	Corpus string
+	Tokens int // number of tokens in the corpus
+	testing.BenchmarkResult
+}
+
+// TokensPerSec returns the lexing throughput in tokens
+func (r Result) TokensPerSec() float64 {
+	if r.T <= 0 {
+		return 0
+	}
+
+	return float64(r.Tokens) * float64(r.N) / r.T.Seconds()
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%-16s %s\t%.0f tokens/s\t%s", r.Corpus, r.BenchmarkResult.String(),
+		r.TokensPerSec(), r.MemString())
+}
+
+// Measure benchmarks lexing of the corpus with a lexer configured by configure (if not nil)
+// the same way "go test -bench" does it
+func Measure(c Corpus, configure func(*gojsonlex.JSONLexer)) (Result, error) {
+	tokens, err := Lex(c, configure)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := testing.Benchmark(func(b *testing.B) {
+		Benchmark(b, c, configure)
+	})
+
+	return Result{Corpus: c.Name, Tokens: tokens, BenchmarkResult: result}, nil
+}
+
+// Benchmark lexes the corpus b.N times with a lexer configured by configure (if not nil),
+// it is meant to be called from benchmark functions
+func Benchmark(b *testing.B, c Corpus, configure func(*gojsonlex.JSONLexer)) {
+	b.SetBytes(int64(len(c.Data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Lex(c, configure); err != nil {
+			b.Fatalf("%v", err)
+		}
+	}
+}
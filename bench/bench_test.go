@@ -0,0 +1,76 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+const corpusSize = 256 << 10
+
+func TestCorpora(t *testing.T) {
+	for _, c := range Corpora(4 << 10) {
+		if !json.Valid(c.Data) {
+			t.Errorf("corpus '%s' is not valid JSON", c.Name)
+		}
+
+		if len(c.Data) < 4<<10 {
+			t.Errorf("corpus '%s' is %d bytes long, expected at least %d", c.Name, len(c.Data), 4<<10)
+		}
+
+		again := Corpora(4 << 10)
+		for _, other := range again {
+			if other.Name == c.Name && !bytes.Equal(other.Data, c.Data) {
+				t.Errorf("corpus '%s' is not deterministic", c.Name)
+			}
+		}
+
+		tokens, err := Lex(c, func(l *gojsonlex.JSONLexer) { l.SetValidateStructure(true) })
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+
+		if tokens == 0 {
+			t.Errorf("corpus '%s' has no tokens", c.Name)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measuring takes about a second")
+	}
+
+	result, err := Measure(Numbers(4<<10), nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if result.N == 0 || result.Tokens == 0 || result.TokensPerSec() <= 0 {
+		t.Errorf("got incomplete result %v", result)
+	}
+}
+
+var settings = []struct {
+	name      string
+	configure func(*gojsonlex.JSONLexer)
+}{
+	{"default", nil},
+	{"buf=64KiB", func(l *gojsonlex.JSONLexer) { l.SetBufSize(64 << 10) }},
+	{"delims", func(l *gojsonlex.JSONLexer) { l.SetSkipDelims(false) }},
+	{"rfc8259", func(l *gojsonlex.JSONLexer) { l.SetDialect(gojsonlex.DialectRFC8259) }},
+	{"validate", func(l *gojsonlex.JSONLexer) { l.SetValidateStructure(true) }},
+	{"copy", func(l *gojsonlex.JSONLexer) { l.SetCopyStrings(true) }},
+}
+
+func BenchmarkCorpora(b *testing.B) {
+	for _, c := range Corpora(corpusSize) {
+		for _, s := range settings {
+			b.Run(c.Name+"/"+s.name, func(b *testing.B) {
+				Benchmark(b, c, s.configure)
+			})
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package gojsonlex
+
+// maxValueClasses is the max number of classes accepted by SetValueClasses
+const maxValueClasses = 255
+
+// ValueClass is a semantic type of string values (e.g. a timestamp or an IP address)
+// recognized by Match, see JSONLexer.SetValueClasses. Common classes are provided by
+// package valueclass.
+type ValueClass struct {
+	Name  string
+	Match func(s string) bool
+}
+
+// classify returns the 1-based index of the first class matching s, 0 if none does
+func classify(classes []ValueClass, s string) uint8 {
+	for i := range classes {
+		if classes[i].Match(s) {
+			return uint8(i + 1)
+		}
+	}
+
+	return 0
+}
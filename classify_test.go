@@ -0,0 +1,47 @@
+package gojsonlex
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLexerSetValueClasses(t *testing.T) {
+	input := `{"ip": "5.61.233.11", "host": "localhost", "ips": ["::1", 1], "5.5.5.5": ""}`
+	expected := []int{-1, 1, -1, -1, -1, 1, -1, -1, 0}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	empty := ValueClass{Name: "empty", Match: func(s string) bool { return s == "" }}
+	ip := ValueClass{Name: "ip", Match: func(s string) bool { return strings.ContainsAny(s, ".:") }}
+
+	if err := l.SetValueClasses(empty, ip); err != nil {
+		t.Fatalf("could not set value classes: %v", err)
+	}
+
+	var output []int
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, token.Class())
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	if err := l.SetValueClasses(make([]ValueClass, 256)...); err == nil {
+		t.Errorf("256 classes must have been rejected")
+	}
+}
@@ -0,0 +1,140 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+)
+
+const defaultColumnBatchSize = 1024
+
+// Column holds the values of a field in a ColumnBatch. Types, Numbers, Strings and Bools
+// have an element per record, only the slice matching the type of the value is meaningful
+// for a given record. Bit i of Valid is set if the i-th record has a scalar other than
+// null at the field path.
+type Column struct {
+	Types   []TokenType
+	Numbers []float64
+	Strings []string
+	Bools   []bool
+	Valid   []uint64
+}
+
+// IsValid reports whether the i-th record has a value in the column
+func (c *Column) IsValid(i int) bool {
+	return c.Valid[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (c *Column) reset() {
+	c.Types = c.Types[:0]
+	c.Numbers = c.Numbers[:0]
+	c.Strings = c.Strings[:0]
+	c.Bools = c.Bools[:0]
+	c.Valid = c.Valid[:0]
+}
+
+func (c *Column) add(found bool, t *TokenGeneric, arena []byte) []byte {
+	i := len(c.Types)
+	if i%64 == 0 {
+		c.Valid = append(c.Valid, 0)
+	}
+
+	var (
+		number  float64
+		str     string
+		boolean bool
+	)
+
+	typ := LexerTokenTypeNull
+
+	if found {
+		typ = t.t
+
+		switch t.t {
+		case LexerTokenTypeNumber:
+			number = t.number
+		case LexerTokenTypeString:
+			start := len(arena)
+			arena = append(arena, t.str...)
+			str = unsafeStringFromBytes(arena[start:])
+		case LexerTokenTypeBool:
+			boolean = t.boolean
+		}
+
+		if t.t != LexerTokenTypeNull {
+			c.Valid[i/64] |= 1 << uint(i%64)
+		}
+	}
+
+	c.Types = append(c.Types, typ)
+	c.Numbers = append(c.Numbers, number)
+	c.Strings = append(c.Strings, str)
+	c.Bools = append(c.Bools, boolean)
+
+	return arena
+}
+
+// ColumnBatch is a batch of records in the structure of arrays layout: a Column per
+// field, the i-th element of every column belongs to the i-th record of the batch
+type ColumnBatch struct {
+	Len     int
+	Columns []Column
+
+	arena []byte // copies of strings
+}
+
+func (b *ColumnBatch) reset() {
+	b.Len = 0
+	b.arena = b.arena[:0]
+
+	for i := range b.Columns {
+		b.Columns[i].reset()
+	}
+}
+
+// ExtractColumns reads records found at path records from r (e.g. "*" for elements of a
+// top-level array or an empty path for NDJSON) and calls fn with batches of up to
+// batchSize records (1024 if 0) holding the scalars at the field paths relative to
+// records. Non-scalar and missing values are reported as nulls. Such a layout is
+// amenable to vectorized post-processing. The batch and its strings are valid until fn
+// returns. Parsing stops at the first error returned by fn, which is returned as is.
+func ExtractColumns(r io.Reader, records Path, fields []Path, batchSize int, fn func(b *ColumnBatch) error) error {
+	if batchSize < 0 {
+		return fmt.Errorf("invalid batch size %d", batchSize)
+	}
+	if batchSize == 0 {
+		batchSize = defaultColumnBatchSize
+	}
+
+	s, err := NewRecordScanner(r, records, fields...)
+	if err != nil {
+		return err
+	}
+
+	b := &ColumnBatch{Columns: make([]Column, len(fields))}
+
+	for {
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		for i := range b.Columns {
+			b.arena = b.Columns[i].add(s.found[i], &s.values[i], b.arena)
+		}
+
+		if b.Len++; b.Len == batchSize {
+			if err := fn(b); err != nil {
+				return err
+			}
+
+			b.reset()
+		}
+	}
+
+	if b.Len == 0 {
+		return nil
+	}
+
+	return fn(b)
+}
@@ -0,0 +1,115 @@
+package gojsonlex
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// columnRecord is a copy of a record of a ColumnBatch
+type columnRecord []interface{}
+
+func TestExtractColumns(t *testing.T) {
+	input := `{"items": [
+		{"id": 1, "user": {"name": "Bob"}, "admin": true},
+		{"id": 2, "user": {"name": "Alice"}, "admin": null},
+		{"id": "3", "user": "unknown"}
+	]}`
+
+	fields := []Path{ParsePath("id"), ParsePath("user.name"), ParsePath("admin")}
+
+	var records []columnRecord
+	var sizes []int
+
+	err := ExtractColumns(strings.NewReader(input), ParsePath("items.*"), fields, 2, func(b *ColumnBatch) error {
+		sizes = append(sizes, b.Len)
+
+		for i := 0; i < b.Len; i++ {
+			var record columnRecord
+
+			for _, c := range b.Columns {
+				switch {
+				case !c.IsValid(i):
+					record = append(record, nil)
+				case c.Types[i] == LexerTokenTypeNumber:
+					record = append(record, c.Numbers[i])
+				case c.Types[i] == LexerTokenTypeString:
+					record = append(record, StringDeepCopy(c.Strings[i]))
+				case c.Types[i] == LexerTokenTypeBool:
+					record = append(record, c.Bools[i])
+				}
+			}
+
+			records = append(records, record)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []columnRecord{
+		{1.0, "Bob", true},
+		{2.0, "Alice", nil},
+		{"3", nil, nil},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("got %v, expected %v", records, expected)
+	}
+
+	if !reflect.DeepEqual(sizes, []int{2, 1}) {
+		t.Errorf("got batch sizes %v, expected [2 1]", sizes)
+	}
+}
+
+func TestExtractColumnsValidity(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 130; i++ {
+		if i%3 == 0 {
+			input.WriteString("null\n")
+		} else {
+			input.WriteString("1\n")
+		}
+	}
+
+	err := ExtractColumns(strings.NewReader(input.String()), Path{}, []Path{{}}, 0, func(b *ColumnBatch) error {
+		if b.Len != 130 || len(b.Columns[0].Valid) != 3 {
+			t.Fatalf("got %d records and %d bitmap words", b.Len, len(b.Columns[0].Valid))
+		}
+
+		for i := 0; i < b.Len; i++ {
+			if b.Columns[0].IsValid(i) != (i%3 != 0) {
+				t.Errorf("record %d: wrong validity", i)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestExtractColumnsFails(t *testing.T) {
+	fields := []Path{ParsePath("a")}
+	noop := func(b *ColumnBatch) error { return nil }
+
+	if err := ExtractColumns(strings.NewReader(`{"a": 1}`), Path{}, fields, -1, noop); err == nil {
+		t.Errorf("negative batch size must have failed")
+	}
+
+	if err := ExtractColumns(strings.NewReader(`{"a": [}`), Path{}, fields, 0, noop); err == nil {
+		t.Errorf("malformed input must have failed")
+	}
+
+	errStop := errors.New("stop")
+	err := ExtractColumns(strings.NewReader(`{"a": 1}`), Path{}, fields, 0, func(b *ColumnBatch) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("got %v, expected the error returned by fn", err)
+	}
+}
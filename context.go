@@ -0,0 +1,58 @@
+package gojsonlex
+
+// TokenContext describes the position of a token in the document: its path (keys and
+// array indices) and its offset in the input. It is passed to callbacks so that they do
+// not have to track the structure themselves and is valid only until the callback
+// returns. See JSONLexer.CurrentPath for the definition of the path of a token.
+type TokenContext struct {
+	tr     *PathTracker // nil if the path is not tracked
+	depth  int          // number of tracker frames describing the path
+	offset int64
+}
+
+// Depth returns the number of segments in the path of the token, 0 for top-level values
+func (c TokenContext) Depth() int {
+	return c.depth
+}
+
+// Offset returns the offset of the first byte of the token in the input stream
+func (c TokenContext) Offset() int64 {
+	return c.offset
+}
+
+// Path returns keys and array indices of the path of the token
+func (c TokenContext) Path() []string {
+	if c.tr == nil {
+		return nil
+	}
+
+	return c.tr.segmentsAt(c.depth)
+}
+
+// Pointer returns the path of the token as a JSON Pointer (RFC 6901), e.g. "/cells/3/value"
+func (c TokenContext) Pointer() string {
+	if c.tr == nil {
+		return ""
+	}
+
+	return c.tr.pointerAt(c.depth)
+}
+
+// String returns the path of the token in the form accepted by ParsePath, e.g.
+// "cells.3.value"
+func (c TokenContext) String() string {
+	if c.tr == nil {
+		return ""
+	}
+
+	return c.tr.pathAt(c.depth)
+}
+
+// Matches reports whether the path of the token matches p without making any allocations
+func (c TokenContext) Matches(p Path) bool {
+	if c.tr == nil {
+		return false
+	}
+
+	return c.tr.matchesAt(c.depth, p)
+}
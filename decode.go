@@ -0,0 +1,671 @@
+package gojsonlex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Decode reads the next complete value from the input and stores it in v the way
+// encoding/json unmarshals into interface{}: map[string]interface{} for objects,
+// []interface{} for arrays, string, bool and nil. Numbers are converted the way Token
+// converts them (float64 by default, see SetIntegers and SetRawNumbers). Strings are
+// copied, so the value stays valid after further lexing. It allows to decode small
+// subtrees (e.g. the value of a key found with TokenFast) while scanning the rest of the
+// input token by token. The value is assembled regardless of SetSkipDelims and
+// SetTokenFilter, a preceding ',' or ':' is skipped. io.EOF is returned if the input has
+// been exhausted between top-level values.
+func (l *JSONLexer) Decode(v *interface{}) error {
+	return l.decode(v, false)
+}
+
+// DecodeOrdered is like Decode, but objects are stored as Object keeping the original
+// order of keys as well as duplicate keys. Marshaling the value with encoding/json writes
+// the members back in the same order, so that e.g. config editing tools may change some
+// values and re-serialize the rest of the document as it has been. Numbers are written
+// as they have been read only with SetRawNumbers.
+func (l *JSONLexer) DecodeOrdered(v *interface{}) error {
+	return l.decode(v, true)
+}
+
+// decode implements Decode and DecodeOrdered
+func (l *JSONLexer) decode(v *interface{}, ordered bool) error {
+	return l.withoutFilters(func() error {
+		t, err := nextValueToken(l)
+		if err != nil {
+			return err
+		}
+
+		value, err := decodeValue(l, &t, ordered)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+
+		*v = value
+
+		return nil
+	})
+}
+
+// nextValueToken returns the next token skipping ',' and ':'
+func nextValueToken(src TokenSource) (TokenGeneric, error) {
+	for {
+		t, err := src.TokenFast()
+		if err != nil {
+			return t, err
+		}
+
+		if !isSeparator(&t) {
+			return t, nil
+		}
+	}
+}
+
+// decodeValue assembles the value started by t, objects are stored as Object if ordered
+// is set
+func decodeValue(src TokenSource, t *TokenGeneric, ordered bool) (interface{}, error) {
+	switch {
+	case t.t == LexerTokenTypeString:
+		return t.StringCopy(), nil
+	case t.t != LexerTokenTypeDelim:
+		// raw numbers point to the buffer just like strings do
+		t.own()
+		return t.jsonToken(), nil
+	case t.delim == '{' && ordered:
+		return decodeOrderedObject(src)
+	case t.delim == '{':
+		return decodeObject(src)
+	case t.delim == '[':
+		return decodeArray(src, ordered)
+	}
+
+	return nil, fmt.Errorf("unexpected '%c', expected a value", t.delim)
+}
+
+// decodeObject reads members of an object which '{' has already been read
+func decodeObject(src TokenSource) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+
+	for {
+		t, err := nextValueToken(src)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.t == LexerTokenTypeDelim && t.delim == '}' {
+			return obj, nil
+		}
+
+		if t.t != LexerTokenTypeString {
+			return nil, fmt.Errorf("expected object key, got %s", t.t)
+		}
+
+		key := t.StringCopy()
+
+		if t, err = nextValueToken(src); err != nil {
+			return nil, err
+		}
+
+		if obj[key], err = decodeValue(src, &t, false); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Member is a member of an object decoded by DecodeOrdered
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Object is an object decoded by DecodeOrdered: its members in the original order,
+// duplicate keys included
+type Object []Member
+
+// Get returns the value of the last member with the given key, which is the one
+// encoding/json would keep, and reports whether such a member exists
+func (o Object) Get(key string) (interface{}, bool) {
+	for i := len(o) - 1; i >= 0; i-- {
+		if o[i].Key == key {
+			return o[i].Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// MarshalJSON writes the members in their order, duplicate keys included
+func (o Object) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+
+	for i, member := range o {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+
+		key, err := json.Marshal(member.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(member.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+
+	return append(buf, '}'), nil
+}
+
+// decodeOrderedObject reads members of an object which '{' has already been read keeping
+// their order
+func decodeOrderedObject(src TokenSource) (Object, error) {
+	obj := make(Object, 0)
+
+	for {
+		t, err := nextValueToken(src)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.t == LexerTokenTypeDelim && t.delim == '}' {
+			return obj, nil
+		}
+
+		if t.t != LexerTokenTypeString {
+			return nil, fmt.Errorf("expected object key, got %s", t.t)
+		}
+
+		member := Member{Key: t.StringCopy()}
+
+		if t, err = nextValueToken(src); err != nil {
+			return nil, err
+		}
+
+		if member.Value, err = decodeValue(src, &t, true); err != nil {
+			return nil, err
+		}
+
+		obj = append(obj, member)
+	}
+}
+
+// decodeArray reads elements of an array which '[' has already been read
+func decodeArray(src TokenSource, ordered bool) ([]interface{}, error) {
+	arr := make([]interface{}, 0)
+
+	for {
+		t, err := nextValueToken(src)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.t == LexerTokenTypeDelim && t.delim == ']' {
+			return arr, nil
+		}
+
+		v, err := decodeValue(src, &t, ordered)
+		if err != nil {
+			return nil, err
+		}
+
+		arr = append(arr, v)
+	}
+}
+
+// withoutFilters calls fn with all tokens (delimiters included) being emitted
+func (l *JSONLexer) withoutFilters(fn func() error) error {
+	skipDelims, skippedTokens := l.skipDelims, l.skippedTokens
+	l.skipDelims, l.skippedTokens = false, 0
+
+	defer func() {
+		l.skipDelims, l.skippedTokens = skipDelims, skippedTokens
+	}()
+
+	return fn()
+}
+
+// DecodeNext reads the next complete value from the input and stores it in the value
+// pointed to by v following the rules of encoding/json.Unmarshal: struct fields are
+// matched by their json tags or names (case-insensitively if there is no exact match),
+// unknown keys are skipped without converting their values, null sets pointers, maps,
+// slices and interfaces to nil and leaves other values unchanged. Numbers can be decoded
+// into integers, floats and json.Number, objects into structs and maps with string keys,
+// arrays into slices and arrays, anything into interface{} (see Decode). Keys are matched
+// without copying them, strings are copied unless SetCopyStrings has been enabled, in
+// which case they are used as is. json.Unmarshaler, encoding.TextUnmarshaler and
+// base64-encoded []byte are not supported. Filters are ignored like in Decode, io.EOF is
+// returned if the input has been exhausted between top-level values. It makes decoding
+// of huge arrays of objects element by element possible without buffering them.
+func (l *JSONLexer) DecodeNext(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("could not decode into %T, a non-nil pointer is required", v)
+	}
+
+	return l.withoutFilters(func() error {
+		t, err := nextValueToken(l)
+		if err != nil {
+			return err
+		}
+
+		err = l.decodeReflect(&t, rv.Elem())
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return err
+	})
+}
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// decodeReflect stores the value started by t in v
+func (l *JSONLexer) decodeReflect(t *TokenGeneric, v reflect.Value) error {
+	if t.t == LexerTokenTypeNull {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return l.decodeReflect(t, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			break
+		}
+
+		value, err := decodeValue(l, t, false)
+		if err != nil {
+			return err
+		}
+
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+
+		return nil
+	}
+
+	switch t.t {
+	case LexerTokenTypeString:
+		if v.Kind() == reflect.String {
+			v.SetString(l.ownedString(t))
+			return nil
+		}
+	case LexerTokenTypeBool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(t.boolean)
+			return nil
+		}
+	case LexerTokenTypeNumber, LexerTokenTypeInt:
+		return decodeNumber(t, v)
+	case LexerTokenTypeDelim:
+		switch {
+		case t.delim == '{' && v.Kind() == reflect.Struct:
+			return l.decodeStruct(v)
+		case t.delim == '{' && v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			return l.decodeMap(v)
+		case t.delim == '[' && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array):
+			return l.decodeSlice(v)
+		case t.delim != '{' && t.delim != '[':
+			return fmt.Errorf("unexpected '%c', expected a value", t.delim)
+		}
+
+		kind := "object"
+		if t.delim == '[' {
+			kind = "array"
+		}
+
+		return fmt.Errorf("could not decode %s into Go value of type %s", kind, v.Type())
+	}
+
+	return fmt.Errorf("could not decode %s into Go value of type %s", t.t, v.Type())
+}
+
+// ownedString returns the string of t that stays valid after further lexing
+func (l *JSONLexer) ownedString(t *TokenGeneric) string {
+	if l.copyStrings {
+		return t.str
+	}
+
+	return t.StringCopy()
+}
+
+func decodeNumber(t *TokenGeneric, v reflect.Value) error {
+	var err error
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if t.t == LexerTokenTypeInt {
+			i, err = t.Int64()
+		} else {
+			i, err = strconv.ParseInt(t.NumberRaw(), 10, 64)
+		}
+
+		if err == nil && v.OverflowInt(i) {
+			err = fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+		if err == nil {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if t.t == LexerTokenTypeInt {
+			u, err = t.Uint64()
+		} else {
+			u, err = strconv.ParseUint(t.NumberRaw(), 10, 64)
+		}
+
+		if err == nil && v.OverflowUint(u) {
+			err = fmt.Errorf("%d overflows %s", u, v.Type())
+		}
+		if err == nil {
+			v.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		f := t.Number()
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%g overflows %s", f, v.Type())
+		}
+
+		v.SetFloat(f)
+	case reflect.String:
+		if v.Type() != jsonNumberType || t.NumberRaw() == "" {
+			return fmt.Errorf("could not decode number into Go value of type %s", v.Type())
+		}
+
+		v.SetString(StringDeepCopy(t.NumberRaw()))
+	default:
+		return fmt.Errorf("could not decode number into Go value of type %s", v.Type())
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not decode number into Go value of type %s: %w", v.Type(), err)
+	}
+
+	return nil
+}
+
+// decodeStruct reads members of an object which '{' has already been read into v
+func (l *JSONLexer) decodeStruct(v reflect.Value) error {
+	fields := cachedStructFields(v.Type())
+
+	for {
+		t, err := nextValueToken(l)
+		if err != nil {
+			return err
+		}
+
+		if t.t == LexerTokenTypeDelim && t.delim == '}' {
+			return nil
+		}
+
+		if t.t != LexerTokenTypeString {
+			return fmt.Errorf("expected object key, got %s", t.t)
+		}
+
+		field := fields.lookup(t.str)
+		if field == nil {
+			if err := l.SkipValue(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if t, err = nextValueToken(l); err != nil {
+			return err
+		}
+
+		if err := l.decodeReflect(&t, fieldByIndex(v, field.index)); err != nil {
+			return fmt.Errorf("field %s: %w", field.name, err)
+		}
+	}
+}
+
+// decodeMap reads members of an object which '{' has already been read into v
+func (l *JSONLexer) decodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	elemType := v.Type().Elem()
+
+	for {
+		t, err := nextValueToken(l)
+		if err != nil {
+			return err
+		}
+
+		if t.t == LexerTokenTypeDelim && t.delim == '}' {
+			return nil
+		}
+
+		if t.t != LexerTokenTypeString {
+			return fmt.Errorf("expected object key, got %s", t.t)
+		}
+
+		key := reflect.ValueOf(l.ownedString(&t)).Convert(v.Type().Key())
+
+		if t, err = nextValueToken(l); err != nil {
+			return err
+		}
+
+		elem := reflect.New(elemType).Elem()
+		if err := l.decodeReflect(&t, elem); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(key, elem)
+	}
+}
+
+// decodeSlice reads elements of an array which '[' has already been read into v, which
+// is a slice or an array
+func (l *JSONLexer) decodeSlice(v reflect.Value) error {
+	isSlice := v.Kind() == reflect.Slice
+	if isSlice {
+		v.SetLen(0)
+	}
+
+	for i := 0; ; i++ {
+		t, err := nextValueToken(l)
+		if err != nil {
+			return err
+		}
+
+		if t.t == LexerTokenTypeDelim && t.delim == ']' {
+			if isSlice && v.IsNil() {
+				v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+			}
+
+			for ; !isSlice && i < v.Len(); i++ {
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+			}
+
+			return nil
+		}
+
+		if isSlice && i == v.Len() {
+			if i == v.Cap() {
+				v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			} else {
+				v.SetLen(i + 1)
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+			}
+		}
+
+		if i >= v.Len() {
+			// elements that do not fit into the array are skipped
+			if err := l.skipStartedValue(&t); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := l.decodeReflect(&t, v.Index(i)); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+}
+
+// skipStartedValue skips the rest of the value started by t
+func (l *JSONLexer) skipStartedValue(t *TokenGeneric) error {
+	if t.t != LexerTokenTypeDelim {
+		return nil
+	}
+
+	if t.delim != '{' && t.delim != '[' {
+		return fmt.Errorf("unexpected '%c', expected a value", t.delim)
+	}
+
+	for depth := 1; depth > 0; {
+		t, err := l.TokenFast()
+		if err != nil {
+			return err
+		}
+
+		if t.t == LexerTokenTypeDelim && (t.delim == '{' || t.delim == '[') {
+			depth++
+		} else if t.isCloseDelim() {
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// fieldByIndex returns the nested field allocating embedded pointers to structs
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+type structFields []structField
+
+// lookup returns the field named key preferring an exact match to a case-insensitive one
+func (fields structFields) lookup(key string) *structField {
+	var folded *structField
+
+	for i := range fields {
+		if fields[i].name == key {
+			return &fields[i]
+		}
+
+		if folded == nil && strings.EqualFold(fields[i].name, key) {
+			folded = &fields[i]
+		}
+	}
+
+	return folded
+}
+
+var structFieldsCache sync.Map // reflect.Type -> structFields
+
+func cachedStructFields(t reflect.Type) structFields {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.(structFields)
+	}
+
+	fields, _ := structFieldsCache.LoadOrStore(t, typeFields(t, nil, map[string]bool{}))
+
+	return fields.(structFields)
+}
+
+// typeFields returns the decodable fields of the struct type, fields of embedded structs
+// are promoted unless a shallower field has the same name
+func typeFields(t reflect.Type, index []int, seen map[string]bool) structFields {
+	var fields, embedded structFields
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := tag
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name = tag[:comma]
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if f.PkgPath != "" && f.Type.Kind() == reflect.Ptr {
+				continue // can not be allocated
+			}
+
+			embedded = append(embedded, structField{index: fieldIndex})
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			fields = append(fields, structField{name: name, index: fieldIndex})
+		}
+	}
+
+	for _, e := range embedded {
+		ft := t.Field(e.index[len(e.index)-1]).Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		fields = append(fields, typeFields(ft, e.index, seen)...)
+	}
+
+	return fields
+}
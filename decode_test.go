@@ -0,0 +1,263 @@
+package gojsonlex
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLexerDecode(t *testing.T) {
+	testcases := []string{
+		`{"a": 1, "b": [true, null, "x\n"], "c": {"d": {}, "e": []}}`,
+		`[1.5, -2, "почта", [[]]]`,
+		`"str"`,
+		`null`,
+		`{"a": 1, "a": 2}`,
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+
+		var got, expected interface{}
+		if err := l.Decode(&got); err != nil {
+			t.Errorf("testcase '%s': %v", testcase, err)
+			continue
+		}
+
+		json.Unmarshal([]byte(testcase), &expected)
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("testcase '%s': got %#v, expected %#v", testcase, got, expected)
+		}
+
+		if err := l.Decode(&got); err != io.EOF {
+			t.Errorf("testcase '%s': got %v at the end of input, expected EOF", testcase, err)
+		}
+	}
+}
+
+func TestJSONLexerDecodeOrdered(t *testing.T) {
+	testcases := []string{
+		`{"b":1,"a":[true,null,"x\n",{"z":{},"y":[]}],"c":{"e":1.50,"d":-0}}`,
+		`{"a":1,"b":2,"a":3}`,
+		`[{"почта":"x"},{}]`,
+		`"str"`,
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetRawNumbers(true)
+
+		var got interface{}
+		if err := l.DecodeOrdered(&got); err != nil {
+			t.Errorf("testcase '%s': %v", testcase, err)
+			continue
+		}
+
+		out, err := json.Marshal(got)
+		if err != nil {
+			t.Errorf("testcase '%s': could not marshal: %v", testcase, err)
+			continue
+		}
+
+		if string(out) != testcase {
+			t.Errorf("testcase '%s': got '%s' after marshaling", testcase, out)
+		}
+	}
+}
+
+func TestObjectGet(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(`{"a": 1, "b": 2, "a": 3}`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	var v interface{}
+	if err := l.DecodeOrdered(&v); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	obj := v.(Object)
+
+	if value, ok := obj.Get("a"); !ok || value != 3.0 {
+		t.Errorf("got %v for 'a', expected the last duplicate 3", value)
+	}
+	if _, ok := obj.Get("c"); ok {
+		t.Errorf("'c' must not have been found")
+	}
+	if len(obj) != 3 || obj[0].Key != "a" || obj[1].Key != "b" || obj[2].Key != "a" {
+		t.Errorf("got members %v, expected a, b, a", obj)
+	}
+}
+
+func TestJSONLexerDecodeSubtree(t *testing.T) {
+	input := `{"id": 1, "meta": {"tags": ["a", "b"]}, "payload": [1, {"x": null}], "n": 2}`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	decoded := map[string]interface{}{}
+	var keys []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if !token.IsKey() {
+			continue
+		}
+
+		keys = append(keys, token.StringCopy())
+
+		if token.StringEquals("meta") || token.StringEquals("payload") {
+			var v interface{}
+			if err := l.Decode(&v); err != nil {
+				t.Fatalf("could not decode '%s': %v", keys[len(keys)-1], err)
+			}
+
+			decoded[keys[len(keys)-1]] = v
+		}
+	}
+
+	expected := map[string]interface{}{
+		"meta":    map[string]interface{}{"tags": []interface{}{"a", "b"}},
+		"payload": []interface{}{float64(1), map[string]interface{}{"x": nil}},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("got %#v, expected %#v", decoded, expected)
+	}
+
+	if expected := []string{"id", "meta", "payload", "n"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("got keys %v, expected %v", keys, expected)
+	}
+
+	for _, input := range []string{`{"a": [1, 2`, `{1: 2}`, `]`} {
+		l, _ := NewJSONLexer(strings.NewReader(input))
+
+		var v interface{}
+		if err := l.Decode(&v); err == nil || err == io.EOF {
+			t.Errorf("testcase '%s': got %v, expected an error", input, err)
+		}
+	}
+}
+
+type decodeNextBase struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+}
+
+type decodeNextItem struct {
+	decodeNextBase
+	Name    string
+	Tags    []string          `json:"tags"`
+	Scores  [2]float32        `json:"scores"`
+	Attrs   map[string]uint16 `json:"attrs"`
+	Next    *decodeNextItem   `json:"next"`
+	Raw     json.Number       `json:"raw"`
+	Any     interface{}       `json:"any"`
+	Ignored string            `json:"-"`
+	hidden  string
+}
+
+func TestJSONLexerDecodeNext(t *testing.T) {
+	input := `[
+		{"id": 1, "kind": "a", "NAME": "first", "tags": ["x", "y"], "scores": [1.5, 2, 3],
+			"attrs": {"p": 80}, "next": {"id": 2, "next": null}, "raw": 1.570e+10,
+			"any": [1, {"b": null}], "Ignored": "z", "hidden": "h", "unknown": {"deep": [1, {}]}},
+		{"id": -3, "name": "second", "tags": null, "scores": [7]}
+	]`
+
+	expected := []decodeNextItem{
+		{
+			decodeNextBase: decodeNextBase{ID: 1, Kind: "a"},
+			Name:           "first",
+			Tags:           []string{"x", "y"},
+			Scores:         [2]float32{1.5, 2},
+			Attrs:          map[string]uint16{"p": 80},
+			Next:           &decodeNextItem{decodeNextBase: decodeNextBase{ID: 2}},
+			Raw:            "1.570e+10",
+			Any:            []interface{}{float64(1), map[string]interface{}{"b": nil}},
+		},
+		{
+			decodeNextBase: decodeNextBase{ID: -3},
+			Name:           "second",
+			Scores:         [2]float32{7, 0},
+		},
+	}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(8)
+	l.SetSkipDelims(false)
+
+	if token, err := l.TokenFast(); err != nil || printToken(token) != "[" {
+		t.Fatalf("got '%s' (%v), expected '['", printToken(token), err)
+	}
+
+	var items []decodeNextItem
+
+	for l.More() {
+		item := decodeNextItem{}
+		if err := l.DecodeNext(&item); err != nil {
+			t.Fatalf("could not decode item %d: %v", len(items), err)
+		}
+
+		items = append(items, item)
+	}
+
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("got %+v, expected %+v", items, expected)
+	}
+}
+
+func TestJSONLexerDecodeNextFails(t *testing.T) {
+	testcases := []struct {
+		input string
+		v     interface{}
+	}{
+		{`"1"`, new(int)},
+		{`1.5`, new(int)},
+		{`300`, new(uint8)},
+		{`-1`, new(uint)},
+		{`[1]`, new(map[string]int)},
+		{`{"a": 1}`, new([]int)},
+		{`{"id": "1"}`, new(decodeNextBase)},
+		{`{"tags": [1]}`, new(decodeNextItem)},
+		{`{"id": 1`, new(decodeNextBase)},
+		{`1`, decodeNextBase{}},
+		{`1`, nil},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		if err := l.DecodeNext(testcase.v); err == nil || err == io.EOF {
+			t.Errorf("testcase '%s': got %v, expected an error", testcase.input, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package gojsonlex
+
+import (
+	"sync"
+	"unicode"
+)
+
+// Dialect describes the flavour of JSON recognized by JSONLexer: its literal set, escape
+// rules, number grammar, comment support and whitespace policy. Dialects are defined
+// declaratively, nil predicates fall back to the default ones: IsDelim, IsValidEscapedSymbol
+// and CanAppearInNumber. The zero Dialect is the same as DialectLenient.
+type Dialect struct {
+	// Name identifies the dialect in the registry, see RegisterDialect
+	Name string
+
+	// IsDelim reports whether the rune is a delimiter
+	IsDelim func(c rune) bool
+	// IsValidEscapedSymbol reports whether the rune may follow a backslash inside strings
+	IsValidEscapedSymbol func(c rune) bool
+	// CanAppearInNumber reports whether the rune may appear in numbers, numbers still
+	// have to follow the JSON number grammar
+	CanAppearInNumber func(c rune) bool
+
+	// StrictLiterals requires true, false and null to be lowercase
+	StrictLiterals bool
+	// StrictNumbers requires numbers to follow RFC 8259: no leading '+', no leading zeros
+	// and at least one digit on both sides of '.'
+	StrictNumbers bool
+	// StrictWhitespace makes any byte between tokens other than space, tab, CR and LF an
+	// error, otherwise such bytes are skipped
+	StrictWhitespace bool
+	// StrictStrings makes unescaped control characters (below 0x20) inside strings an error
+	StrictStrings bool
+	// Comments enables '//' and '/* */' comments between tokens
+	Comments bool
+	// SingleQuotedStrings enables strings enclosed in single quotes
+	SingleQuotedStrings bool
+	// RawStrings enables raw strings enclosed in backticks or in triple double quotes
+	// ("""..."""), they may span lines and their content is taken as is: escape
+	// sequences are not processed and any bytes are allowed
+	RawStrings bool
+
+	// NameSeparators are bytes separating keys from values in addition to ':' (e.g. "="
+	// for HOCON-like formats), they are returned as ':' delimiters
+	NameSeparators string
+	// ValueSeparators are bytes separating members and elements in addition to ','
+	// (e.g. ";"), they are returned as ',' delimiters
+	ValueSeparators string
+}
+
+var (
+	// DialectLenient is the default dialect: literals are case-insensitive, numbers may
+	// have a leading '+', leading zeros and a leading or trailing '.', '\U' is accepted
+	// as '\u' and unknown bytes between tokens are skipped
+	DialectLenient = Dialect{
+		Name: "lenient",
+	}
+
+	// DialectRFC8259 is JSON exactly as defined by RFC 8259
+	DialectRFC8259 = Dialect{
+		Name:                 "rfc8259",
+		IsValidEscapedSymbol: isRFC8259EscapedSymbol,
+		StrictLiterals:       true,
+		StrictNumbers:        true,
+		StrictWhitespace:     true,
+		StrictStrings:        true,
+	}
+
+	// DialectJSON5 is a subset of JSON5: comments, single-quoted strings, escapes '\'',
+	// '\v' and '\0' and relaxed numbers. Unquoted keys, hexadecimal numbers, Infinity
+	// and NaN are not supported.
+	DialectJSON5 = Dialect{
+		Name:                 "json5",
+		IsValidEscapedSymbol: isJSON5EscapedSymbol,
+		StrictLiterals:       true,
+		Comments:             true,
+		SingleQuotedStrings:  true,
+	}
+)
+
+var dialects = struct {
+	sync.RWMutex
+	byName map[string]Dialect
+}{
+	byName: map[string]Dialect{
+		DialectLenient.Name: DialectLenient,
+		DialectRFC8259.Name: DialectRFC8259,
+		DialectJSON5.Name:   DialectJSON5,
+	},
+}
+
+// RegisterDialect adds the dialect to the registry under its name replacing the dialect
+// previously registered with the same name (if any)
+func RegisterDialect(d Dialect) {
+	dialects.Lock()
+	defer dialects.Unlock()
+
+	dialects.byName[d.Name] = d
+}
+
+// LookupDialect returns the dialect registered with the given name, the built-in dialects
+// are registered as "lenient", "rfc8259" and "json5"
+func LookupDialect(name string) (Dialect, bool) {
+	dialects.RLock()
+	defer dialects.RUnlock()
+
+	d, ok := dialects.byName[name]
+
+	return d, ok
+}
+
+func isRFC8259EscapedSymbol(c rune) bool {
+	return c != 'U' && IsValidEscapedSymbol(c)
+}
+
+func isJSON5EscapedSymbol(c rune) bool {
+	return c == '\'' || c == 'v' || c == '0' || isRFC8259EscapedSymbol(c)
+}
+
+type charClass byte
+
+const (
+	charClassDelim charClass = 1 << iota
+	charClassEscapedSymbol
+	charClassNumber
+	charClassSpace
+	charClassQuote        // opens a string
+	charClassCommentStart // opens a comment
+)
+
+// charClasses is a lookup table of classes of all bytes, it is built from the predicates
+// of a Dialect so that the lexer does not have to call them for every byte
+type charClasses [256]charClass
+
+var defaultCharClasses = Dialect{}.charClasses()
+
+func (d Dialect) charClasses() *charClasses {
+	isDelim := d.IsDelim
+	if isDelim == nil {
+		isDelim = IsDelim
+	}
+
+	isValidEscapedSymbol := d.IsValidEscapedSymbol
+	if isValidEscapedSymbol == nil {
+		isValidEscapedSymbol = IsValidEscapedSymbol
+	}
+
+	canAppearInNumber := d.CanAppearInNumber
+	if canAppearInNumber == nil {
+		canAppearInNumber = CanAppearInNumber
+	}
+
+	classes := &charClasses{}
+
+	for i := range classes {
+		c := rune(i)
+
+		if isDelim(c) {
+			classes[i] |= charClassDelim
+		}
+		if isValidEscapedSymbol(c) {
+			classes[i] |= charClassEscapedSymbol
+		}
+		if canAppearInNumber(c) {
+			classes[i] |= charClassNumber
+		}
+		if unicode.IsSpace(c) {
+			classes[i] |= charClassSpace
+		}
+	}
+
+	classes['"'] |= charClassQuote
+	if d.SingleQuotedStrings {
+		classes['\''] |= charClassQuote
+	}
+	if d.RawStrings {
+		classes['`'] |= charClassQuote
+	}
+	if d.Comments {
+		classes['/'] |= charClassCommentStart
+	}
+
+	for _, seps := range []string{d.NameSeparators, d.ValueSeparators} {
+		for i := 0; i < len(seps); i++ {
+			classes[seps[i]] |= charClassDelim
+		}
+	}
+
+	return classes
+}
+
+// separators returns the table mapping additional separators to ':' and ',', nil if
+// the dialect has none
+func (d Dialect) separators() *[256]byte {
+	if d.NameSeparators == "" && d.ValueSeparators == "" {
+		return nil
+	}
+
+	table := &[256]byte{}
+
+	for i := 0; i < len(d.NameSeparators); i++ {
+		table[d.NameSeparators[i]] = ':'
+	}
+	for i := 0; i < len(d.ValueSeparators); i++ {
+		table[d.ValueSeparators[i]] = ','
+	}
+
+	return table
+}
+
+func (c *charClasses) is(b byte, class charClass) bool {
+	return c[b]&class != 0
+}
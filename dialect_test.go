@@ -0,0 +1,226 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type dialectTestCase struct {
+	input   string
+	dialect Dialect
+	output  []string // printed tokens, "!" marks an error
+}
+
+func TestDialect(t *testing.T) {
+	noPlus := func(c rune) bool { return c != '+' && CanAppearInNumber(c) }
+	noSolidus := func(c rune) bool { return c != '/' && IsValidEscapedSymbol(c) }
+	semicolon := func(c rune) bool { return c == ';' || IsDelim(c) }
+
+	testcases := []dialectTestCase{
+		{`[1e+5, "a\/b"]`, Dialect{}, []string{"[", "100000", ",", "a/b", "]"}},
+		{`[1e+5]`, Dialect{CanAppearInNumber: noPlus}, []string{"[", "!"}},
+		{`[1e5, -1]`, Dialect{CanAppearInNumber: noPlus}, []string{"[", "100000", ",", "-1", "]"}},
+		{`["a\/b"]`, Dialect{IsValidEscapedSymbol: noSolidus}, []string{"[", "!"}},
+		{`1;2`, Dialect{}, []string{"!"}},
+		{`1;2`, Dialect{IsDelim: semicolon}, []string{"1", ";", "2"}},
+		{"[\"a\tb\"]", Dialect{}, []string{"[", "a\tb", "]"}},
+		{"[\"a\tb\"]", Dialect{StrictStrings: true}, []string{"[", "!"}},
+		{`["a\tb"]`, Dialect{StrictStrings: true}, []string{"[", "a\tb", "]"}},
+		{`{"a" = 1; "b": [true; "x"]}`, Dialect{NameSeparators: "=", ValueSeparators: ";"},
+			[]string{"{", "a", ":", "1", ",", "b", ":", "[", "true", ",", "x", "]", "}"}},
+		{`{"a" = 1}`, Dialect{}, []string{"{", "a", "1", "}"}},
+		{"[`a\\n\"b`, \"\"\"c\n\\d\"e\"\"\"]", Dialect{RawStrings: true},
+			[]string{"[", `a\n"b`, ",", "c\n\\d\"e", "]"}},
+		{`["", """""", ""]`, Dialect{RawStrings: true}, []string{"[", "", ",", "", ",", "", "]"}},
+		{`"" ""`, Dialect{RawStrings: true}, []string{"", ""}},
+		{"[`a`]", Dialect{}, []string{"[", "]"}},
+		{"[`a\\x`]", Dialect{RawStrings: true, StrictStrings: true}, []string{"[", `a\x`, "]"}},
+		{`["""a""`, Dialect{RawStrings: true}, []string{"[", "!"}},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetDialect(testcase.dialect)
+		l.SetSkipDelims(false)
+
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				output = append(output, "!")
+				break
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != strings.Join(testcase.output, " ") {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.output)
+		}
+	}
+}
+
+type builtinDialectTestCase struct {
+	input   string
+	dialect string
+	output  []string // printed tokens, "!" marks an error
+}
+
+func TestDialectSeparatorsStructure(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(`{"a" = [1; 2]; "b" = {"c" = null}}`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetDialect(Dialect{NameSeparators: "=", ValueSeparators: ";"})
+	l.SetSkipDelims(false)
+	l.SetValidateStructure(true)
+	l.SetTrackPath(true)
+
+	var pointers []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if token.Type() == LexerTokenTypeNumber || token.IsNull() {
+			pointers = append(pointers, l.CurrentPointer())
+		}
+	}
+
+	if strings.Join(pointers, " ") != "/a/0 /a/1 /b/c" {
+		t.Errorf("got %v", pointers)
+	}
+}
+
+func TestBuiltinDialects(t *testing.T) {
+	testcases := []builtinDialectTestCase{
+		{`[True, NULL, +1, .5, 5., 012, "\U0041"]`, "lenient",
+			[]string{"[", "true", ",", "<nil>", ",", "1", ",", "0.5", ",", "5", ",", "12", ",", "A", "]"}},
+		{`x[1]`, "lenient", []string{"[", "1", "]"}},
+		{`[true, null, -0, 0.5, 1e5, 1.5E-3, "A"]`, "rfc8259",
+			[]string{"[", "true", ",", "<nil>", ",", "-0", ",", "0.5", ",", "100000", ",", "0.0015", ",", "A", "]"}},
+		{`[True]`, "rfc8259", []string{"[", "!"}},
+		{`[nulL]`, "rfc8259", []string{"[", "!"}},
+		{`[+1]`, "rfc8259", []string{"[", "!"}},
+		{`[.5]`, "rfc8259", []string{"[", "!"}},
+		{`[-.5]`, "rfc8259", []string{"[", "!"}},
+		{`[5.]`, "rfc8259", []string{"[", "!"}},
+		{`[5.e3]`, "rfc8259", []string{"[", "!"}},
+		{`[012]`, "rfc8259", []string{"[", "!"}},
+		{`[-012]`, "rfc8259", []string{"[", "!"}},
+		{`["\U0041"]`, "rfc8259", []string{"[", "!"}},
+		{`x[1]`, "rfc8259", []string{"!"}},
+		{"[1]\r\n\t [2]", "rfc8259", []string{"[", "1", "]", "[", "2", "]"}},
+		{`// comment
+		{'a': 'it\'s "quoted"', /* comment */ "b": [1/**/, 2 /* * / **/]} // trailing`, "json5",
+			[]string{"{", "a", ":", `it's "quoted"`, ",", "b", ":", "[", "1", ",", "2", "]", "}"}},
+		{`['\v\0']`, "json5", []string{"[", "\v\x00", "]"}},
+		{`[1 /* unterminated ]`, "json5", []string{"[", "1", "!"}},
+		{`[1 / 2]`, "json5", []string{"[", "1", "!"}},
+		{`['a']`, "lenient", []string{"[", "]"}}, // unknown bytes are skipped
+		{`["it\'s"]`, "lenient", []string{"[", "!"}},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		d, ok := LookupDialect(testcase.dialect)
+		if !ok {
+			t.Errorf("testcase '%s': dialect '%s' is not registered", testcase.input, testcase.dialect)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.SetDialect(d)
+		l.SetSkipDelims(false)
+
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				output = append(output, "!")
+				break
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != strings.Join(testcase.output, " ") {
+			t.Errorf("testcase '%s' (%s): got %v, expected %v",
+				testcase.input, testcase.dialect, output, testcase.output)
+		}
+	}
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect(Dialect{Name: "test", Comments: true})
+
+	d, ok := LookupDialect("test")
+	if !ok || !d.Comments {
+		t.Errorf("registered dialect could not be found")
+	}
+
+	if _, ok := LookupDialect("unknown"); ok {
+		t.Errorf("unknown dialect must not be found")
+	}
+}
+
+func TestDialectRawStrings(t *testing.T) {
+	input := "{\"query\": \"\"\"\n  SELECT \"id\"\n  FROM t\n\"\"\", `re`: `^\\d+$`, \"n\": 1}"
+	expected := []string{"query", "\n  SELECT \"id\"\n  FROM t\n", "re", `^\d+$`, "n", "1 4:28"}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+	l.SetDialect(Dialect{RawStrings: true})
+
+	var output []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, printToken(token))
+	}
+
+	line, column, _ := l.Position()
+	output[len(output)-1] += fmt.Sprintf(" %d:%d", line, column)
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %q, expected %q", output, expected)
+	}
+}
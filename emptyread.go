@@ -0,0 +1,93 @@
+package gojsonlex
+
+import (
+	"io"
+	"time"
+)
+
+// defaultMaxEmptyReads is the number of empty reads in a row after which the default policy
+// gives up, the same as bufio.Reader does
+const defaultMaxEmptyReads = 100
+
+// EmptyReadPolicy decides what JSONLexer does once the reader has returned no data and no
+// error emptyReads times in a row: returning nil makes JSONLexer read again (the policy
+// may block or sleep before that), an error stops reading and is returned by Token().
+// By default io.ErrNoProgress is returned after 100 empty reads.
+type EmptyReadPolicy func(emptyReads int) error
+
+// EmptyReadsLimit returns an EmptyReadPolicy retrying right away and failing with
+// io.ErrNoProgress after n empty reads in a row
+func EmptyReadsLimit(n int) EmptyReadPolicy {
+	return func(emptyReads int) error {
+		if emptyReads >= n {
+			return io.ErrNoProgress
+		}
+
+		return nil
+	}
+}
+
+// EmptyReadsBackoff returns an EmptyReadPolicy sleeping before every retry, the delay
+// starts from base and doubles up to max. It fails with io.ErrNoProgress after n empty
+// reads in a row, 0 means retrying forever.
+func EmptyReadsBackoff(base, max time.Duration, n int) EmptyReadPolicy {
+	return func(emptyReads int) error {
+		if n > 0 && emptyReads >= n {
+			return io.ErrNoProgress
+		}
+
+		delay := base
+		for i := 1; i < emptyReads && delay < max; i++ {
+			delay *= 2
+		}
+
+		if delay > max {
+			delay = max
+		}
+
+		time.Sleep(delay)
+
+		return nil
+	}
+}
+
+// readFull reads exactly len(buf) bytes like io.ReadFull does, empty reads are handled
+// according to the EmptyReadPolicy
+func (l *JSONLexer) readFull(buf []byte) (int, error) {
+	n, emptyReads := 0, 0
+
+	for n < len(buf) {
+		m, err := l.r.Read(buf[n:])
+		n += m
+
+		switch {
+		case err == io.EOF && n > 0:
+			return n, io.ErrUnexpectedEOF
+		case err != nil:
+			return n, err
+		case m > 0:
+			emptyReads = 0
+			continue
+		}
+
+		emptyReads++
+
+		if err := l.onEmptyRead(emptyReads); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (l *JSONLexer) onEmptyRead(emptyReads int) error {
+	if l.emptyReadPolicy != nil {
+		return l.emptyReadPolicy(emptyReads)
+	}
+
+	if emptyReads >= defaultMaxEmptyReads {
+		return io.ErrNoProgress
+	}
+
+	return nil
+}
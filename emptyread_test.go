@@ -0,0 +1,113 @@
+package gojsonlex
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingReader returns no data and no error the given number of times before every
+// read of the underlying reader, stalls < 0 means forever
+type stallingReader struct {
+	r      io.Reader
+	stalls int
+	left   int
+}
+
+func (r *stallingReader) Read(p []byte) (int, error) {
+	if r.stalls < 0 {
+		return 0, nil
+	}
+
+	if r.left > 0 {
+		r.left--
+		return 0, nil
+	}
+
+	r.left = r.stalls
+
+	return r.r.Read(p[:1])
+}
+
+func TestJSONLexerEmptyReadPolicy(t *testing.T) {
+	type testCase struct {
+		name   string
+		stalls int
+		policy EmptyReadPolicy
+		fails  bool
+	}
+
+	testCases := []testCase{
+		{"default", 50, nil, false},
+		{"default forever", -1, nil, true},
+		{"limit", 2, EmptyReadsLimit(3), false},
+		{"limit exceeded", 3, EmptyReadsLimit(3), true},
+		{"backoff", 5, EmptyReadsBackoff(time.Microsecond, 4*time.Microsecond, 0), false},
+		{"backoff exceeded", 5, EmptyReadsBackoff(time.Microsecond, 4*time.Microsecond, 5), true},
+	}
+
+	for _, testcase := range testCases {
+		r := &stallingReader{r: strings.NewReader(`{"a": [1, "b"]}`), stalls: testcase.stalls}
+		r.left = r.stalls
+
+		l, err := NewJSONLexer(r)
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetEmptyReadPolicy(testcase.policy)
+
+		tokens := 0
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+
+			tokens++
+		}
+
+		if fails := errors.Is(err, io.ErrNoProgress); fails != testcase.fails {
+			t.Errorf("testcase '%s': got error %v", testcase.name, err)
+		}
+
+		if !testcase.fails && tokens != 3 {
+			t.Errorf("testcase '%s': got %d tokens, expected 3", testcase.name, tokens)
+		}
+	}
+}
+
+func TestJSONLexerEmptyReadPolicyHook(t *testing.T) {
+	r := &stallingReader{r: strings.NewReader(`[1, 2]`), stalls: 2}
+
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	var calls []int
+	l.SetEmptyReadPolicy(func(emptyReads int) error {
+		calls = append(calls, emptyReads)
+		return nil
+	})
+
+	for {
+		if _, err := l.TokenFast(); err != nil {
+			break
+		}
+	}
+
+	// the counter is reset once data has been read
+	for i, emptyReads := range calls {
+		if emptyReads != i%2+1 {
+			t.Fatalf("got empty read counters %v", calls)
+		}
+	}
+
+	if len(calls) == 0 {
+		t.Errorf("policy has not been called")
+	}
+}
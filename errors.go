@@ -0,0 +1,104 @@
+package gojsonlex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrEmptyInput is returned for empty or whitespace-only input with EmptyInputError policy
+var ErrEmptyInput = errors.New("empty input")
+
+// ErrStaleString is returned by Validate for tokens whose strings are no longer valid
+var ErrStaleString = errors.New("string of the token is no longer valid")
+
+// ErrStopped is returned once a token satisfying the predicate set with StopWhen has been
+// returned
+var ErrStopped = errors.New("lexing stopped")
+
+// ErrClosed is returned by lexers and streams that have been closed
+var ErrClosed = errors.New("use of closed lexer")
+
+// ErrNotFound is returned by Get when the input has no value at the requested path
+var ErrNotFound = errors.New("value not found")
+
+// ErrSkipValue is returned by Handler methods to skip the rest of the value (see Walk)
+var ErrSkipValue = errors.New("skip value")
+
+// ErrTokenTooLarge is returned for tokens longer than the limit set with SetMaxTokenSize
+// and for tokens that would need a buffer larger than the max int (2GB on 32-bit
+// platforms)
+var ErrTokenTooLarge = errors.New("token is too large")
+
+// ErrBudgetExceeded is returned when a single Token() call has exhausted the budget set
+// with SetBudget. It is not sticky: the next call continues from where the previous one
+// stopped.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// UnexpectedEOFError is returned when the input ends inside a token or inside an object
+// or an array. It wraps io.ErrUnexpectedEOF, so truncated input can be detected with
+// errors.Is(err, io.ErrUnexpectedEOF).
+type UnexpectedEOFError struct {
+	Offset int64 // offset in the input stream at which the input ended
+	Line   int
+	Column int
+}
+
+func (e *UnexpectedEOFError) Error() string {
+	return fmt.Sprintf("unexpected EOF at line %d, column %d (offset %d)", e.Line, e.Column, e.Offset)
+}
+
+func (e *UnexpectedEOFError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// PositionError is returned for malformed input and failed reads, the position points to
+// the offending byte (or to the start of the token that could not be converted). Lines
+// and columns start from 1, columns are counted in bytes.
+type PositionError struct {
+	Offset int64 // offset in the input stream
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("line %d, column %d (offset %d): %v", e.Line, e.Column, e.Offset, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// StringTooLongError is returned for strings longer than the limit set with
+// SetMaxStringLen
+type StringTooLongError struct {
+	Path  string // path of the string (of the member for keys) in the form accepted by ParsePath
+	Len   int    // length of the unescaped string
+	Limit int
+}
+
+func (e *StringTooLongError) Error() string {
+	return fmt.Sprintf("string at '%s' is %d bytes long, the limit is %d", e.Path, e.Len, e.Limit)
+}
+
+// DepthLimitError is returned for objects and arrays nested deeper than the limit set with
+// SetMaxDepth
+type DepthLimitError struct {
+	Limit int
+}
+
+func (e *DepthLimitError) Error() string {
+	return fmt.Sprintf("nesting depth exceeds the limit of %d", e.Limit)
+}
+
+// BufferLimitError is returned for tokens that do not fit into the buffer of the size
+// limited with SetMaxBufSize
+type BufferLimitError struct {
+	Offset int64 // offset of the first byte of the token in the input stream
+	Limit  int
+}
+
+func (e *BufferLimitError) Error() string {
+	return fmt.Sprintf("token at offset %d does not fit into the buffer limit of %d bytes", e.Offset, e.Limit)
+}
@@ -0,0 +1,116 @@
+package gojsonlex
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// EscapeFlags tune the output of EscapeString and AppendEscapedString
+type EscapeFlags byte
+
+const (
+	// EscapeHTMLSafe makes '<', '>', '&', U+2028 and U+2029 escaped so that the output
+	// can be safely embedded into HTML <script> tags
+	EscapeHTMLSafe EscapeFlags = 1 << iota
+	// EscapeASCIIOnly makes all non-ASCII runes escaped with \uXXXX sequences
+	EscapeASCIIOnly
+)
+
+func byteNeedsEscaping(c byte, flags EscapeFlags) bool {
+	switch {
+	case c < 0x20, c == '"', c == '\\':
+		return true
+	case c == '<', c == '>', c == '&':
+		return flags&EscapeHTMLSafe != 0
+	}
+
+	return false
+}
+
+func runeNeedsEscaping(r rune, flags EscapeFlags) bool {
+	switch {
+	case flags&EscapeASCIIOnly != 0:
+		return true
+	case r == '\u2028', r == '\u2029':
+		return flags&EscapeHTMLSafe != 0
+	}
+
+	return false
+}
+
+func appendUnicodeEscape(dst []byte, r rune) []byte {
+	if r > 0xffff {
+		r1, r2 := utf16.EncodeRune(r)
+		dst = appendUnicodeEscape(dst, r1)
+		return appendUnicodeEscape(dst, r2)
+	}
+
+	return jsonutil.AppendUintHex(append(dst, '\\', 'u'), uint64(r), 4)
+}
+
+func appendEscapedByte(dst []byte, c byte) []byte {
+	switch c {
+	case '"', '\\':
+		return append(dst, '\\', c)
+	case '\n':
+		return append(dst, '\\', 'n')
+	case '\r':
+		return append(dst, '\\', 'r')
+	case '\t':
+		return append(dst, '\\', 't')
+	case '\b':
+		return append(dst, '\\', 'b')
+	case '\f':
+		return append(dst, '\\', 'f')
+	}
+
+	return appendUnicodeEscape(dst, rune(c))
+}
+
+// AppendEscapedString escapes s according to JSON rules and appends the result (without
+// the surrounding quotes) to dst returning the extended buffer. Invalid UTF-8 sequences
+// are replaced with �.
+func AppendEscapedString(dst []byte, s string, flags EscapeFlags) []byte {
+	start := 0 // beginning of the pending chunk that does not need escaping
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			if byteNeedsEscaping(c, flags) {
+				dst = append(dst, s[start:i]...)
+				dst = appendEscapedByte(dst, c)
+				start = i + 1
+			}
+
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 || runeNeedsEscaping(r, flags) {
+			dst = append(dst, s[start:i]...)
+			dst = appendUnicodeEscape(dst, r)
+			start = i + size
+		}
+
+		i += size
+	}
+
+	return append(dst, s[start:]...)
+}
+
+// EscapeString returns s escaped according to JSON rules (without the surrounding quotes).
+// s itself is returned if it contains nothing to escape, so no allocation is done in the
+// most common case.
+func EscapeString(s string, flags EscapeFlags) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf || byteNeedsEscaping(s[i], flags) {
+			return string(AppendEscapedString(make([]byte, 0, len(s)+len(s)/8), s, flags))
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,66 @@
+package gojsonlex
+
+import (
+	"testing"
+)
+
+type escapeStringTestCase struct {
+	input  string
+	flags  EscapeFlags
+	output string
+}
+
+func TestEscapeString(t *testing.T) {
+	testcases := []escapeStringTestCase{
+		{"", 0, ""},
+		{"hello world", 0, "hello world"},
+		{"hello\nworld", 0, "hello\\nworld"},
+		{"\"hello\"\t\\world/", 0, "\\\"hello\\\"\\t\\\\world/"},
+		{"\b\f\r\x00\x1f", 0, "\\b\\f\\r\\u0000\\u001f"},
+		{"<a href=\"#\">&</a>", 0, "<a href=\\\"#\\\">&</a>"},
+		{"<a href=\"#\">&</a>", EscapeHTMLSafe, "\\u003ca href=\\\"#\\\"\\u003e\\u0026\\u003c/a\\u003e"},
+		{"line\u2028sep", 0, "line\u2028sep"},
+		{"line\u2028sep", EscapeHTMLSafe, "line\\u2028sep"},
+		{"привет", 0, "привет"},
+		{"привет", EscapeASCIIOnly, "\\u043f\\u0440\\u0438\\u0432\\u0435\\u0442"},
+		{"hello 💩 world", EscapeASCIIOnly, "hello \\ud83d\\udca9 world"},
+		{"<💩>", EscapeASCIIOnly | EscapeHTMLSafe, "\\u003c\\ud83d\\udca9\\u003e"},
+		{"bad \xff utf8", 0, "bad \\ufffd utf8"},
+	}
+
+	for _, testcase := range testcases {
+		currOut := EscapeString(testcase.input, testcase.flags)
+		if currOut != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, currOut, testcase.output)
+		}
+
+		currOutBytes := AppendEscapedString([]byte("prefix"), testcase.input, testcase.flags)
+		if string(currOutBytes) != "prefix"+testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, string(currOutBytes), "prefix"+testcase.output)
+		}
+	}
+}
+
+func TestEscapeStringRoundTrip(t *testing.T) {
+	testcases := []string{
+		"", "hello\nworld", "\"\\/\b\f\r\t", "\x00\x01\x1f", "<&>", "привет 💩 мир",
+	}
+
+	for _, testcase := range testcases {
+		for _, flags := range []EscapeFlags{0, EscapeHTMLSafe, EscapeASCIIOnly} {
+			escaped := EscapeString(testcase, flags)
+
+			unescaped, err := UnescapeBytes(nil, []byte(escaped))
+			if err != nil {
+				t.Errorf("testcase '%s': %v", testcase, err)
+				continue
+			}
+
+			if string(unescaped) != testcase {
+				t.Errorf("testcase '%s': got '%s' after round trip", testcase, string(unescaped))
+			}
+		}
+	}
+}
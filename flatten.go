@@ -0,0 +1,128 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FlatField is a scalar member of a flattened object
+type FlatField struct {
+	Key   string // path of the value inside the object in the form accepted by ParsePath
+	Value string
+}
+
+// Flattener reads top-level objects (e.g. NDJSON records) and flattens them the way
+// FlattenObjects does it, while also capturing the scalars at the given field paths like
+// RecordScanner
+type Flattener struct {
+	s        *RecordScanner
+	fields   []FlatField
+	isObject bool // reports whether the last record is an object
+	numBuf   []byte
+	err      error // the first error converting a value of the last record
+}
+
+// NewFlattener creates a Flattener reading objects from src, fields are relative to the
+// objects
+func NewFlattener(src io.Reader, fields ...Path) (*Flattener, error) {
+	s, err := NewRecordScanner(src, Path{}, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.SetPrecisionLossPolicy(PrecisionLossRaw)
+
+	f := &Flattener{s: s}
+	s.onToken = f.onToken
+
+	return f, nil
+}
+
+func (f *Flattener) onToken(t *TokenGeneric, role TokenRole) {
+	if role.StartsValue() && f.s.tr.valueDepth == 0 {
+		f.isObject = role == TokenRoleOpen && t.delim == '{'
+		return
+	}
+
+	if role != TokenRoleScalar || t.t == LexerTokenTypeNull {
+		return
+	}
+
+	var value string
+
+	switch t.t {
+	case LexerTokenTypeString:
+		value = StringDeepCopy(t.str)
+	case LexerTokenTypeNumber:
+		if t.lossy {
+			value = StringDeepCopy(t.str)
+			break
+		}
+
+		var err error
+		if f.numBuf, err = appendNumber(f.numBuf[:0], t.number); err != nil && f.err == nil {
+			f.err = err
+		}
+
+		value = string(f.numBuf)
+	case LexerTokenTypeBool:
+		value = strconv.FormatBool(t.boolean)
+	}
+
+	f.fields = append(f.fields, FlatField{Key: f.s.tr.Path(), Value: value})
+}
+
+// Next scans the next object, io.EOF is returned once the input has been exhausted. An
+// error is returned for top-level values other than objects.
+func (f *Flattener) Next() error {
+	f.fields = f.fields[:0]
+	f.err = nil
+
+	if err := f.s.Next(); err != nil {
+		return err
+	}
+
+	if !f.isObject {
+		return fmt.Errorf("record is not an object")
+	}
+
+	return f.err
+}
+
+// FlattenObjects reads top-level objects (e.g. NDJSON records) from src and calls fn for
+// every object with its scalar values as strings keyed by their paths inside the object,
+// e.g. {"user": {"tags": ["a"]}} becomes "user.tags.0" = "a". It is suitable for storage
+// in flat maps like Redis hashes. Nulls and empty containers are omitted, numbers are
+// formatted the same way as by TokenWriter. fields is valid until fn returns. Parsing stops
+// at the first error returned by fn, which is returned as is.
+func FlattenObjects(src io.Reader, fn func(fields []FlatField) error) error {
+	f, err := NewFlattener(src)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := f.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(f.Fields()); err != nil {
+			return err
+		}
+	}
+}
+
+// Fields returns the flattened scalars of the current object, they are valid until the
+// next call to Next
+func (f *Flattener) Fields() []FlatField {
+	return f.fields
+}
+
+// Field returns the scalar value of the i-th field of the current object, see
+// RecordScanner.Field
+func (f *Flattener) Field(i int) (value TokenGeneric, ok bool) {
+	return f.s.Field(i)
+}
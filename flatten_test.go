@@ -0,0 +1,55 @@
+package gojsonlex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type flattenObjectsTestCase struct {
+	input  string
+	output [][]FlatField
+}
+
+func TestFlattenObjects(t *testing.T) {
+	testcases := []flattenObjectsTestCase{
+		{
+			`{"id": 1, "user": {"name": "Bob", "tags": ["a", "b"]}, "admin": false, "email": null}`,
+			[][]FlatField{{
+				{"id", "1"}, {"user.name", "Bob"}, {"user.tags.0", "a"}, {"user.tags.1", "b"},
+				{"admin", "false"},
+			}},
+		},
+		{
+			`{"a.b": 1e21, "big": 12345678901234567890123, "empty": {}, "list": []}` + "\n" + `{}`,
+			[][]FlatField{
+				{{`a\.b`, "1e+21"}, {"big", "12345678901234567890123"}},
+				nil,
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		var output [][]FlatField
+
+		err := FlattenObjects(strings.NewReader(testcase.input), func(fields []FlatField) error {
+			output = append(output, append([]FlatField(nil), fields...))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(output, testcase.output) {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.output)
+		}
+	}
+
+	for _, input := range []string{`{"a": 1} [1]`, `"a"`, `{"a": 1`} {
+		err := FlattenObjects(strings.NewReader(input), func([]FlatField) error { return nil })
+		if err == nil {
+			t.Errorf("testcase '%s': must have failed", input)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package gojsonlex
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Framing defines how documents are delimited in the input stream
+type Framing byte
+
+const (
+	// FramingNone expects documents to be simply concatenated, optionally separated by
+	// whitespaces (e.g. NDJSON) (default)
+	FramingNone Framing = iota
+	// FramingRecordSeparator expects every document to be preceded by the ASCII Record
+	// Separator 0x1E as in JSON text sequences (RFC 7464)
+	FramingRecordSeparator
+	// FramingLengthPrefix expects every document to be preceded by its length in bytes
+	// encoded as a 4-byte big-endian unsigned integer
+	FramingLengthPrefix
+)
+
+// recordSeparator is the byte preceding every JSON text in RFC 7464 sequences
+const recordSeparator = 0x1E
+
+// framingReader removes framing from the input stream leaving documents separated by
+// newlines, so that they can be lexed as plain concatenated JSON
+type framingReader struct {
+	r       io.Reader
+	framing Framing
+
+	header    [4]byte
+	remaining int64 // bytes left in the current length-prefixed frame
+	inFrame   bool  // reports whether at least one frame has been started
+
+	produced int64   // number of bytes returned so far
+	ends     []int64 // offsets of the newlines terminating frames not checked by the lexer yet
+}
+
+func newFramingReader(r io.Reader, framing Framing) *framingReader {
+	return &framingReader{
+		r:       r,
+		framing: framing,
+	}
+}
+
+func (f *framingReader) Read(p []byte) (int, error) {
+	if f.framing == FramingRecordSeparator {
+		n, err := f.r.Read(p)
+
+		for i := 0; i < n; i++ {
+			if p[i] == recordSeparator {
+				p[i] = '\n'
+			}
+		}
+
+		return n, err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if f.remaining == 0 {
+		if f.inFrame {
+			// separates the finished document from the next one
+			f.inFrame = false
+			p[0] = '\n'
+
+			f.ends = append(f.ends, f.produced)
+			f.produced++
+
+			return 1, nil
+		}
+
+		n, err := io.ReadFull(f.r, f.header[:])
+		if err == io.ErrUnexpectedEOF {
+			return 0, fmt.Errorf("truncated frame header: got %d bytes", n)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		f.remaining = int64(binary.BigEndian.Uint32(f.header[:]))
+		f.inFrame = true
+	}
+
+	if int64(len(p)) > f.remaining {
+		p = p[:f.remaining]
+	}
+
+	n, err := f.r.Read(p)
+	f.remaining -= int64(n)
+	f.produced += int64(n)
+
+	if err == io.EOF && f.remaining > 0 {
+		return n, fmt.Errorf("truncated frame: %d bytes missing", f.remaining)
+	}
+	if err == io.EOF {
+		// EOF will be reported once the frame has been terminated
+		err = nil
+	}
+
+	return n, err
+}
+
+// SetFraming sets the framing of documents in the input stream, see Framing. Framing is
+// removed before lexing, so documents are lexed as if they were separated by newlines,
+// offsets reported by the lexer refer to the input with framing removed. With
+// FramingLengthPrefix every frame must contain exactly one complete value, otherwise
+// *PositionError is returned. The framing is kept by Reset. MUST be called before parsing
+// started.
+func (l *JSONLexer) SetFraming(framing Framing) {
+	if l.frames != nil {
+		l.r = l.frames.r
+	}
+
+	l.framing = framing
+	l.applyFraming()
+}
+
+// applyFraming wraps the reader of the lexer into framingReader if the framing requires
+func (l *JSONLexer) applyFraming() {
+	l.frames = nil
+
+	if l.framing != FramingNone {
+		l.frames = newFramingReader(l.r, l.framing)
+		l.r = l.frames
+	}
+}
+
+// checkFrame enforces the boundaries of length-prefixed frames on the token that has just
+// been found: it must not cross the end of the frame, and every frame must contain exactly
+// one complete value
+func (l *JSONLexer) checkFrame() error {
+	if l.frames == nil || l.framing != FramingLengthPrefix {
+		return nil
+	}
+
+	start, end := l.currTokenOffsets()
+
+	if err := l.passFrameEnds(start); err != nil {
+		return err
+	}
+
+	if ends := l.frames.ends; len(ends) > 0 && ends[0] < end {
+		return l.positionError(start, fmt.Errorf("token crosses the end of the frame"))
+	}
+
+	if l.depth == 0 && (l.currTokenType != LexerTokenTypeDelim || l.currDelim == '}' || l.currDelim == ']') {
+		if l.frameValues++; l.frameValues > 1 {
+			return l.positionError(start, fmt.Errorf("frame contains more than one value"))
+		}
+	}
+
+	return nil
+}
+
+// passFrameEnds checks that the frames ending before offset contain exactly one complete
+// value, violations are reported at offset
+func (l *JSONLexer) passFrameEnds(offset int64) error {
+	if l.frames == nil || l.framing != FramingLengthPrefix {
+		return nil
+	}
+
+	for len(l.frames.ends) > 0 && l.frames.ends[0] < offset {
+		if l.frameValues != 1 {
+			return l.positionError(offset, fmt.Errorf("frame ending at offset %d does not contain a complete value",
+				l.frames.ends[0]))
+		}
+
+		l.frameValues = 0
+		l.frames.ends = l.frames.ends[1:]
+	}
+
+	return nil
+}
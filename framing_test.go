@@ -0,0 +1,117 @@
+package gojsonlex
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func lengthPrefixed(docs ...string) string {
+	b := []byte{}
+
+	for _, doc := range docs {
+		var header [4]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(doc)))
+
+		b = append(b, header[:]...)
+		b = append(b, doc...)
+	}
+
+	return string(b)
+}
+
+type framingTestCase struct {
+	input   string
+	framing Framing
+	output  string
+	isErr   bool
+}
+
+func TestJSONLexerFraming(t *testing.T) {
+	testcases := []framingTestCase{
+		{"\x1e{\"a\": 1}\n\x1e[true]\n", FramingRecordSeparator, "{ a : 1 } [ true ]", false},
+		{"\x1e1\x1e2\x1e\"x\"", FramingRecordSeparator, "1 2 x", false},
+		{"", FramingRecordSeparator, "", false},
+		{lengthPrefixed(`{"a": 1}`, `[true]`), FramingLengthPrefix, "{ a : 1 } [ true ]", false},
+		{lengthPrefixed("1", "2", "3"), FramingLengthPrefix, "1 2 3", false},
+		{lengthPrefixed(" [1, 2] ", "null"), FramingLengthPrefix, "[ 1 , 2 ] <nil>", false},
+		{lengthPrefixed("1", "2", "", "3"), FramingLengthPrefix, "", true},
+		{lengthPrefixed("1 2 3"), FramingLengthPrefix, "", true},
+		{lengthPrefixed(`{"a": `, `"b"}`), FramingLengthPrefix, "", true},
+		{lengthPrefixed(`"ab`, `c"`), FramingLengthPrefix, "", true},
+		{lengthPrefixed(`[1, 2`, `]`), FramingLengthPrefix, "", true},
+		{lengthPrefixed(`12`, `34`), FramingLengthPrefix, "12 34", false},
+		{lengthPrefixed(`1`, ` `), FramingLengthPrefix, "", true},
+		{lengthPrefixed(`"x\n"`), FramingLengthPrefix, "x\n", false},
+		{"", FramingLengthPrefix, "", false},
+		{lengthPrefixed("12")[:5], FramingLengthPrefix, "", true},
+		{lengthPrefixed("12")[:3], FramingLengthPrefix, "", true},
+		{"1 2", FramingNone, "1 2", false},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(iotest.OneByteReader(strings.NewReader(testcase.input)))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetSkipDelims(false)
+		l.SetFraming(testcase.framing)
+
+		output, err := readPrintedTokens(l)
+		if testcase.isErr {
+			if err == nil {
+				t.Errorf("testcase %q: must have failed", testcase.input)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("testcase %q: %v", testcase.input, err)
+			continue
+		}
+
+		if strings.Join(output, " ") != testcase.output {
+			t.Errorf("testcase %q: got '%s', expected '%s'",
+				testcase.input, strings.Join(output, " "), testcase.output)
+		}
+	}
+}
+
+func TestJSONLexerFramingReset(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetFraming(FramingLengthPrefix)
+	l.SetFraming(FramingLengthPrefix) // must not remove the framing twice
+
+	testcases := []framingTestCase{
+		{lengthPrefixed("1", `"x"`), FramingLengthPrefix, "1 x", false},
+		{lengthPrefixed("[true]"), FramingLengthPrefix, "true", false},
+	}
+
+	for _, testcase := range testcases {
+		l.Reset(strings.NewReader(testcase.input))
+
+		output, err := readPrintedTokens(l)
+		if err != nil {
+			t.Errorf("testcase %q: %v", testcase.input, err)
+			continue
+		}
+
+		if strings.Join(output, " ") != testcase.output {
+			t.Errorf("testcase %q: got '%s', expected '%s'",
+				testcase.input, strings.Join(output, " "), testcase.output)
+		}
+	}
+
+	l.Reset(strings.NewReader(lengthPrefixed("1 2")))
+
+	if _, err := readPrintedTokens(l); err == nil {
+		t.Errorf("a frame with two values must have failed after Reset")
+	}
+}
@@ -0,0 +1,146 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+var fuzzSeeds = []string{
+	``,
+	`{"a": 1, "b": [true, null, "x\n"], "c": {"d": -1.5e3}}`,
+	`[1, 2,, ]}{`,
+	`"П💩\\"`,
+	`"\ud800"`,
+	`{"a" 1}`,
+	`tru nul 01 .5 +1 1e`,
+	"// comment\n[1] /* x */",
+	`'single'`,
+	"\"\x01\"",
+}
+
+// lexAll returns printed tokens of the input up to the first error and reports whether
+// the whole input has been lexed successfully
+func lexAll(l *JSONLexer) ([]string, bool) {
+	var tokens []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			return tokens, true
+		}
+		if err != nil {
+			return tokens, false
+		}
+
+		tokens = append(tokens, printToken(token))
+	}
+}
+
+// FuzzLexer checks that the lexer never panics, that Recover always makes progress and
+// that tokens do not depend on the size of the buffer
+func FuzzLexer(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		for _, dialect := range []Dialect{DialectLenient, DialectRFC8259, DialectJSON5} {
+			l, _ := NewJSONLexer(bytes.NewReader(input))
+			l.SetDialect(dialect)
+			l.SetSkipDelims(false)
+
+			expected, expectedOK := lexAll(l)
+
+			l, _ = NewJSONLexer(iotest.OneByteReader(bytes.NewReader(input)))
+			l.SetDialect(dialect)
+			l.SetSkipDelims(false)
+			l.SetBufSize(1)
+
+			got, ok := lexAll(l)
+			if ok != expectedOK || strings.Join(got, " ") != strings.Join(expected, " ") {
+				t.Fatalf("dialect %s: got %v (%t) with tiny buffer, expected %v (%t)",
+					dialect.Name, got, ok, expected, expectedOK)
+			}
+
+			// every error followed by Recover() consumes at least one byte
+			l, _ = NewJSONLexer(bytes.NewReader(input))
+			l.SetDialect(dialect)
+			l.SetPoisonStrings(true)
+
+			for calls := 0; ; calls++ {
+				if calls > 2*len(input)+2 {
+					t.Fatalf("dialect %s: Recover() does not make progress", dialect.Name)
+				}
+
+				token, err := l.TokenFast()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					l.Recover()
+					continue
+				}
+
+				if err := l.Validate(token); err != nil {
+					t.Fatalf("dialect %s: %v", dialect.Name, err)
+				}
+			}
+		}
+	})
+}
+
+// FuzzUnescape checks that all unescaping APIs agree with each other and that escaping
+// is reversible
+func FuzzUnescape(f *testing.F) {
+	for _, seed := range []string{`abc`, `\n\t\"\\\/`, `П💩`, `\ud800`, `\x`, `\u12`, `\`} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		expected, err := UnescapeBytes(nil, input)
+
+		inplace, inplaceErr := UnescapeBytesInplace(append([]byte(nil), input...))
+		if (err == nil) != (inplaceErr == nil) || err == nil && !bytes.Equal(inplace, expected) {
+			t.Fatalf("UnescapeBytesInplace: got %q (%v), expected %q (%v)", inplace, inplaceErr, expected, err)
+		}
+
+		streamed, streamErr := ioutil.ReadAll(NewUnescapingReader(iotest.OneByteReader(bytes.NewReader(input))))
+		if (err == nil) != (streamErr == nil) || err == nil && !bytes.Equal(streamed, expected) {
+			t.Fatalf("NewUnescapingReader: got %q (%v), expected %q (%v)", streamed, streamErr, expected, err)
+		}
+
+		if !utf8.Valid(input) {
+			return
+		}
+
+		s := string(input)
+		if unescaped, err := UnescapeBytes(nil, []byte(EscapeString(s, 0))); err != nil || string(unescaped) != s {
+			t.Fatalf("escaping is not reversible: got %q (%v), expected %q", unescaped, err, s)
+		}
+	})
+}
+
+// FuzzValidator checks that the lexer validating the structure in the RFC 8259 dialect
+// never accepts what encoding/json rejects
+func FuzzValidator(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		l, _ := NewJSONLexer(bytes.NewReader(input))
+		l.SetDialect(DialectRFC8259)
+		l.SetValidateStructure(true)
+		l.SetEmptyInputPolicy(EmptyInputError)
+
+		if _, ok := lexAll(l); ok && !json.Valid(input) {
+			t.Fatalf("input %q is accepted but encoding/json rejects it", input)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package gojsonlex
+
+import "io"
+
+// Get lexes JSON from r and returns the first value found at path (see ParsePath, e.g.
+// "cells.3.value") decoded the way JSONLexer.Decode does it. Lexing stops right after the
+// value, members and elements that can not lead to the path are skipped without
+// converting their tokens, so fields can be extracted from huge documents fast.
+// ErrNotFound is returned if there is no value at path.
+func Get(r io.Reader, path string) (interface{}, error) {
+	var value interface{}
+
+	found := false
+
+	err := getValues(r, ParsePath(path), func(v interface{}) bool {
+		value, found = v, true
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	return value, nil
+}
+
+// GetAll lexes JSON from r and returns all values found at path (e.g. "cells.*.value")
+// in the order of their appearance, see Get. An empty slice is returned if there are none.
+func GetAll(r io.Reader, path string) ([]interface{}, error) {
+	values := []interface{}{}
+
+	err := getValues(r, ParsePath(path), func(v interface{}) bool {
+		values = append(values, v)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// getValues passes values found at path to fn until it returns false
+func getValues(r io.Reader, path Path, fn func(v interface{}) bool) error {
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		return err
+	}
+
+	l.SetSkipDelims(false)
+	l.SetPathFilter(path)
+
+	for {
+		t, err := l.TokenFast()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// only keys of matching members precede values, the rest is consumed by decodeValue
+		if t.key {
+			continue
+		}
+
+		value, err := decodeValue(l, &t, false)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+
+		if !fn(value) {
+			return nil
+		}
+	}
+}
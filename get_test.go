@@ -0,0 +1,76 @@
+package gojsonlex
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type getTestCase struct {
+	input  string
+	path   string
+	output []interface{} // values returned by GetAll, Get returns the first one
+}
+
+func TestGet(t *testing.T) {
+	input := `{"skip": {"cells": [1]}, "cells": [{"value": 1}, {"name": "ip", "value": "5.61.233.11"},` +
+		` {"value": null}, {"value": {"a": [true]}}], "count": 4}`
+
+	testcases := []getTestCase{
+		{input, "cells.1.value", []interface{}{"5.61.233.11"}},
+		{input, "cells.3.value", []interface{}{map[string]interface{}{"a": []interface{}{true}}}},
+		{input, "cells.*.value", []interface{}{float64(1), "5.61.233.11", nil,
+			map[string]interface{}{"a": []interface{}{true}}}},
+		{input, "count", []interface{}{float64(4)}},
+		{input, "skip.cells.0", []interface{}{float64(1)}},
+		{input, "cells.4.value", []interface{}{}},
+		{input, "missing", []interface{}{}},
+		{`1 "a"`, "", []interface{}{float64(1), "a"}},
+		{`{"a": 1} {"a": 2}`, "a", []interface{}{float64(1), float64(2)}},
+		{`[[1, 2], [3, 4]]`, "*.1", []interface{}{float64(2), float64(4)}},
+	}
+
+	for _, testcase := range testcases {
+		values, err := GetAll(strings.NewReader(testcase.input), testcase.path)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.path, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(values, testcase.output) {
+			t.Errorf("testcase '%s': got %#v, expected %#v", testcase.path, values, testcase.output)
+		}
+
+		value, err := Get(strings.NewReader(testcase.input), testcase.path)
+		if len(testcase.output) == 0 {
+			if err != ErrNotFound {
+				t.Errorf("testcase '%s': got %v, expected ErrNotFound", testcase.path, err)
+			}
+
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(value, testcase.output[0]) {
+			t.Errorf("testcase '%s': got %#v (%v), expected %#v", testcase.path, value, err,
+				testcase.output[0])
+		}
+	}
+}
+
+func TestGetStopsEarly(t *testing.T) {
+	// the input is malformed right after the value
+	value, err := Get(strings.NewReader(`{"a": {"b": [1, 2]}, "c": x`), "a.b")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if expected := []interface{}{float64(1), float64(2)}; !reflect.DeepEqual(value, expected) {
+		t.Errorf("got %#v, expected %#v", value, expected)
+	}
+
+	if _, err := Get(strings.NewReader(`{"a": {"b": [1, 2`), "a.b"); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, expected unexpected EOF", err)
+	}
+}
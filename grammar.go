@@ -0,0 +1,118 @@
+package gojsonlex
+
+import (
+	"fmt"
+)
+
+type grammarState byte
+
+const (
+	grammarValue        grammarState = iota // a value is expected
+	grammarValueOrClose                     // a value or ']' is expected right after '['
+	grammarKeyOrClose                       // a key or '}' is expected right after '{'
+	grammarKey                              // a key is expected after ','
+	grammarColon                            // ':' is expected after a key
+	grammarCommaOrClose                     // ',' or the closing delimiter is expected
+	grammarEnd                              // the top-level value has been finished
+)
+
+var grammarExpectations = [...]string{
+	grammarValue:        "a value",
+	grammarValueOrClose: "a value or ']'",
+	grammarKeyOrClose:   "a key or '}'",
+	grammarKey:          "a key",
+	grammarColon:        "':'",
+	grammarCommaOrClose: "',' or the end of the container",
+	grammarEnd:          "the end of input",
+}
+
+// grammarChecker validates the structure of a single JSON value token by token
+type grammarChecker struct {
+	state    grammarState
+	isObject []bool // kinds of open containers
+}
+
+func (g *grammarChecker) unexpected(what string) error {
+	return fmt.Errorf("unexpected %s, expected %s", what, grammarExpectations[g.state])
+}
+
+// endValue updates the state after a value has been finished
+func (g *grammarChecker) endValue() {
+	if len(g.isObject) == 0 {
+		g.state = grammarEnd
+	} else {
+		g.state = grammarCommaOrClose
+	}
+}
+
+func (g *grammarChecker) feedDelim(c byte) error {
+	switch c {
+	case '{', '[':
+		if g.state != grammarValue && g.state != grammarValueOrClose {
+			return g.unexpected(fmt.Sprintf("'%c'", c))
+		}
+
+		g.isObject = append(g.isObject, c == '{')
+
+		if c == '{' {
+			g.state = grammarKeyOrClose
+		} else {
+			g.state = grammarValueOrClose
+		}
+	case '}', ']':
+		n := len(g.isObject)
+
+		switch {
+		case n == 0 || g.isObject[n-1] != (c == '}'):
+			return g.unexpected(fmt.Sprintf("'%c'", c))
+		case g.state == grammarCommaOrClose,
+			c == '}' && g.state == grammarKeyOrClose,
+			c == ']' && g.state == grammarValueOrClose:
+		default:
+			return g.unexpected(fmt.Sprintf("'%c'", c))
+		}
+
+		g.isObject = g.isObject[:n-1]
+		g.endValue()
+	case ':':
+		if g.state != grammarColon {
+			return g.unexpected("':'")
+		}
+
+		g.state = grammarValue
+	case ',':
+		if g.state != grammarCommaOrClose {
+			return g.unexpected("','")
+		}
+
+		if g.isObject[len(g.isObject)-1] {
+			g.state = grammarKey
+		} else {
+			g.state = grammarValue
+		}
+	}
+
+	return nil
+}
+
+// feed validates the next token of the value
+func (g *grammarChecker) feed(t TokenType, delim byte) error {
+	if t == LexerTokenTypeDelim {
+		return g.feedDelim(delim)
+	}
+
+	switch g.state {
+	case grammarKeyOrClose, grammarKey:
+		if t != LexerTokenTypeString {
+			return g.unexpected(t.String())
+		}
+
+		g.state = grammarColon
+	case grammarValue, grammarValueOrClose:
+		g.endValue()
+	default:
+		return g.unexpected(t.String())
+	}
+
+	return nil
+}
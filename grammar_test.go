@@ -0,0 +1,88 @@
+package gojsonlex
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type validateStructureTestCase struct {
+	input string
+	valid bool
+}
+
+func TestJSONLexerValidateStructure(t *testing.T) {
+	testcases := []validateStructureTestCase{
+		{`{"a": 1, "b": [true, null, {}, []], "c": {"d": "e"}}`, true},
+		{`[]`, true},
+		{` "s" `, true},
+		{`12`, true},
+		{`}{]]`, false},
+		{`{"a": 1]`, false},
+		{`[1}`, false},
+		{`{"a" 1}`, false},
+		{`{"a": 1 "b": 2}`, false},
+		{`{1: 2}`, false},
+		{`{"a": 1,}`, false},
+		{`[1,]`, false},
+		{`[,1]`, false},
+		{`[1 2]`, false},
+		{`{"a": 1}:`, false},
+		{`{"a"}`, false},
+		{`{"a":}`, false},
+		{`{} {}`, false},
+		{`1 2`, false},
+		{`[1] 2`, false},
+		{`{"a": 1`, false},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.SetValidateStructure(true)
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+		}
+
+		switch {
+		case testcase.valid && err != io.EOF:
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+		case !testcase.valid && err == io.EOF:
+			t.Errorf("testcase '%s': must have failed", testcase.input)
+		}
+	}
+}
+
+func TestJSONLexerValidateStructurePosition(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader("{\n  \"a\": 1,\n  \"b\" 2\n}"))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetValidateStructure(true)
+
+	for {
+		if _, err = l.TokenFast(); err != nil {
+			break
+		}
+	}
+
+	var posErr *PositionError
+	if !errors.As(err, &posErr) {
+		t.Fatalf("got %v, expected *PositionError", err)
+	}
+
+	if posErr.Line != 3 || posErr.Column != 7 {
+		t.Errorf("got %d:%d, expected 3:7", posErr.Line, posErr.Column)
+	}
+}
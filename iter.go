@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package gojsonlex
+
+import (
+	"io"
+	"iter"
+)
+
+// Tokens returns an iterator over the remaining tokens of the input for use with
+// range-over-func loops: for tok, err := range l.Tokens() { ... }. Tokens are the ones
+// returned by TokenFast and are valid under the same conditions. Iteration ends at the
+// end of input, an error is yielded with a zero token and ends the iteration as well,
+// after Recover (or ErrBudgetExceeded) the iteration can be resumed by ranging over
+// Tokens once again.
+func (l *JSONLexer) Tokens() iter.Seq2[TokenGeneric, error] {
+	return func(yield func(TokenGeneric, error) bool) {
+		for {
+			t, err := l.TokenFast()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(TokenGeneric{}, err)
+				return
+			}
+
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build go1.23
+
+package gojsonlex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLexerTokens(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(`{"a": [1, "b"], "c": 1x, "d": null}`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	var output []string
+
+	for tok, err := range l.Tokens() {
+		if err != nil {
+			output = append(output, "!")
+			continue
+		}
+
+		output = append(output, printToken(tok))
+	}
+
+	l.Recover()
+
+	for tok, err := range l.Tokens() {
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if output = append(output, printToken(tok)); tok.IsKey() {
+			break
+		}
+	}
+
+	expected := []string{"a", "1", "b", "c", "!", "d"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	if tok, err := l.TokenFast(); err != nil || !tok.IsNull() {
+		t.Errorf("got '%s' (%v) after breaking the loop, expected null", printToken(tok), err)
+	}
+}
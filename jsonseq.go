@@ -0,0 +1,150 @@
+package gojsonlex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// seqTextReader reads a single JSON text of a sequence, it reports io.EOF at the next
+// Record Separator without consuming it
+type seqTextReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+func (s *seqTextReader) Read(p []byte) (int, error) {
+	if s.done || len(p) == 0 {
+		return 0, io.EOF
+	}
+
+	if s.r.Buffered() == 0 {
+		if _, err := s.r.Peek(1); err != nil {
+			return 0, err
+		}
+	}
+
+	data, _ := s.r.Peek(s.r.Buffered())
+	if i := bytes.IndexByte(data, recordSeparator); i >= 0 {
+		data = data[:i]
+		s.done = len(data) <= len(p)
+	}
+
+	n := copy(p, data)
+	s.r.Discard(n)
+
+	if n == 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// JSONSeqReader reads a JSON text sequence (RFC 7464, application/json-seq) where every
+// JSON text is preceded by the ASCII Record Separator 0x1E. Texts are lexed one by one
+// with the same JSONLexer, a malformed text does not affect the following ones: once
+// TokenFast has failed parsing resumes from the next text after Next is called.
+type JSONSeqReader struct {
+	r    *bufio.Reader
+	text seqTextReader
+	l    *JSONLexer
+
+	err error // sticky error of the current text
+}
+
+// NewJSONSeqReader creates a new JSONSeqReader reading from r
+func NewJSONSeqReader(r io.Reader) (*JSONSeqReader, error) {
+	br := bufio.NewReaderSize(r, defaultBufSize)
+
+	l, err := NewJSONLexer(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONSeqReader{
+		r:    br,
+		text: seqTextReader{r: br, done: true},
+		l:    l,
+	}, nil
+}
+
+// Lexer returns the lexer used for all texts, it can be tuned with setters before the
+// first Next call, the settings are preserved between texts
+func (s *JSONSeqReader) Lexer() *JSONLexer {
+	return s.l
+}
+
+// Next skips the rest of the current text and advances to the next non-empty one,
+// io.EOF is returned once the input has been exhausted. Any data preceding the first
+// Record Separator is skipped.
+func (s *JSONSeqReader) Next() error {
+	if s.r == nil {
+		return ErrClosed
+	}
+
+	// skipping the rest of the current text or the data before the first separator
+	s.text.done = false
+	if _, err := io.Copy(ioutil.Discard, &s.text); err != nil {
+		return err
+	}
+
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case c == recordSeparator:
+			continue // start of a text, it may still be empty
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		}
+
+		s.r.UnreadByte()
+
+		break
+	}
+
+	s.text.done = false
+	s.err = nil
+	s.l.reset(&s.text)
+
+	return nil
+}
+
+// Close releases the memory held by the reader and its lexer, all subsequent calls return
+// ErrClosed. Close does not close the underlying reader.
+func (s *JSONSeqReader) Close() error {
+	s.r = nil
+	s.text = seqTextReader{done: true}
+	s.err = ErrClosed
+
+	return s.l.Close()
+}
+
+// TokenFast returns the next token of the current text, io.EOF is returned at the end of
+// the text. Besides errors of JSONLexer an error is returned for a text ending with
+// a number, true, false or null not followed by whitespace, since such a text might have
+// been truncated. Errors are sticky until the next Next call.
+func (s *JSONSeqReader) TokenFast() (TokenGeneric, error) {
+	if s.err != nil {
+		return TokenGeneric{}, s.err
+	}
+
+	t, err := s.l.TokenFast()
+	if err != nil && err != io.EOF {
+		s.err = err
+		return t, err
+	}
+
+	if err == nil && s.l.depth == 0 && t.t != LexerTokenTypeString && t.t != LexerTokenTypeDelim &&
+		s.l.readingFinished && s.l.currTokenEnd == len(s.l.buf) {
+		s.err = fmt.Errorf("possibly truncated JSON text: no whitespace after the last token")
+		return TokenGeneric{}, s.err
+	}
+
+	return t, err
+}
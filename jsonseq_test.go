@@ -0,0 +1,111 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestJSONSeqReader(t *testing.T) {
+	input := "garbage\x1e{\"a\": [1, 2]}\n\x1e[1,\n\x1e2\n\x1etrue\x1e \n\x1e\x1e\"x\"\x1enull \x1e{\"b\": nul}\n"
+	expected := []string{
+		"{ a : [ 1 , 2 ] }",
+		"[ 1 , error",
+		"2",
+		"error",
+		"x",
+		"<nil>",
+		"{ b : error",
+	}
+
+	for _, bufSize := range []int{1, 4, 4096} {
+		r, err := NewJSONSeqReader(iotest.HalfReader(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("could not create reader: %v", err)
+		}
+
+		r.Lexer().SetBufSize(bufSize)
+		r.Lexer().SetSkipDelims(false)
+
+		var output []string
+
+		for {
+			if err := r.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("bufSize %d: %v", bufSize, err)
+			}
+
+			var text []string
+
+			for {
+				token, err := r.TokenFast()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					text = append(text, "error")
+					break
+				}
+
+				text = append(text, printToken(token))
+			}
+
+			output = append(output, strings.Join(text, " "))
+		}
+
+		if strings.Join(output, "|") != strings.Join(expected, "|") {
+			t.Errorf("bufSize %d: got %q, expected %q", bufSize, output, expected)
+		}
+	}
+}
+
+func TestTokenWriterJSONSeq(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	w := NewTokenWriter(out)
+	w.SetJSONSeq(true)
+
+	tokens := []TokenGeneric{
+		NewTokenGenericFromDelim('{'),
+		NewTokenGenericFromString("a"),
+		NewTokenGenericFromNumber(1),
+		NewTokenGenericFromDelim('}'),
+		NewTokenGenericFromBool(true),
+	}
+
+	if err := w.WriteTokens(tokens); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if out.String() != "\x1e{\"a\":1}\n\x1etrue\n" {
+		t.Errorf("got %q, expected %q", out.String(), "\x1e{\"a\":1}\n\x1etrue\n")
+	}
+}
+
+func TestJSONSeqReaderClose(t *testing.T) {
+	s, err := NewJSONSeqReader(strings.NewReader("\x1e{\"a\": 1}\n\x1e[2]\n"))
+	if err != nil {
+		t.Fatalf("could not create reader: %v", err)
+	}
+
+	if err := s.Next(); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	s.Close()
+
+	if _, err := s.TokenFast(); err != ErrClosed {
+		t.Errorf("TokenFast: got %v, expected ErrClosed", err)
+	}
+
+	if err := s.Next(); err != ErrClosed {
+		t.Errorf("Next: got %v, expected ErrClosed", err)
+	}
+}
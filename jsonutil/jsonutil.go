@@ -0,0 +1,281 @@
+// Package jsonutil contains small helpers for classifying and converting the characters
+// JSON is made of. gojsonlex uses them internally, they are exposed for tools built on top
+// of it.
+package jsonutil
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// IsDelim reports whether the given rune is a JSON delimiter
+func IsDelim(c rune) bool {
+	switch c {
+	case '{', '}', '[', ']', ':', ',':
+		return true
+	}
+
+	return false
+}
+
+// IsValidEscapedSymbol reports whether the given rune is one of the special symbols
+// permitted in JSON
+func IsValidEscapedSymbol(c rune) bool {
+	switch c {
+	case 'n', 'r', 't', 'b', 'f', '\\', '/', '"', 'u', 'U':
+		return true
+	}
+
+	return false
+}
+
+// IsHexDigit reports whether the given rune is a valid hex digit
+func IsHexDigit(c rune) bool {
+	switch {
+	case unicode.IsDigit(c):
+		fallthrough
+	case 'a' <= c && c <= 'f':
+		fallthrough
+	case 'A' <= c && c <= 'F':
+		return true
+	}
+
+	return false
+}
+
+// CanAppearInNumber reports whether the given rune can appear in a JSON number
+func CanAppearInNumber(c rune) bool {
+	switch {
+	case unicode.IsDigit(c):
+		fallthrough
+	case c == '-', c == '+':
+		fallthrough
+	case c == '.':
+		fallthrough
+	case c == 'e', c == 'E':
+		return true
+	}
+
+	return false
+}
+
+// IsValidNumber reports whether s is a number conforming to the JSON grammar
+func IsValidNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	digitsStart := i
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+
+	intLen := i - digitsStart
+	if intLen == 0 || intLen > 1 && s[digitsStart] == '0' {
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+
+		fracStart := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+
+		if i == fracStart {
+			return false
+		}
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+
+		if i < len(s) && (s[i] == '-' || s[i] == '+') {
+			i++
+		}
+
+		expStart := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+
+		if i == expStart {
+			return false
+		}
+	}
+
+	return i == len(s)
+}
+
+// HexDigitValue returns the value of the given ASCII hex digit, ok is false if c is not
+// a hex digit
+func HexDigitValue(c byte) (value byte, ok bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+// HexBytesToUint parses the given hex number (without any prefix)
+func HexBytesToUint(in []byte) (result uint64, err error) {
+	for _, c := range in {
+		result *= 0x10
+
+		v, ok := HexDigitValue(c)
+		if !ok {
+			return 0, fmt.Errorf("'%s' is not a hex number", string(in))
+		}
+
+		result += uint64(v)
+	}
+
+	return result, nil
+}
+
+// AppendUintHex appends v as a lowercase hex number (without any prefix) padded with
+// leading zeros to at least width digits to dst returning the extended buffer
+// (e.g. 0xab with width 4 is appended as "00ab" like in \\uXXXX escape sequences)
+func AppendUintHex(dst []byte, v uint64, width int) []byte {
+	digits := 1
+	for x := v >> 4; x != 0; x >>= 4 {
+		digits++
+	}
+
+	if digits < width {
+		digits = width
+	}
+
+	for i := digits - 1; i >= 0; i-- {
+		dst = append(dst, hexDigits[v>>(4*uint(i))&0xf])
+	}
+
+	return dst
+}
+
+// AppendNumber appends f formatted the way encoding/json does it to dst returning the
+// extended buffer, infinities and NaN can not be represented in JSON and cause an error
+func AppendNumber(dst []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, fmt.Errorf("%v can not be represented in JSON", f)
+	}
+
+	// the same format as in encoding/json
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+// AppendSignificantDigits appends significant digits of the mantissa of the given number
+// (without leading and trailing zeros) to dst returning the extended buffer
+func AppendSignificantDigits(dst []byte, number string) []byte {
+	start := len(dst)
+
+	for i := 0; i < len(number); i++ {
+		c := number[i]
+
+		if c == 'e' || c == 'E' {
+			break
+		}
+		if c < '0' || c > '9' || c == '0' && len(dst) == start {
+			continue
+		}
+
+		dst = append(dst, c)
+	}
+
+	for len(dst) > start && dst[len(dst)-1] == '0' {
+		dst = dst[:len(dst)-1]
+	}
+
+	return dst
+}
+
+// maxCanonicalExponent bounds exponents of canonical numbers, larger exponents are
+// saturated
+const maxCanonicalExponent = 1e8
+
+// AppendCanonicalNumber appends the canonical form of the valid JSON number to dst: the
+// sign, the significant digits and the exponent of the decimal point placed before them.
+// Equal numbers have equal forms regardless of their formatting, e.g. 100, 1e2 and
+// 100.0e0 all become "1e3". Both 0 and -0 become "0".
+func AppendCanonicalNumber(dst []byte, number string) []byte {
+	start := len(dst)
+
+	if number[0] == '-' {
+		dst = append(dst, '-')
+		number = number[1:]
+	}
+
+	mantissa, exp := number, 0
+
+	if i := strings.IndexAny(number, "eE"); i >= 0 {
+		mantissa = number[:i]
+
+		expStr, negative := number[i+1:], false
+		if expStr[0] == '-' || expStr[0] == '+' {
+			negative = expStr[0] == '-'
+			expStr = expStr[1:]
+		}
+
+		for j := 0; j < len(expStr) && exp < maxCanonicalExponent; j++ {
+			exp = exp*10 + int(expStr[j]-'0')
+		}
+
+		if exp > maxCanonicalExponent {
+			exp = maxCanonicalExponent
+		}
+
+		if negative {
+			exp = -exp
+		}
+	}
+
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+
+	if intPart = strings.TrimLeft(intPart, "0"); intPart != "" {
+		exp += len(intPart)
+	} else {
+		exp -= len(fracPart) - len(strings.TrimLeft(fracPart, "0"))
+	}
+
+	digitsStart := len(dst)
+
+	dst = AppendSignificantDigits(dst, mantissa)
+	if len(dst) == digitsStart {
+		return append(dst[:start], '0')
+	}
+
+	dst = append(dst, 'e')
+
+	return strconv.AppendInt(dst, int64(exp), 10)
+}
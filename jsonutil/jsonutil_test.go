@@ -0,0 +1,99 @@
+package jsonutil
+
+import (
+	"testing"
+)
+
+type isValidNumberTestCase struct {
+	input  string
+	output bool
+}
+
+func TestIsValidNumber(t *testing.T) {
+	testcases := []isValidNumberTestCase{
+		{"0", true},
+		{"-0", true},
+		{"42", true},
+		{"-4.25", true},
+		{"1e10", true},
+		{"1.5E-10", true},
+		{"0.5e+1", true},
+		{"", false},
+		{"-", false},
+		{"+1", false},
+		{"01", false},
+		{".5", false},
+		{"5.", false},
+		{"1e", false},
+		{"1e+", false},
+		{"NaN", false},
+		{"Inf", false},
+		{"0x10", false},
+		{"1 ", false},
+	}
+
+	for _, testcase := range testcases {
+		currOut := IsValidNumber(testcase.input)
+
+		if testcase.output != currOut {
+			t.Errorf("testcase '%s': got '%t', expected '%t'",
+				testcase.input, currOut, testcase.output)
+		}
+	}
+}
+
+type appendUintHexTestCase struct {
+	input  uint64
+	width  int
+	output string
+}
+
+func TestAppendUintHex(t *testing.T) {
+	testcases := []appendUintHexTestCase{
+		{0, 0, "0"},
+		{0, 4, "0000"},
+		{0xab, 4, "00ab"},
+		{0x1f600, 4, "1f600"},
+		{0xffffffffffffffff, 0, "ffffffffffffffff"},
+		{0x10, 20, "00000000000000000010"},
+	}
+
+	for _, testcase := range testcases {
+		currOut := string(AppendUintHex([]byte("x"), testcase.input, testcase.width))
+
+		if currOut != "x"+testcase.output {
+			t.Errorf("testcase '%x': got '%s', expected 'x%s'",
+				testcase.input, currOut, testcase.output)
+		}
+
+		parsed, err := HexBytesToUint([]byte(currOut[1:]))
+		if err != nil || parsed != testcase.input {
+			t.Errorf("testcase '%x': parsed back as '%x' (%v)", testcase.input, parsed, err)
+		}
+	}
+}
+
+func TestAppendCanonicalNumber(t *testing.T) {
+	testcases := []struct {
+		input  string
+		output string
+	}{
+		{"0", "0"},
+		{"-0.000e10", "0"},
+		{"1", "1e1"},
+		{"1.0", "1e1"},
+		{"100", "1e3"},
+		{"1e2", "1e3"},
+		{"0.00123e+1", "123e-1"},
+		{"-12.5E-3", "-125e-1"},
+		{"12345678901234567890", "1234567890123456789e20"},
+		{"1e99999999999999999999", "1e100000001"},
+		{"1e99999999999999999998", "1e100000001"},
+	}
+
+	for _, testcase := range testcases {
+		if got := string(AppendCanonicalNumber(nil, testcase.input)); got != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'", testcase.input, got, testcase.output)
+		}
+	}
+}
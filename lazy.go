@@ -0,0 +1,139 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+)
+
+// lazyState is the state of the token recognized by NextTokenType
+type lazyState byte
+
+const (
+	lazyNone      lazyState = iota // no token has been recognized
+	lazyFound                      // the token has been recognized but not converted
+	lazyConverted                  // the token has been converted, see lazyToken
+)
+
+// NextTokenType recognizes the next token and returns its type without converting the
+// token: strings are not unescaped, numbers and bools are not parsed. The token can then
+// be converted on demand with AsString, AsNumber, AsBool or AsToken, tokens of no
+// interest are simply passed over by the next NextTokenType call, so consumers skipping
+// most of the values do not pay for the conversion. Numbers are reported as
+// LexerTokenTypeNumber even with SetIntegers. Filters apply the same way they do to
+// TokenFast. StopWhen and SetValueClasses apply only to converted tokens.
+func (l *JSONLexer) NextTokenType() (TokenType, error) {
+	if l.peeked {
+		// the peeked token has already been converted
+		l.peeked = false
+		l.lazyToken, l.lazyState = l.peekedToken, lazyConverted
+
+		if l.peekedErr != nil {
+			l.lazyState = lazyNone
+			return 0, l.peekedErr
+		}
+
+		if l.lazyToken.t == LexerTokenTypeInt {
+			return LexerTokenTypeNumber, nil
+		}
+
+		return l.lazyToken.t, nil
+	}
+
+	l.startEpoch()
+
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	if err := l.findToken(true); err != nil {
+		if err != io.EOF && err != ErrBudgetExceeded {
+			l.err = err
+		}
+
+		return 0, err
+	}
+
+	l.lazyState = lazyFound
+
+	return l.currTokenType, nil
+}
+
+// recognizedToken converts the token recognized by NextTokenType (once)
+func (l *JSONLexer) recognizedToken() (*TokenGeneric, error) {
+	switch l.lazyState {
+	case lazyNone:
+		return nil, fmt.Errorf("no token has been recognized by NextTokenType")
+	case lazyConverted:
+		return &l.lazyToken, nil
+	}
+
+	t, err := l.currToken()
+	if err != nil {
+		start, _ := l.currTokenOffsets()
+		err = l.positionError(start, err)
+	}
+
+	t, err = l.emit(t, err)
+	if err != nil {
+		l.lazyState = lazyNone
+		return nil, err
+	}
+
+	l.lazyToken, l.lazyState = t, lazyConverted
+
+	return &l.lazyToken, nil
+}
+
+// AsToken converts the token recognized by NextTokenType, strings are valid under the
+// same conditions as the ones of tokens returned by TokenFast
+func (l *JSONLexer) AsToken() (TokenGeneric, error) {
+	t, err := l.recognizedToken()
+	if err != nil {
+		return TokenGeneric{}, err
+	}
+
+	return *t, nil
+}
+
+// AsString returns the value of the string token recognized by NextTokenType, the string
+// is valid under the same conditions as the ones of tokens returned by TokenFast
+func (l *JSONLexer) AsString() (string, error) {
+	t, err := l.recognizedToken()
+	if err != nil {
+		return "", err
+	}
+
+	if t.t != LexerTokenTypeString {
+		return "", fmt.Errorf("%s token is not a string", t.t)
+	}
+
+	return t.str, nil
+}
+
+// AsNumber returns the value of the number token recognized by NextTokenType
+func (l *JSONLexer) AsNumber() (float64, error) {
+	t, err := l.recognizedToken()
+	if err != nil {
+		return 0, err
+	}
+
+	if t.t != LexerTokenTypeNumber && t.t != LexerTokenTypeInt {
+		return 0, fmt.Errorf("%s token is not a number", t.t)
+	}
+
+	return t.Number(), nil
+}
+
+// AsBool returns the value of the bool token recognized by NextTokenType
+func (l *JSONLexer) AsBool() (bool, error) {
+	t, err := l.recognizedToken()
+	if err != nil {
+		return false, err
+	}
+
+	if t.t != LexerTokenTypeBool {
+		return false, fmt.Errorf("%s token is not a bool", t.t)
+	}
+
+	return t.boolean, nil
+}
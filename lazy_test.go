@@ -0,0 +1,122 @@
+package gojsonlex
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLexerNextTokenType(t *testing.T) {
+	input := `[{"name": "a\"b", "price": 1.5, "id": 12345678901234567890, "ok": true},` +
+		` {"name": "c", "price": 2, "id": 98765432109876543210, "ok": false}]`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	// ids are never converted, so they can not fail
+	l.SetPrecisionLossPolicy(PrecisionLossError)
+
+	var (
+		names []string
+		sum   float64
+		oks   []bool
+	)
+
+	for {
+		typ, err := l.NextTokenType()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if typ != LexerTokenTypeString {
+			continue
+		}
+
+		key, err := l.AsString()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		switch key {
+		case "name":
+			l.NextTokenType()
+
+			name, _ := l.AsString()
+			if again, _ := l.AsString(); again != name {
+				t.Errorf("got '%s' converting '%s' once again", again, name)
+			}
+
+			names = append(names, name)
+		case "price":
+			l.NextTokenType()
+
+			price, err := l.AsNumber()
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			sum += price
+		case "ok":
+			l.NextTokenType()
+
+			if _, err := l.AsString(); err == nil {
+				t.Errorf("a bool must not be converted to a string")
+			}
+
+			ok, err := l.AsBool()
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			oks = append(oks, ok)
+		}
+	}
+
+	if expected := []string{`a"b`, "c"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("got names %v, expected %v", names, expected)
+	}
+	if sum != 3.5 {
+		t.Errorf("got sum %v, expected 3.5", sum)
+	}
+	if expected := []bool{true, false}; !reflect.DeepEqual(oks, expected) {
+		t.Errorf("got %v, expected %v", oks, expected)
+	}
+
+	if _, err := l.AsToken(); err == nil {
+		t.Errorf("no token must have been recognized at the end of input")
+	}
+}
+
+func TestJSONLexerNextTokenTypePeeked(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(`["a", 1]`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	if _, err := l.AsToken(); err == nil {
+		t.Errorf("no token must have been recognized yet")
+	}
+
+	l.PeekFast()
+
+	if typ, err := l.NextTokenType(); typ != LexerTokenTypeString || err != nil {
+		t.Fatalf("got %s (%v), expected string", typ, err)
+	}
+	if s, err := l.AsString(); s != "a" || err != nil {
+		t.Errorf("got '%s' (%v), expected 'a'", s, err)
+	}
+
+	if typ, _ := l.NextTokenType(); typ != LexerTokenTypeNumber {
+		t.Errorf("got %s, expected number", typ)
+	}
+
+	if token, err := l.TokenFast(); err != io.EOF {
+		t.Errorf("got '%s' (%v), expected EOF", printToken(token), err)
+	}
+}
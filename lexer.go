@@ -6,11 +6,16 @@ import (
 	"io"
 	"log"
 	"strconv"
+	"strings"
 	"unicode"
+
+	"github.com/gibsn/gojsonlex/jsonutil"
 )
 
 const (
 	defaultBufSize = 4096
+
+	maxInt = int64(^uint(0) >> 1) // max int, which is 32-bit on 32-bit platforms
 )
 
 type lexerState byte
@@ -24,6 +29,56 @@ const (
 	stateLexerNumber
 	stateLexerBool
 	stateLexerNull
+	stateLexerCommentStart    // after '/' opening a comment
+	stateLexerLineComment     // inside '//' comment
+	stateLexerBlockComment    // inside '/* */' comment
+	stateLexerBlockCommentEnd // after '*' inside '/* */' comment
+	stateLexerEmptyString     // after '""', which may open a raw string
+	stateLexerRawString       // inside a raw string
+)
+
+// numberState is a sub-state of stateLexerNumber describing which part of a number
+// is being parsed at the moment
+type numberState byte
+
+const (
+	stateNumberSign       numberState = iota // after leading '-' or '+'
+	stateNumberLeadingDot                    // after '.' with no integer part
+	stateNumberZero                          // after leading '0' of integer part
+	stateNumberInt                           // inside integer part
+	stateNumberDot                           // after '.' that follows the integer part
+	stateNumberFrac                          // inside fractional part
+	stateNumberExpStart                      // after 'e' or 'E'
+	stateNumberExpSign                       // after sign of the exponent
+	stateNumberExp                           // inside exponent
+)
+
+// PrecisionLossPolicy defines how JSONLexer treats numbers that can not be represented
+// by float64 exactly (e.g. 64-bit IDs like 9007199254740993)
+type PrecisionLossPolicy byte
+
+const (
+	// PrecisionLossIgnore silently converts such numbers to the closest float64 (default)
+	PrecisionLossIgnore PrecisionLossPolicy = iota
+	// PrecisionLossError makes Token() fail on such numbers
+	PrecisionLossError
+	// PrecisionLossRaw keeps the textual form of such numbers: Token() returns them as
+	// json.Number, TokenFast() marks them with IsLossyNumber() and exposes the text via
+	// String()
+	PrecisionLossRaw
+)
+
+// EmptyInputPolicy defines how JSONLexer treats input that contains no tokens at all
+// (e.g. empty or whitespace-only input)
+type EmptyInputPolicy byte
+
+const (
+	// EmptyInputEOF treats such input as a stream of no documents, the first Token()
+	// call returns io.EOF (default)
+	EmptyInputEOF EmptyInputPolicy = iota
+	// EmptyInputError requires at least one document, the first Token() call returns
+	// ErrEmptyInput for such input
+	EmptyInputError
 )
 
 // JSONLexer is a JSON lexical analyzer with streaming API support, where stream is a sequence of
@@ -36,74 +91,567 @@ const (
 //
 // JSONLexer uses unsafe pointers into the underlying buf to minimize allocations, see Token()
 // for the provided guarantees.
+//
+// Errors are sticky: once Token() or TokenFast() has failed, all subsequent calls return
+// the same error without consuming input, the buffer is left intact. Parsing can be
+// resumed with Recover().
 type JSONLexer struct {
 	r               io.Reader
 	readingFinished bool // reports whether r has more data to read
 
+	sources *sourcesReader // not nil if the lexer reads several sources
+
+	framing     Framing
+	frames      *framingReader // reader removing the framing, nil if there is none
+	frameValues int            // number of top-level values finished in the current frame
+
 	state lexerState
 
-	buf     []byte
-	currPos int // current positin in buffer
+	buf        []byte
+	bufSize    int   // initial size of buf, buf is shrunk back to it after oversized tokens
+	maxBufSize int   // max size buf may grow to, 0 if unlimited
+	bufOffset  int64 // offset of buf[0] in the input stream
+	currPos    int   // current positin in buffer
+
+	line      int64 // number of newlines before currPos
+	lineStart int64 // offset of the first byte of the current line in the input stream
 
 	unicodeRuneBytesCounter byte // a counter used to validate a unicode rune
 
+	numberState numberState
+
+	currTokenHasEscapes bool // true if current string token contains escape sequences
+
+	depth    int // number of currently open objects and arrays
+	maxDepth int // max depth, 0 if unlimited
+
+	isObject       []bool // kinds of currently open containers
+	expectKey      bool   // reports whether the next string is an object key
+	currTokenIsKey bool
+
+	emptyInputPolicy EmptyInputPolicy
+	emptyReadPolicy  EmptyReadPolicy // nil if the default one is used
+	tokenFound       bool            // true if at least one token has been found in the input
+
 	currTokenStart int // positin in the buf of current token start (if any)
-	currTokenEnd   int // positin in the buf of current token start (if any)
+	currTokenEnd   int // positin in the buf right after the end of current token (if any)
 	currTokenType  TokenType
+	currDelim      byte // current delimiter token with separators of the dialect normalized
 	newTokenFound  bool // true if during the last feed() a new token was finished being parsed
 
-	skipDelims bool
+	reprocessCurrByte bool // true if the last feed() did not consume the byte
+
+	err              error // sticky error returned until Recover() is called
+	errAtCurrByte    bool  // true if err was caused by the byte at currPos
+	discardCurrToken bool  // true if current string must be skipped after Recover()
+
+	peeked      bool // reports whether the next token has been read by PeekFast
+	peekedToken TokenGeneric
+	peekedErr   error
+
+	lazyState lazyState    // state of the token recognized by NextTokenType
+	lazyToken TokenGeneric // the token recognized by NextTokenType once converted
+
+	skipDelims    bool
+	skippedTokens TokenTypeSet // types of tokens that are not emitted
+	copyStrings   bool         // strings of returned tokens must be owned by the caller
+
+	stopWhen func(TokenGeneric) bool
+
+	budgetBytes  int // max number of bytes processed by a single Token() call
+	budgetTokens int // max number of tokens skipped by a single Token() call
+
+	dialect    Dialect
+	classes    *charClasses // character classes of the current dialect
+	separators *[256]byte   // additional separators of the current dialect, nil if none
+
+	quote     byte // quote that opened the current string
+	quoteLen  int  // length of the quotes enclosing the current string
+	rawQuotes int  // number of consecutive quotes at the end of the current raw string
+
+	precisionLossPolicy PrecisionLossPolicy
+	integers            bool
+	rawNumbers          bool
+	valueClasses        []ValueClass // classes of string values, nil if not classified
+	quotedNumbers       bool
+	quotedNumberPaths   []Path // paths of quoted numbers, all values are coerced if empty
+	quotedBools         bool
+	quotedBoolPaths     []Path // paths of quoted bools, all values are coerced if empty
+	digitsBuf           []byte // scratch space for precision loss detection
+
+	maxStringLen int         // max length of unescaped strings, 0 if unlimited
+	maxTokenSize int         // max raw length of tokens, 0 if unlimited
+	tracker      PathTracker // position in the document, see tracksPath
+	unescapeBuf  []byte      // scratch space for unescaping strings while tracking
+
+	validateStructure bool
+	grammar           grammarChecker
+
+	trackPath bool
+	pathDepth int // number of tracker frames describing the path of the last token
+
+	pathFilter      []Path // paths of the emitted subtrees, nil if all tokens are emitted
+	filterSkipDepth int    // depth of the subtree being skipped by the path filter, 0 if none
+
+	epoch         uint32 // number of Token() calls, strings are valid within one epoch
+	poisonStrings bool
+	poisonStart   int // range of buf that must be poisoned by the next Token() call
+	poisonEnd     int
+
+	streamPrefetch int // number of tokens Stream may lex ahead of the consumer
+	streamStrings  StreamStringPolicy
+	pinBuffers     bool // strings must stay valid while streaming, see StreamStringsUnsafe
 
 	debug bool
 }
 
 // NewJSONLexer creates a new JSONLexer with the given reader.
 func NewJSONLexer(r io.Reader) (*JSONLexer, error) {
-	l := &JSONLexer{
-		r:   r,
-		buf: make([]byte, defaultBufSize),
+	l := newJSONLexer(r, make([]byte, defaultBufSize))
+	return &l, nil
+}
+
+// newJSONLexer returns a lexer with default settings using buf of defaultBufSize
+func newJSONLexer(r io.Reader, buf []byte) JSONLexer {
+	return JSONLexer{
+		r:              r,
+		buf:            buf,
+		bufSize:        defaultBufSize,
+		skipDelims:     true,
+		classes:        defaultCharClasses,
+		streamPrefetch: streamBufSize,
+	}
+}
+
+// Reset prepares the lexer for parsing a new input from r as if it has just been created
+// but keeps its buffer and settings, so that lexers can be pooled (e.g. with sync.Pool)
+// instead of being allocated for every request. Strings returned before Reset become
+// invalid. A closed lexer gets a new buffer and default settings.
+func (l *JSONLexer) Reset(r io.Reader) {
+	if l.buf == nil {
+		epoch := l.epoch // closing has already invalidated strings
+
+		*l = newJSONLexer(r, make([]byte, defaultBufSize))
+		l.epoch = epoch
+
+		return
+	}
+
+	l.reset(r)
+}
+
+// reset prepares the lexer for parsing a new input from r keeping its buffer and
+// settings
+func (l *JSONLexer) reset(r io.Reader) {
+	// tokens returned before resetting are reported as stale by Validate
+	l.startEpoch()
+
+	*l = JSONLexer{
+		r:                   r,
+		buf:                 l.buf[:cap(l.buf)],
+		bufSize:             l.bufSize,
+		maxBufSize:          l.maxBufSize,
+		emptyInputPolicy:    l.emptyInputPolicy,
+		emptyReadPolicy:     l.emptyReadPolicy,
+		framing:             l.framing,
+		skipDelims:          l.skipDelims,
+		skippedTokens:       l.skippedTokens,
+		copyStrings:         l.copyStrings,
+		stopWhen:            l.stopWhen,
+		budgetBytes:         l.budgetBytes,
+		budgetTokens:        l.budgetTokens,
+		dialect:             l.dialect,
+		classes:             l.classes,
+		separators:          l.separators,
+		precisionLossPolicy: l.precisionLossPolicy,
+		integers:            l.integers,
+		rawNumbers:          l.rawNumbers,
+		valueClasses:        l.valueClasses,
+		quotedNumbers:       l.quotedNumbers,
+		quotedNumberPaths:   l.quotedNumberPaths,
+		quotedBools:         l.quotedBools,
+		quotedBoolPaths:     l.quotedBoolPaths,
+		digitsBuf:           l.digitsBuf[:0],
+		maxStringLen:        l.maxStringLen,
+		maxDepth:            l.maxDepth,
+		maxTokenSize:        l.maxTokenSize,
+		unescapeBuf:         l.unescapeBuf[:0],
+		validateStructure:   l.validateStructure,
+		trackPath:           l.trackPath,
+		pathFilter:          l.pathFilter,
+		grammar:             grammarChecker{isObject: l.grammar.isObject[:0]},
+		isObject:            l.isObject[:0],
+		epoch:               l.epoch,
+		poisonStrings:       l.poisonStrings,
+		streamPrefetch:      l.streamPrefetch,
+		streamStrings:       l.streamStrings,
+		debug:               l.debug,
+	}
+
+	l.applyFraming()
+	l.releaseMemory()
+}
+
+// Close releases the memory held by the lexer, all subsequent calls return ErrClosed.
+// Strings returned by the lexer become invalid. Close does not close the underlying
+// reader. It is safe to call Close several times, e.g. both on error and deferred.
+func (l *JSONLexer) Close() error {
+	*l = JSONLexer{
+		err:   ErrClosed,
+		epoch: l.epoch,
 	}
 
-	return l, nil
+	// tokens returned before closing are reported as stale by Validate
+	l.startEpoch()
+
+	return nil
 }
 
 // SetBufSize creates a new buffer of the given size. MUST be called before parsing started.
+// In case a long token makes the buffer grow, the buffer is shrunk back to this size once
+// the data following the token has been consumed, so that a single pathological value
+// (e.g. in a huge top-level array) does not inflate memory usage permanently.
 func (l *JSONLexer) SetBufSize(bufSize int) {
 	l.buf = make([]byte, bufSize)
+	l.bufSize = bufSize
+}
+
+// SetMaxBufSize sets a hard limit on the size the buffer may grow to, regardless of the
+// limits on tokens. A token that does not fit into a buffer of this size causes
+// *BufferLimitError (wrapped into *PositionError) naming the offset of the token instead
+// of allocating more. The limit set with SetMaxTokenSize is checked first, so with a buffer
+// limit above the token limit long tokens always cause ErrTokenTooLarge and the buffer
+// limit only bounds the memory. 0 means no limit (default). MUST be called before parsing
+// started.
+func (l *JSONLexer) SetMaxBufSize(n int) {
+	l.maxBufSize = n
 }
 
 // SetSkipDelims tells JSONLexer to skip delimiters and return only keys and values. This can
 // be useful in case you want to simply match the input to some specific grammar and have no
-// intention of doing full syntax analysis.
+// intention of doing full syntax analysis. Delimiters are skipped by default.
+//
+// Unlike other setters SetSkipDelims may be called at any moment (e.g. between documents),
+// the new value takes effect starting with the next Token() call.
 func (l *JSONLexer) SetSkipDelims(mustSkip bool) {
-	l.skipDelims = true
+	l.skipDelims = mustSkip
+}
+
+// SetTokenFilter tells JSONLexer to return only tokens of the given types, other tokens
+// are validated but neither converted nor returned (e.g. numbers are not parsed while
+// scanning for strings). Delimiters are returned only if they are in the mask and
+// SetSkipDelims(false) has been called. All tokens are returned by default. MUST be
+// called before parsing started.
+func (l *JSONLexer) SetTokenFilter(mask TokenTypeSet) {
+	l.skippedTokens = ^mask
+}
+
+// SetCopyStrings tells JSONLexer to return strings that are owned by the caller and stay
+// valid forever, each string is copied to a new allocation. This is safer but slower than
+// the default behaviour, where strings are valid only until the next Token() call.
+func (l *JSONLexer) SetCopyStrings(copyStrings bool) {
+	l.copyStrings = copyStrings
+}
+
+// StopWhen sets a predicate that makes JSONLexer stop right after returning a token
+// satisfying it, so that the rest of the input is not read. All subsequent calls return
+// ErrStopped, lexing can be resumed with Recover(). The predicate may call Context() to
+// learn the position of the token. MUST be called before parsing started.
+func (l *JSONLexer) StopWhen(predicate func(TokenGeneric) bool) {
+	l.stopWhen = predicate
 }
 
+// SetBudget bounds the work a single Token() call may perform: the number of bytes
+// processed and the number of skipped tokens (delimiters or tokens filtered out with
+// SetTokenFilter), 0 means no limit. Once the budget is exhausted ErrBudgetExceeded is
+// returned, the next call continues from where the previous one stopped. This lets
+// an event loop interleave other work while e.g. long runs of whitespaces are skipped.
+func (l *JSONLexer) SetBudget(maxBytes, maxTokens int) {
+	l.budgetBytes = maxBytes
+	l.budgetTokens = maxTokens
+}
+
+// SetDialect sets the dialect of JSON to be recognized, see Dialect. MUST be called before
+// parsing started.
+func (l *JSONLexer) SetDialect(d Dialect) {
+	l.dialect = d
+	l.classes = d.charClasses()
+	l.separators = d.separators()
+}
+
+// SetPrecisionLossPolicy sets the policy for numbers that can not be represented by
+// float64 exactly, see PrecisionLossPolicy. Detection of precision loss is skipped
+// completely with PrecisionLossIgnore.
+func (l *JSONLexer) SetPrecisionLossPolicy(p PrecisionLossPolicy) {
+	l.precisionLossPolicy = p
+}
+
+// SetMaxStringLen limits the length of strings (keys included) after unescaping, longer
+// strings cause *StringTooLongError naming their path. Unlike the size of the buffer,
+// which limits the raw length, it bounds the memory needed by consumers of the tokens.
+// Tracking the path makes lexing slower. 0 means no limit (default). MUST be called
+// before parsing started.
+func (l *JSONLexer) SetMaxStringLen(n int) {
+	l.maxStringLen = n
+}
+
+// SetMaxTokenSize limits the raw length of tokens (quotes and escape sequences included),
+// longer tokens cause ErrTokenTooLarge (wrapped into *PositionError naming the offset of
+// the token) instead of growing the buffer without bound, which untrusted input could
+// exploit. The buffer may still grow up to about twice the limit, see SetMaxBufSize for
+// a hard limit on memory. 0 means no limit (default). MUST be called before parsing
+// started.
+func (l *JSONLexer) SetMaxTokenSize(n int) {
+	l.maxTokenSize = n
+}
+
+// SetMaxDepth limits the number of nested objects and arrays, opening a container beyond
+// the limit causes *DepthLimitError (wrapped into *PositionError). It protects consumers
+// recursing into values from deeply nested payloads like [[[[...]]]]. 0 means no limit
+// (default). MUST be called before parsing started.
+func (l *JSONLexer) SetMaxDepth(n int) {
+	l.maxDepth = n
+}
+
+// SetValidateStructure makes JSONLexer validate the structure of the input: brackets must
+// match, keys must be strings followed by ':', members and elements must be separated
+// with ',' and the input must contain a single value. Violations are reported as
+// *PositionError pointing to the offending token. By default only tokens themselves are
+// validated. MUST be called before parsing started.
+func (l *JSONLexer) SetValidateStructure(validate bool) {
+	l.validateStructure = validate
+}
+
+// SetTrackPath makes JSONLexer maintain the path of the last returned token in the
+// document, see CurrentPath, CurrentPointer and MatchesPath. Tracking the path makes
+// lexing slower. MUST be called before parsing started.
+func (l *JSONLexer) SetTrackPath(track bool) {
+	l.trackPath = track
+}
+
+// SetPathFilter makes JSONLexer return only tokens which path (see CurrentPath) starts
+// with one of the given paths, i.e. the values at the paths with all their contents and
+// the keys of the matching members. Everything else is skipped without converting tokens
+// (no unescaping, no number conversion), objects and arrays that can not lead to any of
+// the paths are skipped without even tracking their path, which makes extracting a few
+// fields from huge documents cheap. Calling it with no paths removes the filter. MUST be
+// called before parsing started.
+func (l *JSONLexer) SetPathFilter(paths ...Path) {
+	l.pathFilter = nil
+	if len(paths) > 0 {
+		l.pathFilter = paths
+	}
+}
+
+// SetIntegers makes JSONLexer return numbers without fractional part and exponent that
+// fit into int64 or uint64 as LexerTokenTypeInt tokens, their exact values are returned
+// by Int64() and Uint64() (Number() returns the closest float64). Token() returns them as
+// int64 or uint64. SetTokenFilter treats them as LexerTokenTypeNumber. MUST be called
+// before parsing started.
+func (l *JSONLexer) SetIntegers(enabled bool) {
+	l.integers = enabled
+}
+
+// SetRawNumbers makes JSONLexer skip the conversion of numbers: tokens keep only the
+// textual form returned by NumberRaw(), Number() converts it on every call and Token()
+// returns numbers as json.Number. This is the fastest way to re-emit or store numbers
+// preserving their exact form. SetIntegers and SetPrecisionLossPolicy have no effect in
+// this mode. MUST be called before parsing started.
+func (l *JSONLexer) SetRawNumbers(raw bool) {
+	l.rawNumbers = raw
+}
+
+// SetQuotedNumbers makes JSONLexer return string values (not keys) containing nothing but
+// a valid JSON number as number tokens, e.g. "1.5" becomes 1.5, so that quoted numerics
+// are available via Number(), Int64() etc. (SetIntegers, SetRawNumbers and
+// SetPrecisionLossPolicy apply to them as well). With paths only values at those paths
+// are coerced, which requires tracking the path and makes lexing slower. Token filters
+// and NextTokenType still see such values as strings. MUST be called before parsing
+// started.
+func (l *JSONLexer) SetQuotedNumbers(enabled bool, paths ...Path) {
+	l.quotedNumbers = enabled
+	l.quotedNumberPaths = paths
+}
+
+// SetQuotedBools makes JSONLexer return string values (not keys) "true", "1", "false" and
+// "0" as bool tokens, so that producers encoding flags differently are normalized in
+// a single pass. With paths only values at those paths are coerced, which requires
+// tracking the path and makes lexing slower. Values subject to both SetQuotedBools and
+// SetQuotedNumbers become bools. Token filters and NextTokenType still see such values as
+// strings. MUST be called before parsing started.
+func (l *JSONLexer) SetQuotedBools(enabled bool, paths ...Path) {
+	l.quotedBools = enabled
+	l.quotedBoolPaths = paths
+}
+
+// SetValueClasses makes JSONLexer tag string values (not keys) with the first matching
+// class, see TokenGeneric.Class, so that consumers can dispatch on the semantic type of
+// values without matching them again. Up to 255 classes are supported, matching makes
+// lexing slower. MUST be called before parsing started.
+func (l *JSONLexer) SetValueClasses(classes ...ValueClass) error {
+	if len(classes) > maxValueClasses {
+		return fmt.Errorf("too many value classes: %d, max %d", len(classes), maxValueClasses)
+	}
+
+	l.valueClasses = classes
+
+	return nil
+}
+
+// SetEmptyInputPolicy sets the policy for input that contains no tokens, see
+// EmptyInputPolicy
+func (l *JSONLexer) SetEmptyInputPolicy(p EmptyInputPolicy) {
+	l.emptyInputPolicy = p
+}
+
+// SetEmptyReadPolicy sets the policy for readers returning no data and no error (which
+// would make the lexer spin otherwise), see EmptyReadPolicy
+func (l *JSONLexer) SetEmptyReadPolicy(p EmptyReadPolicy) {
+	l.emptyReadPolicy = p
+}
+
+// SetPoisonStrings enables a diagnostic mode for tests: every Token() call overwrites the
+// bytes of strings returned by the previous call with garbage, so that strings used after
+// they have become invalid are caught deterministically instead of being corrupted only
+// occasionally (once the buffer gets refilled).
+func (l *JSONLexer) SetPoisonStrings(poison bool) {
+	l.poisonStrings = poison
+}
+
+// Validate returns ErrStaleString if the string of the token has been invalidated by
+// subsequent Token() calls of the lexer. Tokens that have not been returned by the lexer
+// (or whose strings are owned, see SetCopyStrings) are always valid.
+func (l *JSONLexer) Validate(t TokenGeneric) error {
+	if t.epoch != 0 && t.epoch != l.epoch {
+		return ErrStaleString
+	}
+
+	return nil
+}
+
+// startEpoch invalidates strings returned by the previous Token() call
+func (l *JSONLexer) startEpoch() {
+	l.lazyState = lazyNone
+
+	if l.pinBuffers {
+		// strings of the whole stream share the epoch
+		return
+	}
+
+	l.epoch++
+	if l.epoch == 0 {
+		l.epoch++ // 0 is reserved for tokens with owned strings
+	}
+
+	if l.poisonStrings {
+		for i := l.poisonStart; i < l.poisonEnd; i++ {
+			l.buf[i] = poisonByte
+		}
+	}
+
+	l.poisonStart, l.poisonEnd = 0, 0
+}
+
+// poisonByte is used to overwrite invalidated strings, it is not valid UTF-8
+const poisonByte = 0xDB
+
 // SetDebug enables debug logging
 func (l *JSONLexer) SetDebug(debug bool) {
 	l.debug = true
 }
 
+// finishTokenBeforeCurrByte finishes the current token right before the current byte.
+// The byte itself is not a part of the token and will be processed once again.
+func (l *JSONLexer) finishTokenBeforeCurrByte() {
+	l.state = stateLexerSkipping
+	l.currTokenEnd = l.currPos
+	l.newTokenFound = true
+	l.reprocessCurrByte = true
+}
+
+// inToken reports whether some token is being parsed at the moment
+func (l *JSONLexer) inToken() bool {
+	switch l.state {
+	case stateLexerString, stateLexerPendingEscapedSymbol, stateLexerUnicodeRune,
+		stateLexerEmptyString, stateLexerRawString, stateLexerNumber, stateLexerBool,
+		stateLexerNull:
+		return true
+	}
+
+	return false
+}
+
 func (l *JSONLexer) processStateSkipping(c byte) error {
 	switch {
-	case c == '"':
+	case l.classes.is(c, charClassDelim):
+		if l.separators != nil && l.separators[c] != 0 {
+			c = l.separators[c]
+		}
+
+		l.currDelim = c
+
+		switch c {
+		case '{', '[':
+			if l.maxDepth > 0 && l.depth >= l.maxDepth {
+				return &DepthLimitError{Limit: l.maxDepth}
+			}
+
+			l.depth++
+			l.isObject = append(l.isObject, c == '{')
+			l.expectKey = c == '{'
+		case '}', ']':
+			if l.depth > 0 {
+				l.depth--
+			}
+			if len(l.isObject) > 0 {
+				l.isObject = l.isObject[:len(l.isObject)-1]
+			}
+
+			l.expectKey = false
+		case ',':
+			l.expectKey = len(l.isObject) > 0 && l.isObject[len(l.isObject)-1]
+		case ':':
+			l.expectKey = false
+		}
+
+		l.currTokenType = LexerTokenTypeDelim
+		l.currTokenStart = l.currPos
+		l.currTokenEnd = l.currPos + 1
+		l.newTokenFound = true
+	case l.classes.is(c, charClassQuote):
 		l.state = stateLexerString
+		if c == '`' {
+			l.state = stateLexerRawString
+		}
+
+		l.quote = c
+		l.quoteLen = 1
 		l.currTokenType = LexerTokenTypeString
 		l.currTokenStart = l.currPos
-	case CanAppearInNumber(rune(c)):
-		l.state = stateLexerNumber
-		l.currTokenType = LexerTokenTypeNumber
-		l.currTokenStart = l.currPos
-	case c == 't' || c == 'T':
-		fallthrough
-	case c == 'f' || c == 'F':
+		l.currTokenHasEscapes = false
+		l.currTokenIsKey = l.expectKey
+		l.expectKey = false
+	case c == '0' && l.classes.is(c, charClassNumber):
+		l.startNumber(stateNumberZero)
+	case unicode.IsDigit(rune(c)) && l.classes.is(c, charClassNumber):
+		l.startNumber(stateNumberInt)
+	case c == '-' && l.classes.is(c, charClassNumber),
+		c == '+' && l.classes.is(c, charClassNumber) && !l.dialect.StrictNumbers:
+		l.startNumber(stateNumberSign)
+	case c == '.' && l.classes.is(c, charClassNumber) && !l.dialect.StrictNumbers:
+		l.startNumber(stateNumberLeadingDot)
+	case c == 't' || c == 'f' || (c == 'T' || c == 'F') && !l.dialect.StrictLiterals:
 		l.state = stateLexerBool
 		l.currTokenType = LexerTokenTypeBool
 		l.currTokenStart = l.currPos
-	case c == 'n' || c == 'N':
+	case c == 'n' || c == 'N' && !l.dialect.StrictLiterals:
 		l.state = stateLexerNull
 		l.currTokenType = LexerTokenTypeNull
 		l.currTokenStart = l.currPos
+	case l.classes.is(c, charClassCommentStart):
+		l.state = stateLexerCommentStart
+	case l.dialect.StrictWhitespace && c != ' ' && c != '\t' && c != '\n' && c != '\r':
+		return fmt.Errorf("invalid character '%c' between tokens", c)
 	default:
 		// skipping
 	}
@@ -111,23 +659,93 @@ func (l *JSONLexer) processStateSkipping(c byte) error {
 	return nil
 }
 
+func (l *JSONLexer) processStateCommentStart(c byte) error {
+	switch c {
+	case '/':
+		l.state = stateLexerLineComment
+	case '*':
+		l.state = stateLexerBlockComment
+	default:
+		return fmt.Errorf("invalid character '%c' after '/'", c)
+	}
+
+	return nil
+}
+
+func (l *JSONLexer) processStateComment(c byte) error {
+	switch {
+	case l.state == stateLexerLineComment && c == '\n':
+		l.state = stateLexerSkipping
+	case l.state == stateLexerBlockComment && c == '*':
+		l.state = stateLexerBlockCommentEnd
+	case l.state == stateLexerBlockCommentEnd && c == '/':
+		l.state = stateLexerSkipping
+	case l.state == stateLexerBlockCommentEnd && c != '*':
+		l.state = stateLexerBlockComment
+	}
+
+	return nil
+}
+
 func (l *JSONLexer) processStateString(c byte) error {
 	switch c {
-	case '"':
+	case l.quote:
+		if c == '"' && l.dialect.RawStrings && l.currPos == l.currTokenStart+1 {
+			l.state = stateLexerEmptyString
+			return nil
+		}
+
 		l.state = stateLexerSkipping
-		l.currTokenEnd = l.currPos
-		l.newTokenFound = true
+		l.currTokenEnd = l.currPos + 1
+		l.newTokenFound = !l.discardCurrToken
+		l.discardCurrToken = false
 	case '\\':
 		l.state = stateLexerPendingEscapedSymbol
+		l.currTokenHasEscapes = true
 	default:
+		if c < 0x20 && l.dialect.StrictStrings {
+			return fmt.Errorf("invalid control character %#x inside string", c)
+		}
+
 		// accumulating string
 	}
 
 	return nil
 }
 
+func (l *JSONLexer) processStateEmptyString(c byte) error {
+	if c != '"' {
+		l.finishTokenBeforeCurrByte()
+		return nil
+	}
+
+	l.state = stateLexerRawString
+	l.quoteLen = 3
+	l.rawQuotes = 0
+
+	return nil
+}
+
+func (l *JSONLexer) processStateRawString(c byte) error {
+	if c != l.quote {
+		l.rawQuotes = 0
+		return nil
+	}
+
+	if l.rawQuotes++; l.rawQuotes < l.quoteLen {
+		return nil
+	}
+
+	l.state = stateLexerSkipping
+	l.currTokenEnd = l.currPos + 1
+	l.newTokenFound = !l.discardCurrToken
+	l.discardCurrToken = false
+
+	return nil
+}
+
 func (l *JSONLexer) processStatePendingEscapedSymbol(c byte) error {
-	if !IsValidEscapedSymbol(rune(c)) {
+	if !l.classes.is(c, charClassEscapedSymbol) {
 		return fmt.Errorf("invalid escape sequence '\\%c'", c)
 	}
 
@@ -155,36 +773,123 @@ func (l *JSONLexer) processStateUnicodeRune(c byte) error {
 	return nil
 }
 
+func (l *JSONLexer) startNumber(initialState numberState) {
+	l.state = stateLexerNumber
+	l.numberState = initialState
+	l.currTokenType = LexerTokenTypeNumber
+	l.currTokenStart = l.currPos
+}
+
+// numberCanEndHere reports whether a number may be terminated in the current sub-state
+func (l *JSONLexer) numberCanEndHere() bool {
+	switch l.numberState {
+	case stateNumberZero, stateNumberInt, stateNumberFrac, stateNumberExp:
+		return true
+	case stateNumberDot:
+		return !l.dialect.StrictNumbers
+	}
+
+	return false
+}
+
 func (l *JSONLexer) processStateNumber(c byte) error {
-	switch {
-	case unicode.IsDigit(rune(c)):
-		fallthrough
-	case c == '.':
-		// accumulating number
-	case IsDelim(rune(c)):
-		fallthrough
-	case unicode.IsSpace(rune(c)):
-		l.state = stateLexerSkipping
-		l.currTokenEnd = l.currPos
-		l.newTokenFound = true
+	if l.classes.is(c, charClassDelim|charClassSpace|charClassCommentStart) {
+		if !l.numberCanEndHere() {
+			return fmt.Errorf("unexpected end of number at '%c'", c)
+		}
+
+		l.finishTokenBeforeCurrByte()
+
+		return nil
+	}
+
+	if !l.classes.is(c, charClassNumber) {
+		return fmt.Errorf("invalid literal '%c' while parsing number value", c)
+	}
+
+	isDigit := unicode.IsDigit(rune(c))
+
+	switch l.numberState {
+	case stateNumberSign:
+		switch {
+		case c == '0':
+			l.numberState = stateNumberZero
+		case isDigit:
+			l.numberState = stateNumberInt
+		case c == '.' && !l.dialect.StrictNumbers:
+			l.numberState = stateNumberLeadingDot
+		default:
+			return fmt.Errorf("invalid literal '%c' while parsing number value", c)
+		}
+	case stateNumberZero, stateNumberInt:
+		switch {
+		case isDigit && l.numberState == stateNumberZero && l.dialect.StrictNumbers:
+			return fmt.Errorf("leading zeros are not allowed in numbers")
+		case isDigit:
+			l.numberState = stateNumberInt
+		case c == '.':
+			l.numberState = stateNumberDot
+		case c == 'e' || c == 'E':
+			l.numberState = stateNumberExpStart
+		default:
+			return fmt.Errorf("invalid literal '%c' while parsing number value", c)
+		}
+	case stateNumberLeadingDot:
+		if !isDigit {
+			return fmt.Errorf("invalid literal '%c' while parsing number value", c)
+		}
+
+		l.numberState = stateNumberFrac
+	case stateNumberDot, stateNumberFrac:
+		switch {
+		case isDigit:
+			l.numberState = stateNumberFrac
+		case (c == 'e' || c == 'E') && (l.numberState == stateNumberFrac || !l.dialect.StrictNumbers):
+			l.numberState = stateNumberExpStart
+		default:
+			return fmt.Errorf("invalid literal '%c' while parsing number value", c)
+		}
+	case stateNumberExpStart:
+		switch {
+		case isDigit:
+			l.numberState = stateNumberExp
+		case c == '-' || c == '+':
+			l.numberState = stateNumberExpSign
+		default:
+			return fmt.Errorf("invalid literal '%c' while parsing number exponent", c)
+		}
+	case stateNumberExpSign, stateNumberExp:
+		if !isDigit {
+			return fmt.Errorf("invalid literal '%c' while parsing number exponent", c)
+		}
+
+		l.numberState = stateNumberExp
 	}
 
 	return nil
 }
 
+// foldLiteral returns the byte of a literal (true, false or null) in the lowercase,
+// the byte is returned as is if the dialect requires literals to be lowercase
+func (l *JSONLexer) foldLiteral(c byte) rune {
+	if l.dialect.StrictLiterals {
+		return rune(c)
+	}
+
+	return unicode.ToLower(rune(c))
+}
+
 func (l *JSONLexer) processStateNull(c byte) error {
 	currPositionInToken := l.currPos - l.currTokenStart
 
 	if currPositionInToken == len("null") {
-		l.state = stateLexerSkipping
-		l.currTokenEnd = l.currPos
-		l.newTokenFound = true
+		l.finishTokenBeforeCurrByte()
 		return nil
 	}
 
 	expectedLiteral := rune("null"[currPositionInToken])
 
-	if unicode.ToLower(rune(c)) != expectedLiteral {
+	if l.foldLiteral(c) != expectedLiteral {
 		return fmt.Errorf("invalid literal '%c' while parsing 'Null' value", c)
 	}
 
@@ -205,15 +910,13 @@ func (l *JSONLexer) processStateBool(c byte) error {
 	}
 
 	if currPositionInToken == len(expectedToken) {
-		l.state = stateLexerSkipping
-		l.currTokenEnd = l.currPos
-		l.newTokenFound = true
+		l.finishTokenBeforeCurrByte()
 		return nil
 	}
 
 	expectedLiteral := rune(expectedToken[currPositionInToken])
 
-	if unicode.ToLower(rune(c)) != expectedLiteral {
+	if l.foldLiteral(c) != expectedLiteral {
 		return fmt.Errorf("invalid literal '%c' while parsing bool value", c)
 	}
 
@@ -226,6 +929,10 @@ func (l *JSONLexer) feed(c byte) error {
 		return l.processStateSkipping(c)
 	case stateLexerString:
 		return l.processStateString(c)
+	case stateLexerEmptyString:
+		return l.processStateEmptyString(c)
+	case stateLexerRawString:
+		return l.processStateRawString(c)
 	case stateLexerPendingEscapedSymbol:
 		return l.processStatePendingEscapedSymbol(c)
 	case stateLexerUnicodeRune:
@@ -236,171 +943,957 @@ func (l *JSONLexer) feed(c byte) error {
 		return l.processStateBool(c)
 	case stateLexerNull:
 		return l.processStateNull(c)
+	case stateLexerCommentStart:
+		return l.processStateCommentStart(c)
+	case stateLexerLineComment, stateLexerBlockComment, stateLexerBlockCommentEnd:
+		return l.processStateComment(c)
 	}
 
 	return nil
 }
 
-func (l *JSONLexer) currTokenAsUnsafeString() (string, error) {
-	// skipping "
-	var subStr = l.buf[l.currTokenStart+1 : l.currTokenEnd]
-	subStr, err := UnescapeBytesInplace(subStr)
-	if err != nil {
-		return "", err
+// offset returns the offset of the byte at currPos in the input stream
+func (l *JSONLexer) offset() int64 {
+	return l.bufOffset + int64(l.currPos)
+}
+
+// advance moves to the next byte of buf keeping track of lines
+func (l *JSONLexer) advance() {
+	if l.buf[l.currPos] == '\n' {
+		l.line++
+		l.lineStart = l.offset() + 1
 	}
 
-	return unsafeStringFromBytes(subStr), nil
+	l.currPos++
 }
 
-func (l *JSONLexer) currTokenAsNumber() (float64, error) {
-	str := unsafeStringFromBytes(l.buf[l.currTokenStart:l.currTokenEnd])
+// position returns the line and the column of the given offset, which MUST be on the
+// current line. On 32-bit platforms they saturate at the max int (e.g. for columns of
+// single-line dumps larger than 2GB).
+func (l *JSONLexer) position(offset int64) (line, column int) {
+	return clampInt(l.line + 1), clampInt(offset - l.lineStart + 1)
+}
 
-	n, err := strconv.ParseFloat(str, 64)
-	if err != nil {
-		return 0, fmt.Errorf("could not convert '%s' to float64: %w", StringDeepCopy(str), err)
+// clampInt converts v to int saturating at the max int
+func clampInt(v int64) int {
+	if v > maxInt {
+		return int(maxInt)
 	}
 
-	return n, nil
+	return int(v)
 }
 
-func (l *JSONLexer) currTokenAsBool() (bool, error) {
-	if unicode.ToLower(rune(l.buf[l.currTokenStart])) == 't' {
-		return true, nil
-	}
-	if unicode.ToLower(rune(l.buf[l.currTokenStart])) == 'f' {
-		return false, nil
-	}
+func (l *JSONLexer) positionError(offset int64, err error) error {
+	line, column := l.position(offset)
+	return &PositionError{Offset: offset, Line: line, Column: column, Err: err}
+}
 
-	tokenAsStr := unsafeStringFromBytes(l.buf[l.currTokenStart:l.currTokenEnd])
-	return false, fmt.Errorf("could not convert '%s' to bool", StringDeepCopy(tokenAsStr))
+// Position returns the line and the column (both starting from 1, columns are counted in
+// bytes) and the offset in the input stream of the next byte to be processed
+func (l *JSONLexer) Position() (line, column int, offset int64) {
+	offset = l.offset()
+	line, column = l.position(offset)
+
+	return line, column, offset
 }
 
-func (l *JSONLexer) currToken() (TokenGeneric, error) {
-	switch l.currTokenType {
-	case LexerTokenTypeDelim:
-		return newTokenGenericFromDelim(l.buf[l.currTokenStart]), nil
-	case LexerTokenTypeString:
-		s, err := l.currTokenAsUnsafeString()
-		return newTokenGenericFromString(s), err
-	case LexerTokenTypeNumber:
-		n, err := l.currTokenAsNumber()
-		return newTokenGenericFromNumber(n), err
-	case LexerTokenTypeBool:
-		b, err := l.currTokenAsBool()
-		return newTokenGenericFromBool(b), err
-	case LexerTokenTypeNull:
-		return newTokenGenericFromNull(), nil
+// InputOffset returns the offset in the input stream of the end of the last returned token
+// and the beginning of the next one, just like json.Decoder.InputOffset does. Data read
+// ahead into the buffer is not counted.
+func (l *JSONLexer) InputOffset() int64 {
+	return l.offset()
+}
+
+// Context returns the context of the last returned token. Its path is tracked only with
+// SetTrackPath(true), otherwise only the offset is available.
+func (l *JSONLexer) Context() TokenContext {
+	start, _ := l.currTokenOffsets()
+	c := TokenContext{offset: start}
+
+	if l.trackPath {
+		c.tr, c.depth = &l.tracker, l.pathDepth
 	}
 
-	panic("unexpected token type")
+	return c
 }
 
-func (l *JSONLexer) fetchNewData() error {
-	// if now some token is in the middle of parsing we gotta copy the part of it
-	// that has already been parsed, otherwise we won't be able to construct it
-	if l.state != stateLexerSkipping && l.state != stateLexerIdle {
-		dstBuf := l.buf
+// CurrentPath returns the path of the last returned token as keys and array indices: the
+// path of the value for values (opening delimiters included), the path of the member for
+// keys, the path of the closed container for closing delimiters and the path of the
+// enclosing container for ',' and ':'. Top-level values have an empty path. Requires
+// SetTrackPath(true), nil is returned otherwise.
+func (l *JSONLexer) CurrentPath() []string {
+	return l.Context().Path()
+}
 
-		// checking if buf must be extended
-		currTokenBytesParsed := l.currPos - l.currTokenStart
-		if currTokenBytesParsed >= l.currTokenStart {
-			newSize := 2 * len(l.buf)
-			dstBuf = make([]byte, newSize)
+// CurrentPointer returns the path of the last returned token (see CurrentPath) as
+// a JSON Pointer (RFC 6901), e.g. "/cells/3/value". Requires SetTrackPath(true), an
+// empty string is returned otherwise.
+func (l *JSONLexer) CurrentPointer() string {
+	return l.Context().Pointer()
+}
 
-			if l.debug {
-				log.Printf("debug: gojsonlex: growing buffer %d -> %d", len(l.buf), newSize)
-			}
-		}
+// MatchesPath reports whether the path of the last returned token (see CurrentPath)
+// matches p without making any allocations. Requires SetTrackPath(true), false is
+// returned otherwise.
+func (l *JSONLexer) MatchesPath(p Path) bool {
+	return l.Context().Matches(p)
+}
+
+// currTokenOffsets returns offsets of the first byte and the byte right after the end
+// of current token in the input stream
+func (l *JSONLexer) currTokenOffsets() (start, end int64) {
+	return l.bufOffset + int64(l.currTokenStart), l.bufOffset + int64(l.currTokenEnd)
+}
+
+// currStringContent returns the current string token without the enclosing quotes
+func (l *JSONLexer) currStringContent() []byte {
+	return l.buf[l.currTokenStart+l.quoteLen : l.currTokenEnd-l.quoteLen]
+}
+
+func (l *JSONLexer) currTokenAsUnsafeString() (string, error) {
+	var subStr = l.currStringContent()
+
+	// most strings contain no escape sequences, those can be returned as is
+	if !l.currTokenHasEscapes {
+		return unsafeStringFromBytes(subStr), nil
+	}
+
+	// escapes have already been validated according to the dialect
+	subStr, err := unescapeBytesInplace(subStr, true)
+	if err != nil {
+		return "", err
+	}
+
+	return unsafeStringFromBytes(subStr), nil
+}
+
+// integerToken converts an integer literal to a token, it reports false if the integer
+// fits neither into int64 nor into uint64
+func integerToken(str string) (TokenGeneric, bool) {
+	negative := str[0] == '-'
+	if str[0] == '-' || str[0] == '+' {
+		str = str[1:]
+	}
+
+	v, err := strconv.ParseUint(str, 10, 64)
+	if err != nil || negative && v > 1<<63 {
+		return TokenGeneric{}, false
+	}
+
+	t := NewTokenGenericFromUint64(v)
+	if negative {
+		t.number = -t.number
+		t.negative = true
+	}
+
+	return t, true
+}
+
+func (l *JSONLexer) currTokenAsNumber() (TokenGeneric, error) {
+	str := unsafeStringFromBytes(l.buf[l.currTokenStart:l.currTokenEnd])
+
+	// the state the number has ended in tells whether it has fractional part or exponent
+	return l.numberToken(str, l.numberState == stateNumberZero || l.numberState == stateNumberInt)
+}
+
+// currTokenAsQuotedBool converts the current string token to a bool token, ok is false if
+// the string is not subject to SetQuotedBools or is not a bool
+func (l *JSONLexer) currTokenAsQuotedBool() (t TokenGeneric, ok bool) {
+	if l.currTokenIsKey || l.currTokenHasEscapes || !l.currTokenAt(l.quotedBoolPaths) {
+		return TokenGeneric{}, false
+	}
+
+	switch unsafeStringFromBytes(l.currStringContent()) {
+	case "true", "1":
+		return NewTokenGenericFromBool(true), true
+	case "false", "0":
+		return NewTokenGenericFromBool(false), true
+	}
+
+	return TokenGeneric{}, false
+}
+
+// currTokenAsQuotedNumber converts the current string token to a number token, ok is
+// false if the string is not subject to SetQuotedNumbers or is not a valid number
+func (l *JSONLexer) currTokenAsQuotedNumber() (t TokenGeneric, ok bool, err error) {
+	if l.currTokenIsKey || l.currTokenHasEscapes || !l.currTokenAt(l.quotedNumberPaths) {
+		return TokenGeneric{}, false, nil
+	}
+
+	str := unsafeStringFromBytes(l.currStringContent())
+	if !jsonutil.IsValidNumber(str) {
+		return TokenGeneric{}, false, nil
+	}
+
+	t, err = l.numberToken(str, strings.IndexAny(str, ".eE") < 0)
+
+	return t, true, err
+}
+
+// currTokenAt reports whether the current token is at one of the given paths, any path
+// matches if none is given
+func (l *JSONLexer) currTokenAt(paths []Path) bool {
+	if len(paths) == 0 {
+		return true
+	}
+
+	c := TokenContext{tr: &l.tracker, depth: l.pathDepth}
+	for _, p := range paths {
+		if c.Matches(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// numberToken converts the textual form of a number to a token, integral reports whether
+// the number has neither fractional part nor exponent
+func (l *JSONLexer) numberToken(str string, integral bool) (TokenGeneric, error) {
+	if l.rawNumbers {
+		return TokenGeneric{t: LexerTokenTypeNumber, str: str, unparsed: true}, nil
+	}
+
+	if l.integers && integral {
+		if t, ok := integerToken(str); ok {
+			t.str = str
+			return t, nil
+		}
+	}
+
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return TokenGeneric{}, fmt.Errorf("could not convert '%s' to float64: %w", StringDeepCopy(str), err)
+	}
+
+	t := NewTokenGenericFromNumber(n)
+	t.str = str
+
+	if l.precisionLossPolicy == PrecisionLossIgnore {
+		return t, nil
+	}
+
+	var lossy bool
+	if lossy, l.digitsBuf = numberLosesPrecision(str, n, l.digitsBuf); !lossy {
+		return t, nil
+	}
+
+	if l.precisionLossPolicy == PrecisionLossError {
+		return TokenGeneric{}, fmt.Errorf("'%s' can not be converted to float64 without loss of precision",
+			StringDeepCopy(str))
+	}
+
+	t.lossy = true
+
+	return t, nil
+}
+
+func (l *JSONLexer) currTokenAsBool() (bool, error) {
+	if unicode.ToLower(rune(l.buf[l.currTokenStart])) == 't' {
+		return true, nil
+	}
+	if unicode.ToLower(rune(l.buf[l.currTokenStart])) == 'f' {
+		return false, nil
+	}
+
+	tokenAsStr := unsafeStringFromBytes(l.buf[l.currTokenStart:l.currTokenEnd])
+	return false, fmt.Errorf("could not convert '%s' to bool", StringDeepCopy(tokenAsStr))
+}
+
+// tracksPath reports whether the path of tokens is tracked
+func (l *JSONLexer) tracksPath() bool {
+	return l.maxStringLen > 0 || l.trackPath || l.pathFilter != nil ||
+		l.quotedNumbers && len(l.quotedNumberPaths) > 0 || l.quotedBools && len(l.quotedBoolPaths) > 0
+}
+
+// trackToken feeds the finished token to the path tracker enforcing the limit (if any) on the
+// length of strings
+func (l *JSONLexer) trackToken() error {
+	t := TokenGeneric{t: l.currTokenType}
+
+	switch l.currTokenType {
+	case LexerTokenTypeDelim:
+		t = NewTokenGenericFromDelim(l.currDelim)
+	case LexerTokenTypeString:
+		str := l.currStringContent()
+
+		if l.currTokenHasEscapes {
+			// the token itself must be unescaped only once, by currToken()
+			var err error
+			l.unescapeBuf, err = unescapeBytesInplace(append(l.unescapeBuf[:0], str...), true)
+			if err != nil {
+				return err
+			}
+
+			str = l.unescapeBuf
+		}
+
+		t.str = unsafeStringFromBytes(str)
+	}
+
+	// the structure is not validated by the lexer, the path is the best guess then
+	role, _ := l.tracker.Feed(&t)
+
+	switch role {
+	case TokenRoleKey, TokenRoleClose:
+		l.pathDepth = l.tracker.depth
+	case TokenRoleSeparator:
+		l.pathDepth = l.tracker.depth - 1
+	default:
+		l.pathDepth = l.tracker.valueDepth
+	}
+
+	if l.pathDepth < 0 {
+		l.pathDepth = 0
+	}
+
+	if l.maxStringLen == 0 || t.t != LexerTokenTypeString || len(t.str) <= l.maxStringLen {
+		return nil
+	}
+
+	path := l.tracker.Path()
+	if role == TokenRoleKey {
+		path = l.tracker.keyPath()
+	}
+
+	return &StringTooLongError{Path: path, Len: len(t.str), Limit: l.maxStringLen}
+}
+
+// passesPathFilter reports whether the path of the current token starts with one of the
+// paths set with SetPathFilter. Once an object or an array that can not lead to any of
+// them is opened, the rest of it is skipped without tracking.
+func (l *JSONLexer) passesPathFilter() bool {
+	leads := false
+
+	for _, p := range l.pathFilter {
+		if p.Len() <= l.pathDepth {
+			if l.tracker.matchesAt(p.Len(), p) {
+				return true
+			}
+
+			continue
+		}
+
+		if !leads && l.tracker.matchesAt(l.pathDepth, p.prefix(l.pathDepth)) {
+			leads = true
+		}
+	}
+
+	if !leads && l.currTokenType == LexerTokenTypeDelim && (l.currDelim == '{' || l.currDelim == '[') {
+		l.filterSkipDepth = l.depth
+	}
+
+	return false
+}
+
+// checkTokenSize enforces the limit (if any) on the size of the finished token
+func (l *JSONLexer) checkTokenSize() error {
+	if l.maxTokenSize > 0 && l.currTokenEnd-l.currTokenStart > l.maxTokenSize {
+		start, _ := l.currTokenOffsets()
+		return l.positionError(start, ErrTokenTooLarge)
+	}
+
+	return nil
+}
+
+// checkGrammar feeds the finished token to the grammar checker
+func (l *JSONLexer) checkGrammar() error {
+	if err := l.grammar.feed(l.currTokenType, l.currDelim); err != nil {
+		start, _ := l.currTokenOffsets()
+		return l.positionError(start, err)
+	}
+
+	return nil
+}
+
+func (l *JSONLexer) currToken() (TokenGeneric, error) {
+	switch l.currTokenType {
+	case LexerTokenTypeDelim:
+		return NewTokenGenericFromDelim(l.currDelim), nil
+	case LexerTokenTypeString:
+		if l.quotedBools {
+			if t, ok := l.currTokenAsQuotedBool(); ok {
+				return t, nil
+			}
+		}
+
+		if l.quotedNumbers {
+			if t, ok, err := l.currTokenAsQuotedNumber(); ok {
+				return t, err
+			}
+		}
+
+		s, err := l.currTokenAsUnsafeString()
+		t := NewTokenGenericFromString(s)
+		t.key = l.currTokenIsKey
+
+		return t, err
+	case LexerTokenTypeNumber:
+		return l.currTokenAsNumber()
+	case LexerTokenTypeBool:
+		b, err := l.currTokenAsBool()
+		return NewTokenGenericFromBool(b), err
+	case LexerTokenTypeNull:
+		return NewTokenGenericFromNull(), nil
+	}
+
+	panic("unexpected token type")
+}
+
+// releaseMemory drops the memory grown to fit oversized tokens, MUST be called only
+// between tokens when buf contains no unprocessed data
+func (l *JSONLexer) releaseMemory() {
+	if len(l.buf) > l.bufSize {
+		if l.debug {
+			log.Printf("debug: gojsonlex: shrinking buffer %d -> %d", len(l.buf), l.bufSize)
+		}
+
+		l.buf = make([]byte, l.bufSize)
+	}
+
+	if cap(l.digitsBuf) > l.bufSize {
+		l.digitsBuf = nil
+	}
+
+	if cap(l.unescapeBuf) > l.bufSize {
+		l.unescapeBuf = nil
+	}
+}
+
+// fetchError wraps the error returned by fetchNewData into *PositionError, errors caused
+// by the size of the current token are reported at its start
+func (l *JSONLexer) fetchError(err error) error {
+	offset := l.offset()
+
+	if _, ok := err.(*BufferLimitError); ok || err == ErrTokenTooLarge {
+		offset, _ = l.currTokenOffsets()
+	}
+
+	return l.positionError(offset, err)
+}
+
+func (l *JSONLexer) fetchNewData() error {
+	// buf might have been truncated by a previous failed read
+	l.buf = l.buf[:cap(l.buf)]
+
+	// if now some token is in the middle of parsing we gotta copy the part of it
+	// that has already been parsed, otherwise we won't be able to construct it
+	if l.inToken() {
+		dstBuf := l.buf
+
+		// checking if buf must be extended
+		currTokenBytesParsed := l.currPos - l.currTokenStart
+		if l.maxTokenSize > 0 && currTokenBytesParsed > l.maxTokenSize {
+			return ErrTokenTooLarge
+		}
+
+		if currTokenBytesParsed >= l.currTokenStart {
+			newSize := 2 * int64(len(l.buf))
+			if l.maxBufSize > 0 && newSize > int64(l.maxBufSize) {
+				newSize = int64(l.maxBufSize)
+			}
+
+			switch {
+			case newSize > maxInt:
+				return ErrTokenTooLarge
+			case newSize > int64(len(l.buf)):
+				dstBuf = make([]byte, newSize)
+
+				if l.debug {
+					log.Printf("debug: gojsonlex: growing buffer %d -> %d", len(l.buf), newSize)
+				}
+			case currTokenBytesParsed == len(l.buf):
+				// the limit has been reached and the token fills the whole buffer
+				start, _ := l.currTokenOffsets()
+				return &BufferLimitError{Offset: start, Limit: l.maxBufSize}
+			}
+		}
+
+		if l.pinBuffers && len(dstBuf) == len(l.buf) {
+			dstBuf = make([]byte, len(l.buf))
+		}
 
 		// copying the part that has already been parsed
 		copy(dstBuf, l.buf[l.currTokenStart:])
+		l.bufOffset += int64(l.currTokenStart)
 		l.currTokenStart = 0
 		l.currPos = currTokenBytesParsed
 		l.buf = dstBuf
 	} else {
+		l.bufOffset += int64(l.currPos)
 		l.currPos = 0
+
+		// the oversized token (if any) has been consumed, even inside a huge top-level
+		// array the memory is not kept until its end
+		l.releaseMemory()
+
+		if l.pinBuffers {
+			l.buf = make([]byte, len(l.buf))
+		}
 	}
 
 	// reading new data into buf
-	n, err := io.ReadFull(l.r, l.buf[l.currPos:])
+	n, err := l.readFull(l.buf[l.currPos:])
 	if err == io.EOF || err == io.ErrUnexpectedEOF {
 		l.readingFinished = true
 		l.buf = l.buf[:l.currPos+n]
 	} else if err != nil {
+		l.buf = l.buf[:l.currPos+n]
 		return fmt.Errorf("could not fetch new data: %w", err)
 	}
 
 	return nil
 }
 
+// finishTokenAtEOF finishes a number, bool, null or "" token terminated by the end of input,
+// it reports whether such a token has been found
+func (l *JSONLexer) finishTokenAtEOF() bool {
+	tokenLen := l.currPos - l.currTokenStart
+
+	switch l.state {
+	case stateLexerNumber:
+		if !l.numberCanEndHere() {
+			return false
+		}
+	case stateLexerBool:
+		if unicode.ToLower(rune(l.buf[l.currTokenStart])) == 't' && tokenLen != len("true") ||
+			unicode.ToLower(rune(l.buf[l.currTokenStart])) == 'f' && tokenLen != len("false") {
+			return false
+		}
+	case stateLexerNull:
+		if tokenLen != len("null") {
+			return false
+		}
+	case stateLexerEmptyString:
+	default:
+		return false
+	}
+
+	l.state = stateLexerSkipping
+	l.currTokenEnd = l.currPos
+
+	return true
+}
+
 func (l *JSONLexer) shutdown() error {
-	if l.state != stateLexerSkipping {
-		return fmt.Errorf("unexpected EOF")
+	if err := l.passFrameEnds(l.bufOffset + int64(len(l.buf))); err != nil {
+		return err
+	}
+
+	if l.state != stateLexerSkipping && l.state != stateLexerLineComment || l.depth != 0 {
+		offset := l.bufOffset + int64(len(l.buf))
+		line, column := l.position(offset)
+
+		return &UnexpectedEOFError{Offset: offset, Line: line, Column: column}
+	}
+
+	if !l.tokenFound && l.emptyInputPolicy == EmptyInputError {
+		return ErrEmptyInput
 	}
 
 	return io.EOF
 }
 
-// Token returns the next JSON token, all delimiters are skipped. Token will return io.EOF when
-// all input has been exhausted.  All strings returned by Token are guaranteed to be valid
-// until the next Token call, otherwise you MUST make a deep copy.
+// Token returns the next JSON token, delimiters are skipped unless SetSkipDelims(false) has
+// been called, in which case they are returned as json.Delim just like json.Decoder does it
+// (unlike json.Decoder though, ':' and ',' are returned as well). Token will return io.EOF when
+// all input has been exhausted between top-level values, *UnexpectedEOFError is returned if
+// the input ends inside a token, an object or an array. Other errors caused by the input
+// (or by reading it) are returned as *PositionError. All strings returned by Token are guaranteed to be valid
+// until the next Token call, otherwise you MUST make a deep copy (or use SetCopyStrings).
 func (l *JSONLexer) Token() (json.Token, error) {
 	t, err := l.TokenFast()
 	if err != nil {
 		return nil, err
 	}
 
-	switch t.t {
-	case LexerTokenTypeNull:
-		return nil, nil
-	case LexerTokenTypeDelim:
-		return t.delim, nil
-	case LexerTokenTypeNumber:
-		return t.number, nil
-	case LexerTokenTypeString:
-		return t.str, nil
-	case LexerTokenTypeBool:
-		return t.boolean, nil
+	return t.jsonToken(), nil
+}
+
+// Peek returns the next token the way Token does it without consuming it, the following
+// Token (or TokenFast) call returns the same token (or error). See PeekFast.
+func (l *JSONLexer) Peek() (json.Token, error) {
+	t, err := l.PeekFast()
+	if err != nil {
+		return nil, err
 	}
 
-	panic("unknown token type")
+	return t.jsonToken(), nil
+}
+
+// PeekFast returns the next token the way TokenFast does it without consuming it, the
+// following TokenFast (or Token) call returns the same token (or error). Peeking reads
+// the token from the input, so strings of previously returned tokens become invalid and
+// methods describing the last token (e.g. Context, InputOffset) describe the peeked one.
+func (l *JSONLexer) PeekFast() (TokenGeneric, error) {
+	if !l.peeked {
+		l.peekedToken, l.peekedErr = l.TokenFast()
+		l.peeked = true
+	}
+
+	return l.peekedToken, l.peekedErr
+}
+
+// More reports whether there is another element in the current array or object (or
+// another top-level value), i.e. whether the next token is not a closing delimiter, an
+// error or the end of input. Like json.Decoder.More it reads ahead: the next token is
+// peeked (see PeekFast), a closing delimiter that is not emitted is consumed.
+func (l *JSONLexer) More() bool {
+	if l.peeked {
+		return l.peekedErr == nil && !l.peekedToken.isCloseDelim()
+	}
+
+	l.startEpoch()
+
+	if l.err != nil {
+		return false
+	}
+
+	for {
+		err := l.findToken(false)
+		if err == nil && l.currTokenType == LexerTokenTypeDelim && l.skipDelims {
+			if l.currDelim == '}' || l.currDelim == ']' {
+				return false
+			}
+
+			continue
+		}
+		if err == nil && l.skippedTokens.Contains(l.currTokenType) {
+			continue
+		}
+
+		var t TokenGeneric
+		if err == nil {
+			if t, err = l.currToken(); err != nil {
+				start, _ := l.currTokenOffsets()
+				err = l.positionError(start, err)
+			}
+		}
+
+		l.peekedToken, l.peekedErr = l.emit(t, err)
+		l.peeked = true
+
+		return l.peekedErr == nil && !l.peekedToken.isCloseDelim()
+	}
 }
 
 // TokenFast is a more efficient version of Token(). All strings returned by Token
 // are guaranteed to be valid until the next Token call, otherwise you MUST make a deep copy.
 func (l *JSONLexer) TokenFast() (TokenGeneric, error) {
+	if l.peeked {
+		// strings of the peeked token stay valid
+		l.peeked = false
+		return l.peekedToken, l.peekedErr
+	}
+
+	l.startEpoch()
+
+	if l.err != nil {
+		return TokenGeneric{}, l.err
+	}
+
+	return l.emit(l.nextToken())
+}
+
+// emit makes the found token ready to be returned to the user
+func (l *JSONLexer) emit(t TokenGeneric, err error) (TokenGeneric, error) {
+	if err != nil && err != io.EOF && err != ErrBudgetExceeded {
+		l.err = err
+	}
+
+	if err == nil && t.str != "" {
+		if l.copyStrings {
+			t.str = StringDeepCopy(t.str)
+		} else {
+			t.epoch = l.epoch
+			l.poisonStart, l.poisonEnd = l.currTokenStart, l.currTokenEnd
+		}
+	}
+
+	if err == nil && l.valueClasses != nil && t.t == LexerTokenTypeString && !t.key {
+		t.class = classify(l.valueClasses, t.str)
+	}
+
+	if err == nil && l.stopWhen != nil && l.stopWhen(t) {
+		l.err = ErrStopped
+	}
+
+	return t, err
+}
+
+// SkipValue skips the next value (a scalar, an object or an array) along with the ','
+// or ':' preceding it without converting any tokens, which is the cheapest way to ignore
+// the value of a key that is of no interest. Strings returned before become invalid.
+// io.EOF is returned if the input has been exhausted between top-level values. Running
+// into '}' or ']' instead of a value is an error.
+func (l *JSONLexer) SkipValue() error {
+	depth, inContainer := l.depth, false
+
+	if l.peeked {
+		// the value may have been started by the peeked token
+		t, err := l.TokenFast()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case t.t != LexerTokenTypeDelim:
+			return nil
+		case t.delim == '{' || t.delim == '[':
+			depth, inContainer = l.depth-1, true
+		case t.delim == '}' || t.delim == ']':
+			start, _ := l.currTokenOffsets()
+			l.err = l.positionError(start, fmt.Errorf("unexpected '%c', expected a value", t.delim))
+
+			return l.err
+		}
+	}
+
+	l.startEpoch()
+
+	if l.err != nil {
+		return l.err
+	}
+
+	err := l.skipValue(depth, inContainer)
+	if err != nil && err != io.EOF {
+		l.err = err
+	}
+
+	return err
+}
+
+// skipContainer skips the rest of the object or the array opened by the last returned token
+func (l *JSONLexer) skipContainer() error {
+	err := l.skipValue(l.depth-1, true)
+	if err != nil && err != io.EOF {
+		l.err = err
+	}
+
+	return err
+}
+
+// skipValue skips tokens until the value started at depth ends, inContainer reports
+// whether an object or an array is being skipped
+func (l *JSONLexer) skipValue(depth int, inContainer bool) error {
+	for {
+		if err := l.findToken(false); err != nil {
+			return err
+		}
+
+		if l.currTokenType != LexerTokenTypeDelim {
+			if !inContainer {
+				return nil
+			}
+
+			continue
+		}
+
+		switch c := l.currDelim; c {
+		case '{', '[':
+			inContainer = true
+		case '}', ']':
+			if !inContainer {
+				start, _ := l.currTokenOffsets()
+				return l.positionError(start, fmt.Errorf("unexpected '%c', expected a value", c))
+			}
+
+			if l.depth == depth {
+				return nil
+			}
+		}
+	}
+}
+
+// Recover clears the last error and resynchronizes the lexer so that parsing can be
+// continued. If the error was caused by malformed input, the partially parsed token
+// and the offending byte are discarded and lexing resumes right after that byte, so
+// every Recover() call after such an error is guaranteed to make progress. An offending
+// delimiter is not discarded though, it is returned by the next Token() call. Malformed
+// strings are skipped up to the closing quote. A token
+// truncated by the end of input is discarded as well. If reading
+// from the underlying reader failed, the read is retried by the next Token() call.
+// Errors converting an already parsed token (e.g. precision loss) are simply cleared,
+// the token itself is skipped. Recover does nothing if there was no error.
+func (l *JSONLexer) Recover() {
+	if l.peeked && l.peekedErr != nil {
+		l.peeked = false
+	}
+
+	if l.err == nil || l.err == ErrClosed {
+		return
+	}
+
+	if l.errAtCurrByte {
+		c := l.buf[l.currPos]
+		inString := l.inToken() && l.currTokenType == LexerTokenTypeString
+
+		l.state = stateLexerSkipping
+		l.reprocessCurrByte = false
+
+		switch {
+		case inString && c == l.quote:
+			// the offending quote terminates the malformed string
+			l.advance()
+		case inString:
+			l.state = stateLexerString
+			l.discardCurrToken = true
+			l.advance()
+		case l.classes.is(c, charClassDelim):
+			// the offending delimiter is kept so that the structure is not broken
+		default:
+			l.advance()
+		}
+	} else if l.readingFinished && l.currPos >= len(l.buf) {
+		l.state = stateLexerSkipping
+		l.discardCurrToken = false
+		l.depth = 0
+		l.isObject = l.isObject[:0]
+		l.expectKey = false
+		l.tokenFound = true // ErrEmptyInput has already been reported
+	}
+
+	l.err = nil
+	l.errAtCurrByte = false
+}
+
+func (l *JSONLexer) nextToken() (TokenGeneric, error) {
+	if err := l.findToken(true); err != nil {
+		return TokenGeneric{}, err
+	}
+
+	t, err := l.currToken()
+	if err != nil {
+		start, _ := l.currTokenOffsets()
+		return t, l.positionError(start, err)
+	}
+
+	return t, nil
+}
+
+// findToken finds the next token without converting it, with filter tokens that are not
+// emitted are skipped and the budget is respected
+func (l *JSONLexer) findToken(filter bool) error {
 	if l.state == stateLexerIdle {
 		if err := l.fetchNewData(); err != nil {
-			return TokenGeneric{}, err
+			return l.fetchError(err)
 		}
 
 		l.state = stateLexerSkipping
 	}
 
+	bytesProcessed, tokensSkipped := 0, 0
+
 	for {
+		if filter && (l.budgetBytes > 0 && bytesProcessed >= l.budgetBytes ||
+			l.budgetTokens > 0 && tokensSkipped >= l.budgetTokens) {
+			return ErrBudgetExceeded
+		}
+
 		if l.currPos >= len(l.buf) {
 			if l.readingFinished {
-				return TokenGeneric{}, l.shutdown()
+				if l.finishTokenAtEOF() {
+					l.tokenFound = true
+
+					if err := l.checkTokenSize(); err != nil {
+						return err
+					}
+
+					if err := l.checkFrame(); err != nil {
+						return err
+					}
+
+					if l.validateStructure {
+						if err := l.checkGrammar(); err != nil {
+							return err
+						}
+					}
+
+					// only top-level values are finished by the end of input
+					if l.pathFilter != nil {
+						l.pathDepth = 0
+
+						if !l.passesPathFilter() {
+							return l.shutdown()
+						}
+					}
+
+					break
+				}
+
+				return l.shutdown()
 			}
 
 			if err := l.fetchNewData(); err != nil {
-				return TokenGeneric{}, err
+				return l.fetchError(err)
 			}
 
 			continue // last fetching could probably return 0 new bytes
 		}
 
 		if err := l.feed(l.buf[l.currPos]); err != nil {
-			return TokenGeneric{}, err
+			l.errAtCurrByte = true
+			return l.positionError(l.offset(), err)
 		}
 
-		l.currPos++
+		bytesProcessed++
+
+		if l.reprocessCurrByte {
+			l.reprocessCurrByte = false
+		} else {
+			l.advance()
+		}
 
 		if l.newTokenFound {
 			l.newTokenFound = false
+			l.tokenFound = true
+
+			if err := l.checkTokenSize(); err != nil {
+				return err
+			}
+
+			if err := l.checkFrame(); err != nil {
+				return err
+			}
+
+			if l.validateStructure {
+				if err := l.checkGrammar(); err != nil {
+					return err
+				}
+			}
+
+			if l.filterSkipDepth > 0 {
+				if l.currTokenType != LexerTokenTypeDelim || l.depth >= l.filterSkipDepth {
+					// tokens of subtrees not leading to the paths are not even tracked
+					tokensSkipped++
+					continue
+				}
+
+				l.filterSkipDepth = 0
+			}
+
+			if l.tracksPath() {
+				if err := l.trackToken(); err != nil {
+					start, _ := l.currTokenOffsets()
+					return l.positionError(start, err)
+				}
+			}
+
+			if l.pathFilter != nil && !l.passesPathFilter() {
+				tokensSkipped++
+				continue
+			}
+
+			if filter && (l.currTokenType == LexerTokenTypeDelim && l.skipDelims ||
+				l.skippedTokens.Contains(l.currTokenType)) {
+				tokensSkipped++
+				continue
+			}
+
 			break
 		}
 	}
 
-	return l.currToken()
+	return nil
 }
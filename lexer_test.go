@@ -3,9 +3,16 @@ package gojsonlex
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 type jsonLexerOutputToken struct {
@@ -91,31 +98,31 @@ func TestJSONLexer(t *testing.T) {
 				},
 			},
 		},
-		// {
-		// 	input: `{"hello":{"0": -10, "1": -11.0}}`,
-		// 	output: []jsonLexerOutputToken{
-		// 		{
-		// 			"hello",
-		// 			LexerTokenTypeString,
-		// 		},
-		// 		{
-		// 			"0",
-		// 			LexerTokenTypeString,
-		// 		},
-		// 		{
-		// 			float64(-10),
-		// 			LexerTokenTypeNumber,
-		// 		},
-		// 		{
-		// 			"1",
-		// 			LexerTokenTypeString,
-		// 		},
-		// 		{
-		// 			float64(-11),
-		// 			LexerTokenTypeNumber,
-		// 		},
-		// 	},
-		// },
+		{
+			input: `{"hello":{"0": -10, "1": -11.0}}`,
+			output: []jsonLexerOutputToken{
+				{
+					"hello",
+					LexerTokenTypeString,
+				},
+				{
+					"0",
+					LexerTokenTypeString,
+				},
+				{
+					float64(-10),
+					LexerTokenTypeNumber,
+				},
+				{
+					"1",
+					LexerTokenTypeString,
+				},
+				{
+					float64(-11),
+					LexerTokenTypeNumber,
+				},
+			},
+		},
 		// tests for special symbols
 		{
 			input: `{"ua": "\"\"Some\nWeird\tUA\"\""}`,
@@ -339,6 +346,15 @@ func TestJSONLexerFails(t *testing.T) {
 		{`{"size": 1.2e*10}`, nil, false},
 		{`{"distance": 1.57+e10}`, nil, false},
 		{`{"size": 1.210-e}`, nil, false},
+		{`{"size": -}`, nil, false},
+		{`{"size": -.}`, nil, false},
+		{`{"size": 1e}`, nil, false},
+		{`{"size": 1e+}`, nil, false},
+		{`{"size": 1e5.2}`, nil, false},
+		{`{"size": 1ee5}`, nil, false},
+		{`{"size": --1}`, nil, false},
+		{`{"size": 1.5.}`, nil, false},
+		{`{"size": 15"}`, nil, false},
 	}
 
 	for _, testcase := range testcases {
@@ -369,111 +385,2063 @@ func TestJSONLexerFails(t *testing.T) {
 	}
 }
 
-const (
-	jsonSample = ` {
-	  "type" : "row",
-	  "position" : 471,
-	  "clustering" : [ "1b5bf100-8f99-11ea-8e8d-fa163e4302ba" ],
-	  "liveness_info" : { "tstamp" : "2020-05-06T12:57:14.193447Z" },
-	  "cells" : [
-		{ "name" : "event_id", "value" : 253 },
-		{ "name" : "ip", "value" : "5.61.233.11" },
-		{ "name" : "is_valid", "value" : true },
-		{ "name" : "session_id", "value" : null },
-		{ "name" : "delta", "value" : 3.14 },
-		{ "name" : "temperature", "value" : -52 },
-		{ "name" : "distance", "value" : 1.57e10 },
-		{ "name" : "args", "deletion_info" : { "marked_deleted" : "2020-05-06T12:57:14.193446Z", "local_delete_time" : "2020-05-06T12:57:14Z" } },
-		{ "name" : "args", "path" : [ "f" ], "value" : "fdevmail.openstacklocal" },
-		{ "name" : "args", "path" : [ "h" ], "value" : "internal-api.devmail.ru" },
-		{ "name" : "args", "path" : [ "ip" ], "value" : "127.0.0.1" },
-		{ "name" : "args", "path" : [ "rid" ], "value" : "8c28ca1055" },
-		{ "name" : "args", "path" : [ "ua" ], "value" : "\"Go-http-client/1.1\"" },
-		{ "desc": "\u041f\u0440\u043e\u0432\u0435\u0440\u043a\u0430 \\UD83D\\UDCA9 \u043f\u043e\u0447\u0442\u044b"}
-	  ]
-	}`
-)
+func printToken(t TokenGeneric) string {
+	switch t.Type() {
+	case LexerTokenTypeDelim:
+		return string(t.Delim())
+	case LexerTokenTypeString:
+		return t.StringCopy()
+	case LexerTokenTypeNumber:
+		return fmt.Sprint(t.Number())
+	case LexerTokenTypeBool:
+		return fmt.Sprint(t.Bool())
+	}
 
-func generateBenchmarkInput(b *bytes.Buffer, numObjects int) {
-	for i := 0; i < numObjects; i++ {
-		if i > 0 {
-			b.WriteRune(',')
+	return "<nil>"
+}
+
+func TestJSONLexerSetSkipDelims(t *testing.T) {
+	input := `{"a": [1]} {"b": [2, true]}`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+	l.SetSkipDelims(false)
+
+	var output []string
+
+	// the first document is parsed structurally, the rest is only matched
+	for depth := 0; len(output) == 0 || depth > 0; {
+		token, err := l.TokenFast()
+		if err != nil {
+			t.Fatalf("%v", err)
 		}
 
-		b.WriteString(jsonSample)
+		output = append(output, printToken(token))
+
+		if token.Type() == LexerTokenTypeDelim {
+			switch token.Delim() {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+	}
+
+	l.SetSkipDelims(true)
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, printToken(token))
+	}
+
+	expected := `{ a : [ 1 ] } b 2 true`
+	if strings.Join(output, " ") != expected {
+		t.Errorf("got '%s', expected '%s'", strings.Join(output, " "), expected)
 	}
 }
 
-func BenchmarkEncodingJSON(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		b.StopTimer()
-		input := bytes.NewBuffer(nil)
-		input.WriteRune('[')
-		generateBenchmarkInput(input, 100)
-		input.WriteRune(']')
-		dec := json.NewDecoder(input)
-		b.StartTimer()
+func TestJSONLexerDelimiterTokens(t *testing.T) {
+	input := ` {"a" :[1, {}] ,"b":null}`
+	expected := []json.Token{
+		json.Delim('{'), "a", json.Delim(':'), json.Delim('['), float64(1), json.Delim(','),
+		json.Delim('{'), json.Delim('}'), json.Delim(']'), json.Delim(','), "b", json.Delim(':'),
+		nil, json.Delim('}'),
+	}
 
-		for {
-			_, err := dec.Token()
-			if err == io.EOF {
+	for _, fast := range []bool{false, true} {
+		l, err := NewJSONLexer(iotest.OneByteReader(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetSkipDelims(false)
+
+		for i := 0; ; i++ {
+			var token json.Token
+			var delimType bool
+
+			if fast {
+				var tok TokenGeneric
+				if tok, err = l.TokenFast(); err == nil {
+					token, delimType = tok.jsonToken(), tok.Type() == LexerTokenTypeDelim
+				}
+			} else {
+				token, err = l.Token()
+				_, delimType = token.(json.Delim)
+			}
+
+			if err == io.EOF && i == len(expected) {
 				break
 			}
-			if err != nil {
-				b.Errorf("could not get next token: %v", err)
+			if err != nil || i >= len(expected) {
+				t.Fatalf("fast %t: token %d: got %v (%v)", fast, i, token, err)
+			}
+
+			if token != expected[i] {
+				t.Errorf("fast %t: token %d: got %v, expected %v", fast, i, token, expected[i])
+			}
+
+			if _, isDelim := expected[i].(json.Delim); isDelim != delimType {
+				t.Errorf("fast %t: token %d: got delimiter %t", fast, i, delimType)
+			}
+
+			// every delimiter is reported at its own position
+			if d, ok := expected[i].(json.Delim); ok {
+				if offset := l.InputOffset(); input[offset-1] != byte(d) {
+					t.Errorf("fast %t: token %d: got offset %d", fast, i, offset)
+				}
 			}
 		}
 	}
 }
 
-func BenchmarkJSONLexer(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		b.StopTimer()
-		input := bytes.NewBuffer(nil)
-		generateBenchmarkInput(input, 100)
-		l, err := NewJSONLexer(input)
+type tokenFilterTestCase struct {
+	mask       TokenTypeSet
+	skipDelims bool
+	output     string
+}
+
+func TestJSONLexerSetTokenFilter(t *testing.T) {
+	// 1e400 does not fit float64, but filtered out numbers are never converted
+	input := `{"a": [1, "x", true, null, 1e400], "b": {"c": false}}`
+
+	testcases := []tokenFilterTestCase{
+		{NewTokenTypeSet(LexerTokenTypeString), true, "a x b c"},
+		{NewTokenTypeSet(LexerTokenTypeBool), true, "true false"},
+		{NewTokenTypeSet(LexerTokenTypeDelim, LexerTokenTypeNull), false, "{ : [ , , , <nil> , ] , : { : } }"},
+		{NewTokenTypeSet(LexerTokenTypeDelim, LexerTokenTypeNull), true, "<nil>"},
+		{NewTokenTypeSet(), false, ""},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(input))
 		if err != nil {
-			b.Errorf("could not create JSONLexer: %v", err)
+			t.Fatalf("could not create lexer: %v", err)
 		}
 
-		b.StartTimer()
+		l.SetSkipDelims(testcase.skipDelims)
+		l.SetTokenFilter(testcase.mask)
+
+		var output []string
 
 		for {
-			_, err := l.Token()
+			token, err := l.TokenFast()
 			if err == io.EOF {
 				break
 			}
 			if err != nil {
-				b.Errorf("could not get next token: %v", err)
+				t.Errorf("testcase '%s': %v", testcase.output, err)
+				break
 			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != testcase.output {
+			t.Errorf("testcase '%s': got '%s'", testcase.output, strings.Join(output, " "))
 		}
 	}
 }
 
-func BenchmarkJSONLexerFast(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		b.StopTimer()
+func TestJSONLexerStopWhen(t *testing.T) {
+	input := `{"id": 1, "name": "x"} {"id": 2, "name": "y"}` + strings.Repeat(" ", 64) +
+		`{"id": 3, "name": "z"}`
 
-		input := bytes.Buffer{}
-		generateBenchmarkInput(&input, 100)
+	l, err := NewJSONLexer(iotest.OneByteReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
 
-		l, err := NewJSONLexer(&input)
+	l.SetBufSize(1)
+	l.StopWhen(func(token TokenGeneric) bool {
+		return token.StringEquals("y")
+	})
+
+	var output []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == ErrStopped {
+			break
+		}
 		if err != nil {
-			b.Errorf("could not create JSONLexer: %v", err)
+			t.Fatalf("%v", err)
 		}
 
-		l.SetBufSize(1024)
+		output = append(output, printToken(token))
+	}
 
-		b.StartTimer()
+	if read := l.bufOffset + int64(len(l.buf)); read > int64(strings.Index(input, `{"id": 3`)) {
+		t.Errorf("the input has been read too far: %d bytes", read)
+	}
+
+	if _, err := l.TokenFast(); err != ErrStopped {
+		t.Errorf("got %v, expected %v", err, ErrStopped)
+	}
+
+	l.Recover()
+	output = append(output, "|")
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, printToken(token))
+	}
+
+	expected := "id 1 name x id 2 name y | id 3 name z"
+	if strings.Join(output, " ") != expected {
+		t.Errorf("got '%s', expected '%s'", strings.Join(output, " "), expected)
+	}
+}
+
+type budgetTestCase struct {
+	input               string
+	maxBytes, maxTokens int
+	output              string
+	exceeded            int // expected number of ErrBudgetExceeded
+}
+
+func TestJSONLexerSetBudget(t *testing.T) {
+	testcases := []budgetTestCase{
+		{`{"a":` + strings.Repeat(" ", 1000) + `"bcd"}`, 100, 0, "a bcd", 10},
+		{`"abcdef" 1`, 1, 0, "abcdef 1", 9},
+		{`[[[[1]]], [2]]`, 0, 2, "1 2", 5},
+		{`[1, 2]`, 0, 0, "1 2", 0},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBudget(testcase.maxBytes, testcase.maxTokens)
+
+		var output []string
+		exceeded := 0
 
 		for {
-			_, err := l.TokenFast()
+			token, err := l.TokenFast()
 			if err == io.EOF {
 				break
 			}
+			if err == ErrBudgetExceeded {
+				exceeded++
+				continue
+			}
 			if err != nil {
-				b.Errorf("could not get next token: %v", err)
+				t.Errorf("testcase '%s': %v", testcase.input, err)
+				break
 			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, strings.Join(output, " "), testcase.output)
+		}
+
+		if exceeded != testcase.exceeded {
+			t.Errorf("testcase '%s': budget exceeded %d times, expected %d",
+				testcase.input, exceeded, testcase.exceeded)
+		}
+	}
+}
+
+func TestJSONLexerSetCopyStrings(t *testing.T) {
+	input := `{"key": "value", "escaped": "a\tb", "n": 9007199254740993}`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+	l.SetCopyStrings(true)
+	l.SetPrecisionLossPolicy(PrecisionLossRaw)
+
+	var tokens []TokenGeneric
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	var output []string
+	for _, token := range tokens {
+		output = append(output, token.String())
+	}
+
+	expected := "key value escaped a\tb n 9007199254740993"
+	if strings.Join(output, " ") != expected {
+		t.Errorf("got '%s', expected '%s'", strings.Join(output, " "), expected)
+	}
+}
+
+func TestJSONLexerStaleStrings(t *testing.T) {
+	if !zeroCopyStrings {
+		t.Skip("strings are copied in purego builds")
+	}
+
+	input := `{"key": "value", "escaped": "a\tb", "n": 1}`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetPoisonStrings(true)
+
+	prev, err := l.TokenFast()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	prevCopy := prev.StringCopy()
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if err := l.Validate(token); err != nil {
+			t.Errorf("token '%s': %v", printToken(token), err)
+		}
+
+		if err := l.Validate(prev); prevCopy != "" && err != ErrStaleString {
+			t.Errorf("previous token '%s': got %v, expected %v", prevCopy, err, ErrStaleString)
+		}
+
+		if prevCopy != "" && prev.String() == prevCopy {
+			t.Errorf("previous token '%s' has not been poisoned", prevCopy)
+		}
+
+		prev, prevCopy = token, token.StringCopy()
+	}
+
+	if err := l.Validate(NewTokenGenericFromString("x")); err != nil {
+		t.Errorf("token created by the caller: %v", err)
+	}
+}
+
+func TestJSONLexerReleasesMemory(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	input := `{"a": "` + long + `", "b": [1, 2]}` + strings.Repeat(" ", 200) + `["b", 3]`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+
+	expected := []struct {
+		token string
+		grown bool // reports whether the buffer must be larger than its initial size
+	}{
+		{"a", false},
+		{long, true},
+		{"b", true},
+		{"1", true},
+		{"2", true},
+		{"b", false},
+		{"3", false},
+	}
+
+	for _, e := range expected {
+		token, err := l.TokenFast()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if printToken(token) != e.token {
+			t.Errorf("got '%s', expected '%s'", printToken(token), e.token)
+		}
+
+		if grown := len(l.buf) > 4; grown != e.grown {
+			t.Errorf("token '%s': buffer size is %d", e.token, len(l.buf))
+		}
+	}
+}
+
+func TestJSONLexerReleasesMemoryInsideValue(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	input := `["` + long + `"` + strings.Repeat(`, "b"`, 100) + `]`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+
+	var maxSize int
+
+	for {
+		_, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if len(l.buf) > maxSize {
+			maxSize = len(l.buf)
 		}
 	}
+
+	if maxSize <= 4 {
+		t.Errorf("buffer must have grown to fit the long string")
+	}
+
+	if cap(l.buf) != 4 {
+		t.Errorf("buffer size is %d at the end of the array, expected 4", cap(l.buf))
+	}
+}
+
+type jsonLexerRecoverTestCase struct {
+	input      string
+	output     []string // printed tokens, "!" marks an error
+	skipDelims bool
+}
+
+func TestJSONLexerRecover(t *testing.T) {
+	testcases := []jsonLexerRecoverTestCase{
+		{`["a", tru, "b"]`, []string{"a", "!", "b"}, true},
+		{`[tru] ["b"]`, []string{"[", "!", "]", "[", "b", "]"}, false},
+		{`{"a": 1x, "b": 2}`, []string{"a", "!", "b", "2"}, true},
+		{`{"a": "\x", "b": null}`, []string{"a", "!", "b", "<nil>"}, true},
+		{`["a", "b`, []string{"a", "!"}, true},
+		{`[1e, 2]`, []string{"[", "!", ",", "2", "]"}, false},
+		{`["\u12", "b"]`, []string{"!", "b"}, true},
+		{`["\u12x4\q", "b"]`, []string{"!", "!", "b"}, true},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.skipDelims = testcase.skipDelims
+
+		var output []string
+
+		for i := 0; i < 100; i++ {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if _, err2 := l.TokenFast(); err2 != err {
+					t.Errorf("testcase '%s': error is not sticky: got '%v' after '%v'",
+						testcase.input, err2, err)
+				}
+
+				output = append(output, "!")
+				l.Recover()
+
+				continue
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != strings.Join(testcase.output, " ") {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.output)
+		}
+	}
+}
+
+type jsonLexerEOFTestCase struct {
+	input     string
+	output    []string // printed tokens
+	truncated bool     // reports whether input must end with UnexpectedEOFError
+}
+
+func TestJSONLexerEOF(t *testing.T) {
+	testcases := []jsonLexerEOFTestCase{
+		{``, nil, false},
+		{`10`, []string{"10"}, false},
+		{`-1.5e3`, []string{"-1500"}, false},
+		{`true`, []string{"true"}, false},
+		{`false`, []string{"false"}, false},
+		{`null`, []string{"<nil>"}, false},
+		{`1 2 null`, []string{"1", "2", "<nil>"}, false},
+		{`{"a": 1}`, []string{"{", "a", ":", "1", "}"}, false},
+		{`{"a": 1} [`, []string{"{", "a", ":", "1", "}", "["}, true},
+		{`{"a": 1`, []string{"{", "a", ":", "1"}, true},
+		{`[[1], 2`, []string{"[", "[", "1", "]", ",", "2"}, true},
+		{`"abc`, nil, true},
+		{`tru`, nil, true},
+		{`fals`, nil, true},
+		{`nul`, nil, true},
+		{`1e`, nil, true},
+		{`-`, nil, true},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.skipDelims = false
+
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				if testcase.truncated {
+					t.Errorf("testcase '%s': must have failed", testcase.input)
+				}
+
+				break
+			}
+			if err != nil {
+				eofErr, ok := err.(*UnexpectedEOFError)
+
+				switch {
+				case !testcase.truncated || !ok:
+					t.Errorf("testcase '%s': %v", testcase.input, err)
+				case !errors.Is(err, io.ErrUnexpectedEOF):
+					t.Errorf("testcase '%s': error must wrap io.ErrUnexpectedEOF", testcase.input)
+				case eofErr.Offset != int64(len(testcase.input)):
+					t.Errorf("testcase '%s': got offset %d, expected %d",
+						testcase.input, eofErr.Offset, len(testcase.input))
+				}
+
+				break
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != strings.Join(testcase.output, " ") {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.output)
+		}
+	}
+}
+
+type jsonLexerPositionTestCase struct {
+	input  string
+	line   int
+	column int
+	offset int64
+}
+
+func TestJSONLexerPositionError(t *testing.T) {
+	testcases := []jsonLexerPositionTestCase{
+		{`-x`, 1, 2, 1},
+		{`{"a": tru}`, 1, 10, 9},
+		{"{\n  \"a\": 1,\n  \"b\": nul1\n}", 3, 11, 22},
+		{"[\n\"a\\q\"]", 2, 4, 5},
+		{"[1,\r\n 1e400]", 2, 2, 6}, // conversion errors point to the start of the token
+		{"\n\n[1, 2", 3, 6, 7},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.SetPrecisionLossPolicy(PrecisionLossError)
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+		}
+
+		var line, column int
+		var offset int64
+
+		switch err := err.(type) {
+		case *PositionError:
+			line, column, offset = err.Line, err.Column, err.Offset
+		case *UnexpectedEOFError:
+			line, column, offset = err.Line, err.Column, err.Offset
+		default:
+			t.Errorf("testcase '%s': unexpected error %v", testcase.input, err)
+			continue
+		}
+
+		if line != testcase.line || column != testcase.column || offset != testcase.offset {
+			t.Errorf("testcase '%s': got %d:%d (%d), expected %d:%d (%d)", testcase.input,
+				line, column, offset, testcase.line, testcase.column, testcase.offset)
+		}
+	}
+}
+
+func TestJSONLexerPosition(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader("{\n\t\"a\": [\n\t\t1\n\t]\n}"))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+
+	for _, expected := range []string{`a 2:5`, `1 3:4`} {
+		token, err := l.TokenFast()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		line, column, _ := l.Position()
+		if got := fmt.Sprintf("%s %d:%d", printToken(token), line, column); got != expected {
+			t.Errorf("got '%s', expected '%s'", got, expected)
+		}
+	}
+
+	// lines are counted across Recover()
+	l, _ = NewJSONLexer(strings.NewReader("[1x,\n\"b\" 2]"))
+	if _, err := l.TokenFast(); err == nil {
+		t.Fatalf("must have failed")
+	}
+
+	l.Recover()
+
+	if _, err := l.TokenFast(); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if line, column, offset := l.Position(); line != 2 || column != 4 || offset != 8 {
+		t.Errorf("got %d:%d (%d), expected 2:4 (8)", line, column, offset)
+	}
+}
+
+func TestJSONLexerSetIntegers(t *testing.T) {
+	input := `[0, -0, 12, -9223372036854775808, 18446744073709551615, 18446744073709551616, ` +
+		`-9223372036854775809, 1.0, 1e3, 9007199254740993]`
+
+	expected := []json.Token{
+		int64(0), int64(0), int64(12), int64(math.MinInt64), uint64(math.MaxUint64),
+		float64(18446744073709551616), float64(-9223372036854775809), 1.0, 1000.0, int64(9007199254740993),
+	}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+	l.SetIntegers(true)
+
+	var output []json.Token
+
+	for {
+		token, err := l.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, token)
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader(`-42`))
+	l.SetIntegers(true)
+
+	token, err := l.TokenFast()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if token.Type() != LexerTokenTypeInt || token.Number() != -42 {
+		t.Errorf("got %s token %v, expected int -42", token.Type(), token.Number())
+	}
+}
+
+func TestJSONLexerSetPathFilter(t *testing.T) {
+	type testCase struct {
+		input    string
+		paths    []string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			`{"a": {"x": "\u0041", "y": [1, 2]}, "b": [{"c": 1, "d": "e"}, {"c": 2}], "c": 3}`,
+			[]string{"b.*.c"},
+			"c 1 c 2",
+		},
+		{
+			`{"a": {"x": "\u0041", "y": [1, 2]}, "b": [{"c": 1, "d": "e"}, {"c": 2}], "c": 3}`,
+			[]string{"a", "c"},
+			"a { x : A , y : [ 1 , 2 ] } c 3",
+		},
+		{
+			`{"a": {"b": [true]}} {"a": {"b": null}} [{"a": {"b": 1}}]`,
+			[]string{"a.b"},
+			"b [ true ] b <nil>",
+		},
+		{`[1, 2] 3`, []string{""}, "[ 1 , 2 ] 3"},
+		{`[1, 2] 3`, []string{"0"}, "1"},
+		{`[1, 2] 3`, []string{"x"}, ""},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		var paths []Path
+		for _, p := range testcase.paths {
+			paths = append(paths, ParsePath(p))
+		}
+
+		l.SetBufSize(4)
+		l.SetSkipDelims(false)
+		l.SetPathFilter(paths...)
+
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("testcase '%s': %v", testcase.input, err)
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		if strings.Join(output, " ") != testcase.expected {
+			t.Errorf("testcase '%s' %v: got '%s', expected '%s'",
+				testcase.input, testcase.paths, strings.Join(output, " "), testcase.expected)
+		}
+	}
+}
+
+func TestJSONLexerSetQuotedNumbers(t *testing.T) {
+	type testCase struct {
+		input    string
+		paths    []Path
+		expected []json.Token
+	}
+
+	testCases := []testCase{
+		{
+			`{"a": "12", "b": "1.5", "12": "x", "c": "01", "d": "1e3", "e": "-0", "f": "1\u0032", "g": ""}`,
+			nil,
+			[]json.Token{"a", int64(12), "b", 1.5, "12", "x", "c", "01", "d", 1000.0, "e", int64(0),
+				"f", "12", "g", ""},
+		},
+		{
+			`{"id": "7", "name": "8", "items": [{"id": "9", "n": "10"}]}`,
+			[]Path{ParsePath("id"), ParsePath("items.*.id")},
+			[]json.Token{"id", int64(7), "name", "8", "items", "id", int64(9), "n", "10"},
+		},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetIntegers(true)
+		l.SetQuotedNumbers(true, testcase.paths...)
+		l.SetCopyStrings(true)
+
+		var output []json.Token
+
+		for {
+			token, err := l.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("testcase '%s': %v", testcase.input, err)
+			}
+
+			output = append(output, token)
+		}
+
+		if !reflect.DeepEqual(output, testcase.expected) {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.expected)
+		}
+	}
+}
+
+func TestJSONLexerSetQuotedBools(t *testing.T) {
+	type testCase struct {
+		input    string
+		paths    []Path
+		numbers  bool
+		expected []json.Token
+	}
+
+	testCases := []testCase{
+		{
+			`["true", "false", "1", "0", "True", "yes", "2", "tru\u0065", "1" , true]`,
+			nil,
+			true,
+			[]json.Token{true, false, true, false, "True", "yes", 2.0, "true", true, true},
+		},
+		{
+			`{"1": "1", "on": "1", "flags": {"a": "0", "b": "x"}, "n": "0"}`,
+			[]Path{ParsePath("on"), ParsePath("flags.*")},
+			false,
+			[]json.Token{"1", "1", "on", true, "flags", "a", false, "b", "x", "n", "0"},
+		},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetQuotedBools(true, testcase.paths...)
+		l.SetQuotedNumbers(testcase.numbers)
+		l.SetCopyStrings(true)
+
+		var output []json.Token
+
+		for {
+			token, err := l.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("testcase '%s': %v", testcase.input, err)
+			}
+
+			output = append(output, token)
+		}
+
+		if !reflect.DeepEqual(output, testcase.expected) {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.expected)
+		}
+	}
+}
+
+func TestJSONLexerTokenMatchesDecoder(t *testing.T) {
+	testcases := []string{
+		`{"a": 1.5, "b": [true, null, "x", {}], "c": {"d": []}}`,
+		`[1, "2"] "s" 12 false`,
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase, err)
+			continue
+		}
+
+		l.SetSkipDelims(false)
+
+		var got, expected []json.Token
+
+		for {
+			token, err := l.Token()
+			if err != nil {
+				break
+			}
+
+			if token == json.Delim(',') || token == json.Delim(':') {
+				continue // json.Decoder does not return these
+			}
+
+			got = append(got, token)
+		}
+
+		d := json.NewDecoder(strings.NewReader(testcase))
+		for {
+			token, err := d.Token()
+			if err != nil {
+				break
+			}
+
+			expected = append(expected, token)
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase, got, expected)
+		}
+	}
+}
+
+func TestJSONLexerInputOffset(t *testing.T) {
+	testcases := []string{
+		`{"a": 1, "b": [true, null, "x"]}`,
+		` [ 1.5e3 ,{} ]  "s" 12 `,
+		"{\"k\":\n\t\"\\u0041\"}\n[]",
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.SetSkipDelims(false)
+
+		d := json.NewDecoder(strings.NewReader(testcase))
+
+		for {
+			token, err := l.TokenFast()
+			if token.t == LexerTokenTypeDelim && (token.delim == ',' || token.delim == ':') {
+				continue // json.Decoder does not return these
+			}
+
+			_, decoderErr := d.Token()
+			if (err == nil) != (decoderErr == nil) {
+				t.Errorf("testcase '%s': got error %v, json.Decoder got %v", testcase, err, decoderErr)
+				break
+			}
+			if err != nil {
+				break
+			}
+
+			if l.InputOffset() != d.InputOffset() {
+				t.Errorf("testcase '%s': got offset %d after '%s', expected %d",
+					testcase, l.InputOffset(), printToken(token), d.InputOffset())
+			}
+		}
+	}
+}
+
+type jsonLexerMaxStringLenTestCase struct {
+	input string
+	limit int
+	path  string // path reported by StringTooLongError, "-" if input must be accepted
+}
+
+func TestJSONLexerSetMaxStringLen(t *testing.T) {
+	testcases := []jsonLexerMaxStringLenTestCase{
+		{`{"a": "abc", "b": ["x", "abcd"]}`, 4, "-"},
+		{`{"a": "abc", "b": ["x", "abcde"]}`, 4, "b.1"},
+		{`{"a": {"key.long": 1}}`, 4, `a.key\.long`},
+		{`{"a": 1} {"b": [{"c": "\u0041\u0042\u0043"}]}`, 3, "-"},
+		{`{"a": 1} {"b": [{"c": "\u0041\u0042\u0043\u0044"}]}`, 3, "b.0.c"},
+		{`"abcdef"`, 5, ""},
+		{`{"a": "abcdef"}`, 0, "-"},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.SetMaxStringLen(testcase.limit)
+
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				if testcase.path != "-" {
+					t.Errorf("testcase '%s': must have failed", testcase.input)
+				}
+
+				break
+			}
+			if err != nil {
+				var tooLong *StringTooLongError
+
+				switch {
+				case !errors.As(err, &tooLong):
+					t.Errorf("testcase '%s': %v", testcase.input, err)
+				case tooLong.Path != testcase.path:
+					t.Errorf("testcase '%s': got path '%s', expected '%s'",
+						testcase.input, tooLong.Path, testcase.path)
+				}
+
+				break
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		// strings must not be affected by tracking
+		if testcase.path == "-" && strings.Contains(testcase.input, `\u`) &&
+			!strings.Contains(strings.Join(output, " "), "ABC") {
+			t.Errorf("testcase '%s': got %v", testcase.input, output)
+		}
+	}
+}
+
+func TestJSONLexerSetMaxDepth(t *testing.T) {
+	type testCase struct {
+		input  string
+		limit  int
+		offset int64 // offset of the container exceeding the limit, -1 if none
+	}
+
+	testCases := []testCase{
+		{`[[1], {"a": [2]}]`, 3, -1},
+		{`[[1], {"a": [2]}]`, 2, 12},
+		{`{"a": 1} [[[]]]`, 2, 11},
+		{`[[[[[[[[`, 0, -1},
+		{`[] {} "a"`, 1, -1},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetMaxDepth(testcase.limit)
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+		}
+
+		var posErr *PositionError
+		var depthErr *DepthLimitError
+
+		switch {
+		case testcase.offset < 0 && errors.As(err, &depthErr):
+			t.Errorf("testcase '%s': unexpected error %v", testcase.input, err)
+		case testcase.offset < 0:
+		case !errors.As(err, &depthErr) || !errors.As(err, &posErr):
+			t.Errorf("testcase '%s': got error %v, expected *DepthLimitError", testcase.input, err)
+		case depthErr.Limit != testcase.limit || posErr.Offset != testcase.offset:
+			t.Errorf("testcase '%s': got %v at offset %d, expected limit %d at offset %d",
+				testcase.input, depthErr, posErr.Offset, testcase.limit, testcase.offset)
+		}
+	}
+}
+
+func TestJSONLexerSetMaxTokenSize(t *testing.T) {
+	type testCase struct {
+		input  string
+		limit  int
+		fails  bool
+		offset int64 // offset of the token exceeding the limit
+	}
+
+	huge := `["` + strings.Repeat("a", 1<<20) + `"]`
+
+	testCases := []testCase{
+		{`["abc", "abcdef"]`, 8, false, 0},
+		{`["abc", "abcdef"]`, 7, true, 8},
+		{`["a\tb"]`, 5, true, 1},
+		{`[123456789]`, 9, false, 0},
+		{`[123456789]`, 8, true, 1},
+		{`123456789`, 8, true, 0},
+		{huge, 100, true, 1},
+		{`[1, ` + huge + `]`, 100, true, 5},
+		{huge, 0, false, 0},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetMaxTokenSize(testcase.limit)
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+		}
+
+		name := testcase.input
+		if len(name) > 20 {
+			name = name[:20] + "..."
+		}
+
+		if fails := errors.Is(err, ErrTokenTooLarge); fails != testcase.fails {
+			t.Errorf("testcase '%s': got error %v", name, err)
+		}
+
+		var posErr *PositionError
+		if testcase.fails && errors.As(err, &posErr) && posErr.Offset != testcase.offset {
+			t.Errorf("testcase '%s': got error at offset %d, expected %d", name, posErr.Offset, testcase.offset)
+		}
+
+		if testcase.limit > 0 && len(l.buf) > 4*testcase.limit {
+			t.Errorf("testcase '%s': buffer has grown to %d", name, len(l.buf))
+		}
+	}
+}
+
+func TestJSONLexerSetMaxBufSize(t *testing.T) {
+	type testCase struct {
+		input  string
+		limit  int
+		offset int64 // offset of the token exceeding the limit, -1 if none
+	}
+
+	testCases := []testCase{
+		{`["abcdefgh"]`, 10, -1},
+		{`["abcdefghi"]`, 10, 1},
+		{`{"a": "x"} ["0123456789abc"]`, 10, 12},
+		{`[1234567]`, 6, 1},
+		{`["0123456789abc"]`, 0, -1},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetMaxBufSize(testcase.limit)
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+		}
+
+		var limitErr *BufferLimitError
+
+		switch {
+		case testcase.offset < 0 && err != io.EOF:
+			t.Errorf("testcase '%s': unexpected error %v", testcase.input, err)
+		case testcase.offset < 0:
+		case !errors.As(err, &limitErr):
+			t.Errorf("testcase '%s': got error %v, expected *BufferLimitError", testcase.input, err)
+		case limitErr.Offset != testcase.offset || limitErr.Limit != testcase.limit:
+			t.Errorf("testcase '%s': got %v, expected offset %d", testcase.input, limitErr, testcase.offset)
+		}
+
+		if testcase.limit > 0 && len(l.buf) > testcase.limit {
+			t.Errorf("testcase '%s': buffer has grown to %d", testcase.input, len(l.buf))
+		}
+	}
+}
+
+func TestJSONLexerSetMaxTokenSizeAndMaxBufSize(t *testing.T) {
+	type testCase struct {
+		tokenLimit int
+		bufLimit   int
+		err        error
+	}
+
+	testCases := []testCase{
+		{8, 64, ErrTokenTooLarge},
+		{64, 8, &BufferLimitError{}},
+		{64, 64, nil},
+	}
+
+	input := `[1, "0123456789abcdef"]`
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetMaxTokenSize(testcase.tokenLimit)
+		l.SetMaxBufSize(testcase.bufLimit)
+
+		for {
+			_, err = l.TokenFast()
+			if err != nil {
+				break
+			}
+		}
+
+		var limitErr *BufferLimitError
+		var posErr *PositionError
+
+		switch {
+		case testcase.err == nil && err != io.EOF:
+			t.Errorf("testcase %d/%d: unexpected error %v", testcase.tokenLimit, testcase.bufLimit, err)
+		case testcase.err == nil:
+		case testcase.err == ErrTokenTooLarge && !errors.Is(err, ErrTokenTooLarge),
+			testcase.err != ErrTokenTooLarge && !errors.As(err, &limitErr):
+			t.Errorf("testcase %d/%d: got error %v, expected %T", testcase.tokenLimit, testcase.bufLimit, err, testcase.err)
+		case !errors.As(err, &posErr) || posErr.Offset != 4:
+			t.Errorf("testcase %d/%d: got error %v, expected it at offset 4", testcase.tokenLimit, testcase.bufLimit, err)
+		}
+	}
+}
+
+type jsonLexerEmptyInputTestCase struct {
+	input  string
+	policy EmptyInputPolicy
+	err    error // error returned by the first Token() call
+}
+
+func TestJSONLexerEmptyInput(t *testing.T) {
+	testcases := []jsonLexerEmptyInputTestCase{
+		{``, EmptyInputEOF, io.EOF},
+		{` `, EmptyInputEOF, io.EOF},
+		{" \n\t \r\n  \n", EmptyInputEOF, io.EOF},
+		{``, EmptyInputError, ErrEmptyInput},
+		{` `, EmptyInputError, ErrEmptyInput},
+		{" \n\t \r\n  \n", EmptyInputError, ErrEmptyInput},
+		{` [] `, EmptyInputError, io.EOF}, // delimiters are skipped
+		{` 1`, EmptyInputError, nil},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%q': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+		l.SetEmptyInputPolicy(testcase.policy)
+
+		if _, err := l.Token(); err != testcase.err {
+			t.Errorf("testcase '%q': got error '%v', expected '%v'", testcase.input, err, testcase.err)
+			continue
+		}
+
+		if testcase.err != nil {
+			continue
+		}
+
+		// a document has been found, the end of input must be clean
+		for err == nil {
+			_, err = l.Token()
+		}
+
+		if err != io.EOF {
+			t.Errorf("testcase '%q': %v", testcase.input, err)
+		}
+	}
+}
+
+type jsonLexerNumberTestCase struct {
+	input  string
+	output float64
+}
+
+func TestJSONLexerNumbers(t *testing.T) {
+	testcases := []jsonLexerNumberTestCase{
+		{`[0]`, 0},
+		{`[-0]`, 0},
+		{`[42]`, 42},
+		{`[-42]`, -42},
+		{`[+42]`, 42},
+		{`[4.25]`, 4.25},
+		{`[-4.25]`, -4.25},
+		{`[.25]`, 0.25},
+		{`[-.25]`, -0.25},
+		{`[25.]`, 25},
+		{`[1e3]`, 1e3},
+		{`[1E3]`, 1e3},
+		{`[1e+3]`, 1e3},
+		{`[1e-3]`, 1e-3},
+		{`[-1.5e-3]`, -1.5e-3},
+		{`[1.e3]`, 1e3},
+		{"[1.5e3\n]", 1.5e3},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.SetBufSize(4)
+
+		token, err := l.TokenFast()
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if token.Type() != LexerTokenTypeNumber {
+			t.Errorf("testcase '%s': expected token of type %s, got %s",
+				testcase.input, LexerTokenTypeNumber, token.Type())
+			continue
+		}
+
+		if token.Number() != testcase.output {
+			t.Errorf("testcase '%s': expected %v, got %v",
+				testcase.input, testcase.output, token.Number())
+		}
+	}
+}
+
+type jsonLexerPrecisionLossTestCase struct {
+	input string
+	lossy bool
+}
+
+func TestJSONLexerPrecisionLoss(t *testing.T) {
+	testcases := []jsonLexerPrecisionLossTestCase{
+		{`0.1`, false},
+		{`-253`, false},
+		{`1234567890123456`, false},
+		{`1.0000000000000000000`, false},
+		{`1e300`, false},
+		{`0.000000000000000000001`, false},
+		{`9007199254740993`, true},
+		{`-9007199254740993`, true},
+		{`12345678901234567890`, true},
+		{`3.14159265358979323846`, true},
+		{`1.00000000000000000001e10`, true},
+	}
+
+	for _, testcase := range testcases {
+		input := "[" + testcase.input + "]"
+
+		for _, policy := range []PrecisionLossPolicy{PrecisionLossIgnore, PrecisionLossError, PrecisionLossRaw} {
+			l, err := NewJSONLexer(strings.NewReader(input))
+			if err != nil {
+				t.Errorf("testcase '%s': could not create lexer: %v", input, err)
+				continue
+			}
+
+			l.SetPrecisionLossPolicy(policy)
+
+			token, err := l.Token()
+
+			switch {
+			case policy == PrecisionLossError && testcase.lossy:
+				if err == nil {
+					t.Errorf("testcase '%s': must have failed", input)
+				}
+			case err != nil:
+				t.Errorf("testcase '%s': %v", input, err)
+			case policy == PrecisionLossRaw && testcase.lossy:
+				if token != json.Number(testcase.input) {
+					t.Errorf("testcase '%s': expected json.Number, got %v", input, token)
+				}
+			default:
+				if _, ok := token.(float64); !ok {
+					t.Errorf("testcase '%s': expected float64, got %v", input, token)
+				}
+			}
+		}
+	}
+}
+
+const (
+	jsonSample = ` {
+	  "type" : "row",
+	  "position" : 471,
+	  "clustering" : [ "1b5bf100-8f99-11ea-8e8d-fa163e4302ba" ],
+	  "liveness_info" : { "tstamp" : "2020-05-06T12:57:14.193447Z" },
+	  "cells" : [
+		{ "name" : "event_id", "value" : 253 },
+		{ "name" : "ip", "value" : "5.61.233.11" },
+		{ "name" : "is_valid", "value" : true },
+		{ "name" : "session_id", "value" : null },
+		{ "name" : "delta", "value" : 3.14 },
+		{ "name" : "temperature", "value" : -52 },
+		{ "name" : "distance", "value" : 1.57e10 },
+		{ "name" : "args", "deletion_info" : { "marked_deleted" : "2020-05-06T12:57:14.193446Z", "local_delete_time" : "2020-05-06T12:57:14Z" } },
+		{ "name" : "args", "path" : [ "f" ], "value" : "fdevmail.openstacklocal" },
+		{ "name" : "args", "path" : [ "h" ], "value" : "internal-api.devmail.ru" },
+		{ "name" : "args", "path" : [ "ip" ], "value" : "127.0.0.1" },
+		{ "name" : "args", "path" : [ "rid" ], "value" : "8c28ca1055" },
+		{ "name" : "args", "path" : [ "ua" ], "value" : "\"Go-http-client/1.1\"" },
+		{ "desc": "\u041f\u0440\u043e\u0432\u0435\u0440\u043a\u0430 \\UD83D\\UDCA9 \u043f\u043e\u0447\u0442\u044b"}
+	  ]
+	}`
+)
+
+func generateBenchmarkInput(b *bytes.Buffer, numObjects int) {
+	for i := 0; i < numObjects; i++ {
+		if i > 0 {
+			b.WriteRune(',')
+		}
+
+		b.WriteString(jsonSample)
+	}
+}
+
+func BenchmarkEncodingJSON(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		input := bytes.NewBuffer(nil)
+		input.WriteRune('[')
+		generateBenchmarkInput(input, 100)
+		input.WriteRune(']')
+		dec := json.NewDecoder(input)
+		b.StartTimer()
+
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Errorf("could not get next token: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkJSONLexer(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		input := bytes.NewBuffer(nil)
+		generateBenchmarkInput(input, 100)
+		l, err := NewJSONLexer(input)
+		if err != nil {
+			b.Errorf("could not create JSONLexer: %v", err)
+		}
+
+		b.StartTimer()
+
+		for {
+			_, err := l.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Errorf("could not get next token: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkJSONLexerFast(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+
+		input := bytes.Buffer{}
+		generateBenchmarkInput(&input, 100)
+
+		l, err := NewJSONLexer(&input)
+		if err != nil {
+			b.Errorf("could not create JSONLexer: %v", err)
+		}
+
+		l.SetBufSize(1024)
+
+		b.StartTimer()
+
+		for {
+			_, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Errorf("could not get next token: %v", err)
+			}
+		}
+	}
+}
+
+func TestJSONLexerNumberRaw(t *testing.T) {
+	input := `[1.570e+10, -0, 12, 9007199254740993, "1"]`
+	expected := []string{"1.570e+10", "-0", "12", "9007199254740993", ""}
+
+	for _, raw := range []bool{false, true} {
+		l, err := NewJSONLexer(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetRawNumbers(raw)
+
+		var output []string
+		var numbers []float64
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("raw %t: %v", raw, err)
+			}
+
+			output = append(output, StringDeepCopy(token.NumberRaw()))
+
+			if token.Type() == LexerTokenTypeNumber {
+				numbers = append(numbers, token.Number())
+			}
+		}
+
+		if !reflect.DeepEqual(output, expected) {
+			t.Errorf("raw %t: got %q, expected %q", raw, output, expected)
+		}
+
+		if !reflect.DeepEqual(numbers, []float64{1.57e10, 0, 12, 9007199254740992}) {
+			t.Errorf("raw %t: got numbers %v", raw, numbers)
+		}
+	}
+
+	l, _ := NewJSONLexer(strings.NewReader(`[1.570e+10, 9007199254740993]`))
+	l.SetRawNumbers(true)
+
+	var output []json.Token
+
+	for {
+		token, err := l.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, token)
+	}
+
+	expectedTokens := []json.Token{json.Number("1.570e+10"), json.Number("9007199254740993")}
+	if !reflect.DeepEqual(output, expectedTokens) {
+		t.Errorf("got %v, expected %v", output, expectedTokens)
+	}
+
+	token := NewTokenGenericFromNumber(1)
+	if token.NumberRaw() != "" {
+		t.Errorf("constructed number must have no textual form")
+	}
+}
+
+func TestJSONLexerIsKey(t *testing.T) {
+	input := `{"a": "b", "c": ["d", {"e": {}}, "f"], "g": {"h": "i"}} "j" {"k": 1}`
+	expected := []string{"a", "c", "e", "g", "h", "k"}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+
+	var keys []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if token.IsKey() {
+			keys = append(keys, token.StringCopy())
+		}
+	}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("got keys %v, expected %v", keys, expected)
+	}
+}
+
+func TestJSONLexerCurrentPath(t *testing.T) {
+	input := `{"cells": [{"value": 1}, {"a/b~": [true]}], "x": null} 5`
+
+	expected := []string{
+		"{ ''", "cells '/cells'", ": ''", "[ '/cells'", "{ '/cells/0'", "value '/cells/0/value'",
+		": '/cells/0'", "1 '/cells/0/value'", "} '/cells/0'", ", '/cells'", "{ '/cells/1'",
+		"a/b~ '/cells/1/a~1b~0'", ": '/cells/1'", "[ '/cells/1/a~1b~0'", "true '/cells/1/a~1b~0/0'", "] '/cells/1/a~1b~0'",
+		"} '/cells/1'", "] '/cells'", ", ''", "x '/x'", ": ''", "<nil> '/x'", "} ''", "5 ''",
+	}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+	l.SetSkipDelims(false)
+	l.SetTrackPath(true)
+
+	var output []string
+	var matched []string
+
+	for {
+		token, err := l.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, fmt.Sprintf("%v '%s'", token, l.CurrentPointer()))
+
+		if l.MatchesPath(ParsePath("cells.*.value")) {
+			matched = append(matched, strings.Join(l.CurrentPath(), "|"))
+		}
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %q, expected %q", output, expected)
+	}
+
+	if !reflect.DeepEqual(matched, []string{"cells|0|value", "cells|0|value"}) {
+		t.Errorf("got matched paths %q", matched)
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader(`{"a": 1}`))
+	l.TokenFast()
+
+	if l.CurrentPath() != nil || l.CurrentPointer() != "" || l.MatchesPath(Path{}) {
+		t.Errorf("path must not be tracked by default")
+	}
+}
+
+func TestJSONLexerClose(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(`{"a": "b"} [1]`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	token, err := l.TokenFast()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("could not close lexer: %v", err)
+	}
+
+	if l.buf != nil {
+		t.Errorf("buffer must have been released")
+	}
+
+	if err := l.Validate(token); err != ErrStaleString {
+		t.Errorf("tokens returned before closing must be stale, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.TokenFast(); err != ErrClosed {
+			t.Errorf("got %v, expected ErrClosed", err)
+		}
+
+		l.Recover()
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("closing twice must not fail: %v", err)
+	}
+}
+
+func TestJSONLexerSkipValue(t *testing.T) {
+	input := `{"skip": {"a": [1, {"b": "é"}], "c": null}, "keep": 1, "s": "x", "arr": [[], 2], "last": true} 7 [8]`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+
+	var output []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, printToken(token))
+
+		if token.IsKey() && !token.StringEquals("keep") {
+			if err := l.SkipValue(); err != nil {
+				t.Fatalf("could not skip value of '%s': %v", output[len(output)-1], err)
+			}
+		}
+	}
+
+	expected := []string{"skip", "keep", "1", "s", "arr", "last", "7", "8"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader(`[1, [2]] 3`))
+	for _, expected := range []error{nil, nil, io.EOF} {
+		if err := l.SkipValue(); err != expected {
+			t.Errorf("got %v, expected %v", err, expected)
+		}
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader(`[]`))
+	l.SetSkipDelims(false)
+	l.TokenFast()
+
+	if err := l.SkipValue(); err == nil {
+		t.Errorf("skipping ']' must have failed")
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader(`{"a": [1, 2`))
+	l.TokenFast()
+
+	if err := l.SkipValue(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, expected unexpected EOF", err)
+	}
+}
+
+func TestJSONLexerPeek(t *testing.T) {
+	input := `{"id": 15, "tags": ["a", "b"], "ok": true}`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+	l.SetSkipDelims(false)
+
+	var output []string
+
+	for {
+		peeked, peekErr := l.PeekFast()
+		if _, err := l.PeekFast(); err != peekErr {
+			t.Fatalf("peeking twice returned %v and %v", peekErr, err)
+		}
+
+		token, err := l.TokenFast()
+		if err != peekErr {
+			t.Fatalf("got error %v, peeked %v", err, peekErr)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if printToken(token) != printToken(peeked) {
+			t.Errorf("got '%s', peeked '%s'", printToken(token), printToken(peeked))
+		}
+
+		output = append(output, printToken(token))
+	}
+
+	expected := []string{"{", "id", ":", "15", ",", "tags", ":", "[", "a", ",", "b", "]", ",", "ok", ":", "true", "}"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader(`{"tags": ["a", {"b": 1}], "id": 15} 2`))
+	l.SetSkipDelims(false)
+	for i := 0; i < 3; i++ {
+		l.TokenFast()
+	}
+
+	if token, err := l.Peek(); err != nil || token != json.Delim('[') {
+		t.Errorf("got %v (%v), expected '['", token, err)
+	}
+
+	if err := l.SkipValue(); err != nil {
+		t.Fatalf("could not skip peeked value: %v", err)
+	}
+
+	output = output[:0]
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, printToken(token))
+	}
+
+	expected = []string{",", "id", ":", "15", "}", "2"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	l, _ = NewJSONLexer(strings.NewReader("[1x, 2]"))
+	if _, err := l.PeekFast(); err == nil {
+		t.Fatalf("peeking '1x' must have failed")
+	}
+
+	l.Recover()
+
+	if token, err := l.TokenFast(); err != nil || printToken(token) != "2" {
+		t.Errorf("got '%s' (%v) after Recover, expected '2'", printToken(token), err)
+	}
+}
+
+func TestJSONLexerMore(t *testing.T) {
+	testcases := []struct {
+		input      string
+		skipDelims bool
+		output     string // tokens read while More() returns true, '|' when it returns false
+	}{
+		{`[1, "a", null]`, true, `1 a <nil> |`},
+		{`[]`, true, `|`},
+		{`{"a": 1, "b": [2], "c": 3}`, true, `a 1 b 2 | c 3 |`},
+		{`[1, "a"] [2]`, true, `1 a | 2 |`},
+		{`[1, "a", null]`, false, `[ 1 , a , <nil> | ] |`},
+		{`[[], {}]`, false, `[ [ | ] , { | } | ] |`},
+	}
+
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetSkipDelims(testcase.skipDelims)
+
+		var output []string
+
+		for i := 0; i < 100; i++ {
+			if !l.More() {
+				output = append(output, "|")
+			}
+
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("testcase '%s': %v", testcase.input, err)
+			}
+
+			output = append(output, printToken(token))
+		}
+
+		if got := strings.Join(output, " "); got != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'", testcase.input, got, testcase.output)
+		}
+	}
+}
+
+// repeatingReader returns chunk n times
+type repeatingReader struct {
+	chunk string
+	n     int
+	pos   int // position in the current chunk
+}
+
+func (r *repeatingReader) Read(p []byte) (int, error) {
+	read := 0
+
+	for read < len(p) && r.n > 0 {
+		c := copy(p[read:], r.chunk[r.pos:])
+		read += c
+		r.pos += c
+
+		if r.pos == len(r.chunk) {
+			r.pos = 0
+			r.n--
+		}
+	}
+
+	if read == 0 {
+		return 0, io.EOF
+	}
+
+	return read, nil
+}
+
+func TestJSONLexerHugeInput(t *testing.T) {
+	// lexing more than 2GB takes tens of seconds, positions near the limits of int32 are
+	// also covered by TestJSONLexerPositionOverflow
+	if os.Getenv("GOJSONLEX_HUGE_TESTS") == "" {
+		t.Skip("lexing more than 2GB of input, set GOJSONLEX_HUGE_TESTS=1 to run")
+	}
+
+	chunk := `"abcdefghijklmnopqrstuvwx", `
+	n := math.MaxInt32/len(chunk) + 1
+	size := 1 + int64(n)*int64(len(chunk)) + int64(len("1]"))
+
+	l, err := NewJSONLexer(io.MultiReader(
+		strings.NewReader("["), &repeatingReader{chunk: chunk, n: n}, strings.NewReader("1] [")))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	tokens := 0
+
+	for {
+		_, err = l.TokenFast()
+		if err != nil {
+			break
+		}
+
+		tokens++
+	}
+
+	if tokens != n+1 {
+		t.Errorf("got %d tokens, expected %d", tokens, n+1)
+	}
+
+	var eofErr *UnexpectedEOFError
+	if !errors.As(err, &eofErr) {
+		t.Fatalf("got error %v, expected *UnexpectedEOFError", err)
+	}
+
+	if eofErr.Offset != size+2 || eofErr.Line != 1 || eofErr.Column != clampInt(size+3) {
+		t.Errorf("got position %d:%d (offset %d), expected 1:%d (offset %d)",
+			eofErr.Line, eofErr.Column, eofErr.Offset, clampInt(size+3), size+2)
+	}
+}
+
+func TestJSONLexerPositionOverflow(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.line, l.lineStart = 5<<30, 1<<30
+
+	expectedLine, expectedColumn := int64(5<<30+1), int64(9<<30+1)
+	if strconv.IntSize == 32 {
+		expectedLine, expectedColumn = math.MaxInt32, math.MaxInt32
+	}
+
+	line, column := l.position(5 << 31)
+	if int64(line) != expectedLine || int64(column) != expectedColumn {
+		t.Errorf("got position %d:%d, expected %d:%d", line, column, expectedLine, expectedColumn)
+	}
+}
+
+func TestJSONLexerReset(t *testing.T) {
+	l, err := NewJSONLexer(strings.NewReader(`{"a": [1, "b"`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetSkipDelims(false)
+
+	lexAll := func() ([]string, error) {
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				return output, nil
+			}
+			if err != nil {
+				return output, err
+			}
+
+			output = append(output, printToken(token))
+		}
+	}
+
+	if _, err := lexAll(); err == nil {
+		t.Fatalf("truncated input must have failed")
+	}
+
+	buf := &l.buf[0]
+
+	l.Reset(strings.NewReader(`{"c": true} "d"`))
+
+	output, err := lexAll()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []string{"{", "c", ":", "true", "}", "d"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	if &l.buf[0] != buf {
+		t.Errorf("buffer has not been reused")
+	}
+
+	l.Close()
+	l.Reset(strings.NewReader(`[null]`))
+
+	output, err = lexAll()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// closing drops the settings
+	expected = []string{"<nil>"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+}
+
+func TestJSONLexerResetAllocs(t *testing.T) {
+	if !zeroCopyStrings {
+		t.Skip("strings are copied in purego builds")
+	}
+
+	input := []byte(`{"type": "update", "values": [1.5, "xy", true, null]}`)
+	r := bytes.NewReader(input)
+
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(input)
+		l.Reset(r)
+
+		for {
+			if _, err := l.TokenFast(); err != nil {
+				break
+			}
+		}
+	})
+
+	if allocs != 0 {
+		t.Errorf("got %v allocations per input, expected 0", allocs)
+	}
 }
@@ -0,0 +1,86 @@
+package gojsonlex
+
+import (
+	"io"
+	"sync"
+)
+
+// resetBytes prepares the lexer for parsing the given data keeping its settings, data is
+// copied into the buffer of the lexer
+func (l *JSONLexer) resetBytes(data []byte) {
+	l.reset(nil)
+
+	if cap(l.buf) < len(data) {
+		l.buf = make([]byte, 0, len(data))
+	}
+
+	l.buf = append(l.buf[:0], data...)
+	l.readingFinished = true
+	l.state = stateLexerSkipping
+}
+
+// MessageParser lexes many small independent documents (e.g. messages of a WebSocket API)
+// reusing lexers from a pool, so that parsing a message does not allocate. MessageParser
+// is safe for concurrent use.
+type MessageParser struct {
+	configure func(*JSONLexer)
+	pool      sync.Pool
+}
+
+// NewMessageParser creates a new MessageParser. configure (if not nil) is called for every
+// new lexer, it is the place to apply settings (dialect, limits etc.) with setters.
+func NewMessageParser(configure func(*JSONLexer)) *MessageParser {
+	return &MessageParser{configure: configure}
+}
+
+func (p *MessageParser) getLexer() *JSONLexer {
+	if l, ok := p.pool.Get().(*JSONLexer); ok {
+		return l
+	}
+
+	l, _ := NewJSONLexer(nil)
+	if p.configure != nil {
+		p.configure(l)
+	}
+
+	return l
+}
+
+// putLexer returns the lexer to the pool, memory grown by a large message is not kept
+func (p *MessageParser) putLexer(l *JSONLexer) {
+	l.releaseMemory()
+	p.pool.Put(l)
+}
+
+// ParseMessage lexes msg calling fn for every token along with its context, strings are
+// valid until fn returns. The context has the path of the token only if configure calls
+// SetTrackPath(true). Parsing stops at the first error returned by fn, which is returned
+// as is. msg is not modified.
+func (p *MessageParser) ParseMessage(msg []byte, fn func(TokenGeneric, TokenContext) error) error {
+	l := p.getLexer()
+	defer p.putLexer(l)
+
+	l.resetBytes(msg)
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(token, l.Context()); err != nil {
+			return err
+		}
+	}
+}
+
+var defaultMessageParser = NewMessageParser(nil)
+
+// ParseMessage lexes msg with default settings calling fn for every token, see
+// MessageParser.ParseMessage
+func ParseMessage(msg []byte, fn func(TokenGeneric, TokenContext) error) error {
+	return defaultMessageParser.ParseMessage(msg, fn)
+}
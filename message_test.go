@@ -0,0 +1,136 @@
+package gojsonlex
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type parseMessageTestCase struct {
+	input  string
+	output string
+	isErr  bool
+}
+
+func TestParseMessage(t *testing.T) {
+	testcases := []parseMessageTestCase{
+		{`{"type": "ping", "seq": 1}`, "{ type : ping , seq : 1 }", false},
+		{`["a\nb", true, null]`, "[ a\nb , true , <nil> ]", false},
+		{`42`, "42", false},
+		{``, "", false},
+		{`{"a": tru}`, "", true},
+		{`{"a": 1`, "", true},
+		{`{"key": "` + strings.Repeat("x", 3*defaultBufSize) + `"}`,
+			"{ key : " + strings.Repeat("x", 3*defaultBufSize) + " }", false},
+	}
+
+	p := NewMessageParser(func(l *JSONLexer) {
+		l.SetSkipDelims(false)
+	})
+
+	for _, testcase := range testcases {
+		msg := []byte(testcase.input)
+
+		var output []string
+
+		err := p.ParseMessage(msg, func(token TokenGeneric, _ TokenContext) error {
+			output = append(output, printToken(token))
+			return nil
+		})
+		if testcase.isErr {
+			if err == nil {
+				t.Errorf("testcase '%s': must have failed", testcase.input)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if strings.Join(output, " ") != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, strings.Join(output, " "), testcase.output)
+		}
+
+		if string(msg) != testcase.input {
+			t.Errorf("testcase '%s': message has been modified", testcase.input)
+		}
+	}
+}
+
+func TestParseMessageStops(t *testing.T) {
+	errStop := errors.New("stop")
+	tokens := 0
+
+	err := ParseMessage([]byte(`{"a": 1, "b": 2}`), func(TokenGeneric, TokenContext) error {
+		tokens++
+		return errStop
+	})
+	if err != errStop || tokens != 1 {
+		t.Errorf("got error %v after %d tokens, expected %v after 1 token", err, tokens, errStop)
+	}
+}
+
+func TestParseMessageAllocs(t *testing.T) {
+	if !zeroCopyStrings {
+		t.Skip("strings are copied in purego builds")
+	}
+
+	msg := []byte(`{"type": "update", "values": [1.5, "x\ty", true, null]}`)
+
+	var tokens int
+	fn := func(TokenGeneric, TokenContext) error {
+		tokens++
+		return nil
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := ParseMessage(msg, fn); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Errorf("got %v allocations per message, expected 0", allocs)
+	}
+}
+
+func TestParseMessageContext(t *testing.T) {
+	p := NewMessageParser(func(l *JSONLexer) {
+		l.SetTrackPath(true)
+	})
+
+	var output []string
+
+	err := p.ParseMessage([]byte(`{"a": [1, {"b": true}]}`), func(token TokenGeneric, ctx TokenContext) error {
+		output = append(output, fmt.Sprintf("%s@%d:%d", ctx.Pointer(), ctx.Offset(), ctx.Depth()))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []string{"/a@1:1", "/a/0@7:2", "/a/1/b@11:3", "/a/1/b@16:3"}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+}
+
+func TestParseMessageReleasesMemory(t *testing.T) {
+	p := NewMessageParser(nil)
+
+	msg := []byte(`["` + strings.Repeat("x", 4*defaultBufSize) + `"]`)
+	if err := p.ParseMessage(msg, func(TokenGeneric, TokenContext) error { return nil }); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	l := p.getLexer()
+	if len(l.buf) > defaultBufSize {
+		t.Errorf("pooled lexer keeps %d bytes", len(l.buf))
+	}
+}
@@ -0,0 +1,85 @@
+package gojsonlex
+
+import (
+	"io"
+	"sort"
+)
+
+// sourcesReader is the logical concatenation of several readers like io.MultiReader,
+// it additionally remembers at which offset of the stream each reader starts
+type sourcesReader struct {
+	rs     []io.Reader
+	curr   int     // index of the reader being read
+	starts []int64 // stream offsets at which the readers reached so far start
+	offset int64   // stream offset of the next byte to be read
+}
+
+func newSourcesReader(rs []io.Reader) *sourcesReader {
+	return &sourcesReader{
+		rs:     rs,
+		starts: []int64{0},
+	}
+}
+
+func (s *sourcesReader) Read(p []byte) (int, error) {
+	for s.curr < len(s.rs) {
+		n, err := s.rs[s.curr].Read(p)
+		s.offset += int64(n)
+
+		if err == io.EOF {
+			s.curr++
+			s.starts = append(s.starts, s.offset)
+
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+
+	return 0, io.EOF
+}
+
+// locate converts the given stream offset to the index of the reader containing it and
+// the offset inside that reader
+func (s *sourcesReader) locate(offset int64) (int, int64) {
+	// the last reader which starts at or before offset, empty readers are skipped
+	i := sort.Search(len(s.starts), func(i int) bool { return s.starts[i] > offset }) - 1
+	if i >= len(s.rs) {
+		i = len(s.rs) - 1
+	}
+
+	return i, offset - s.starts[i]
+}
+
+// NewJSONLexerFromReaders creates a new JSONLexer reading the given readers sequentially
+// as a single stream (e.g. parts of a file downloaded in segments). Tokens may span
+// boundaries of the readers, TokenProvenance tells which reader a token comes from.
+func NewJSONLexerFromReaders(rs ...io.Reader) (*JSONLexer, error) {
+	sources := newSourcesReader(rs)
+
+	l, err := NewJSONLexer(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	l.sources = sources
+
+	return l, nil
+}
+
+// TokenProvenance returns the index of the reader in which the last returned token starts
+// along with the offset of the token in that reader. For lexers not created with
+// NewJSONLexerFromReaders the only reader has index 0.
+func (l *JSONLexer) TokenProvenance() (source int, offset int64) {
+	start, _ := l.currTokenOffsets()
+
+	if l.sources == nil {
+		return 0, start
+	}
+
+	return l.sources.locate(start)
+}
@@ -0,0 +1,112 @@
+package gojsonlex
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func readPrintedTokens(l *JSONLexer) ([]string, error) {
+	var output []string
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			return output, nil
+		}
+		if err != nil {
+			return output, err
+		}
+
+		output = append(output, printToken(token))
+	}
+}
+
+func TestJSONLexerReaderSeams(t *testing.T) {
+	input := `{"hello": "wörld\n", "n": [-1.5e3, 0, 42], "t": true, "f": false, "z": null}`
+
+	l, _ := NewJSONLexer(strings.NewReader(input))
+	l.SetSkipDelims(false)
+
+	expected, err := readPrintedTokens(l)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for i := 0; i <= len(input); i++ {
+		for j := i; j <= len(input); j++ {
+			parts := []string{input[:i], input[i:j], input[j:]}
+
+			readers := make([]io.Reader, 0, len(parts))
+			for _, part := range parts {
+				readers = append(readers, iotest.HalfReader(strings.NewReader(part)))
+			}
+
+			l, err := NewJSONLexerFromReaders(readers...)
+			if err != nil {
+				t.Fatalf("could not create lexer: %v", err)
+			}
+
+			l.SetBufSize(4)
+			l.SetSkipDelims(false)
+
+			output, err := readPrintedTokens(l)
+			if err != nil {
+				t.Errorf("testcase %q: %v", parts, err)
+				continue
+			}
+
+			if strings.Join(output, " ") != strings.Join(expected, " ") {
+				t.Errorf("testcase %q: got %v, expected %v", parts, output, expected)
+			}
+		}
+	}
+}
+
+type tokenProvenanceTestCase struct {
+	token  string
+	source int
+	offset int64
+}
+
+func TestTokenProvenance(t *testing.T) {
+	l, err := NewJSONLexerFromReaders(
+		strings.NewReader(`{"a": 1,`),
+		strings.NewReader(``),
+		strings.NewReader(` "b": "xy`),
+		strings.NewReader(`z"} `),
+		strings.NewReader(`null`),
+	)
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetBufSize(4)
+
+	expected := []tokenProvenanceTestCase{
+		{"a", 0, 1},
+		{"1", 0, 6},
+		{"b", 2, 1},
+		{"xyz", 2, 6},
+		{"<nil>", 4, 0},
+	}
+
+	for _, testcase := range expected {
+		token, err := l.TokenFast()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		source, offset := l.TokenProvenance()
+
+		if printToken(token) != testcase.token || source != testcase.source || offset != testcase.offset {
+			t.Errorf("got '%s' at %d:%d, expected '%s' at %d:%d", printToken(token), source, offset,
+				testcase.token, testcase.source, testcase.offset)
+		}
+	}
+
+	if _, err := l.TokenFast(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package ndjson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// fnv64a updates the FNV-1a hash h with the type tag of a value followed by its data
+func fnv64a(h uint64, tag byte, data []byte) uint64 {
+	h ^= uint64(tag)
+	h *= fnvPrime64
+
+	for _, c := range data {
+		h ^= uint64(c)
+		h *= fnvPrime64
+	}
+
+	return h
+}
+
+// mix64 is the finalizer of splitmix64, it spreads the entropy of x over all bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+func hashScalar(t *gojsonlex.TokenGeneric) uint64 {
+	h := uint64(fnvOffset64)
+
+	switch t.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		h = fnv64a(h, 's', t.BytesUnsafe())
+	case gojsonlex.LexerTokenTypeNumber:
+		var canonical [64]byte
+
+		// numbers are hashed by their text, so that they are not rounded to float64
+		raw := t.NumberRaw()
+		if raw == "" {
+			raw = strconv.FormatFloat(t.Number(), 'e', -1, 64)
+		}
+
+		h = fnv64a(h, 'n', jsonutil.AppendCanonicalNumber(canonical[:0], raw))
+	case gojsonlex.LexerTokenTypeBool:
+		if t.Bool() {
+			h = fnv64a(h, 't', nil)
+		} else {
+			h = fnv64a(h, 'f', nil)
+		}
+	case gojsonlex.LexerTokenTypeNull:
+		h = fnv64a(h, 'z', nil)
+	}
+
+	return mix64(h)
+}
+
+type hashFrame struct {
+	isObject bool
+	acc      uint64 // hash of the members (or elements) so far
+	key      uint64 // hash of the current key in the object
+}
+
+// valueHasher computes a 64-bit hash of a JSON value from its tokens. The hash is canonical:
+// it does not depend on whitespaces, escaping, formatting of numbers and the order of
+// object members.
+type valueHasher struct {
+	frames []hashFrame
+}
+
+// feed processes the next token of the value, once the value is complete its hash is
+// returned and done is set to true
+func (h *valueHasher) feed(t *gojsonlex.TokenGeneric, role gojsonlex.TokenRole) (hash uint64, done bool) {
+	var v uint64
+
+	switch role {
+	case gojsonlex.TokenRoleSeparator:
+		return 0, false
+	case gojsonlex.TokenRoleKey:
+		h.frames[len(h.frames)-1].key = hashScalar(t)
+		return 0, false
+	case gojsonlex.TokenRoleOpen:
+		h.frames = append(h.frames, hashFrame{isObject: t.Delim() == '{'})
+		return 0, false
+	case gojsonlex.TokenRoleClose:
+		frame := h.frames[len(h.frames)-1]
+		h.frames = h.frames[:len(h.frames)-1]
+
+		if frame.isObject {
+			v = mix64(frame.acc ^ 0x6f626a656374) // "object"
+		} else {
+			v = mix64(frame.acc ^ 0x6172726179) // "array"
+		}
+	case gojsonlex.TokenRoleScalar:
+		v = hashScalar(t)
+	}
+
+	if len(h.frames) == 0 {
+		return v, true
+	}
+
+	frame := &h.frames[len(h.frames)-1]
+
+	if frame.isObject {
+		// the sum does not depend on the order of members
+		frame.acc += mix64(frame.key ^ mix64(v+0x9e3779b97f4a7c15))
+	} else {
+		frame.acc = mix64(frame.acc*fnvPrime64 + v)
+	}
+
+	return 0, false
+}
+
+// seenSet remembers hashes of values
+type seenSet interface {
+	// add adds the hash reporting whether it has already been added before
+	add(hash uint64) bool
+}
+
+// exactSeenSet keeps all hashes, distinct values are reported as seen only if their
+// 64-bit hashes collide
+type exactSeenSet map[uint64]struct{}
+
+func (s exactSeenSet) add(hash uint64) bool {
+	if _, ok := s[hash]; ok {
+		return true
+	}
+
+	s[hash] = struct{}{}
+
+	return false
+}
+
+// bloomFilterHashes is the number of hash functions used by bloomFilter
+const bloomFilterHashes = 4
+
+type bloomFilter struct {
+	bits []uint64
+}
+
+func newBloomFilter(sizeBytes int) *bloomFilter {
+	return &bloomFilter{
+		bits: make([]uint64, (sizeBytes+7)/8),
+	}
+}
+
+func (f *bloomFilter) add(hash uint64) bool {
+	m := uint64(len(f.bits)) * 64
+	h2 := mix64(hash) | 1 // double hashing
+
+	seen := true
+
+	for i := uint64(0); i < bloomFilterHashes; i++ {
+		bit := (hash + i*h2) % m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+
+		if f.bits[word]&mask == 0 {
+			seen = false
+			f.bits[word] |= mask
+		}
+	}
+
+	return seen
+}
+
+// Dedup reads top-level values (e.g. NDJSON records) from src and writes to dst, one per
+// line, only the first record for every distinct value at path key inside records. Values
+// are identified by their canonical 64-bit hash, so e.g. {"a": 1, "b": 2} and {"b":2,"a":1.0}
+// are the same. An empty key deduplicates records by their whole content. Records without
+// the key are always written. Records are copied as is.
+//
+// If bloomFilterBytes is 0, hashes of all distinct values are kept in memory. Only the
+// hashes are compared, so this mode is probabilistic as well, although a unique record is
+// dropped only on a collision of 64-bit hashes: with a probability of about n^2/2^65 for
+// n distinct values (less than 3e-8 for a million values). Otherwise
+// a Bloom filter of the given size is used, it bounds the memory but drops a fraction of
+// unique records: about 2.5% with 1 byte of the filter per distinct value and about 0.25%
+// with 2 bytes.
+func Dedup(dst io.Writer, src io.Reader, key gojsonlex.Path, bloomFilterBytes int) error {
+	if bloomFilterBytes < 0 {
+		return fmt.Errorf("invalid Bloom filter size %d", bloomFilterBytes)
+	}
+
+	s, err := gojsonlex.NewRecordScanner(src, gojsonlex.Path{})
+	if err != nil {
+		return err
+	}
+
+	var seen seenSet = exactSeenSet{}
+	if bloomFilterBytes > 0 {
+		seen = newBloomFilter(bloomFilterBytes)
+	}
+
+	hasher := valueHasher{}
+
+	var hash uint64
+	var hashing, found bool
+
+	s.OnToken(func(tr *gojsonlex.PathTracker, t *gojsonlex.TokenGeneric, role gojsonlex.TokenRole) {
+		if found || !hashing && !(role.StartsValue() && tr.Matches(key)) {
+			return
+		}
+
+		hashing = true
+		hash, found = hasher.feed(t, role)
+	})
+
+	w := bufio.NewWriter(dst)
+
+	for {
+		hashing, found = false, false
+
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if found && seen.add(hash) {
+			continue
+		}
+
+		w.Write(s.Raw())
+		w.WriteByte('\n')
+	}
+
+	return w.Flush()
+}
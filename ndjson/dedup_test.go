@@ -0,0 +1,86 @@
+package ndjson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type dedupTestCase struct {
+	input  string
+	key    string
+	output string
+}
+
+func TestDedup(t *testing.T) {
+	testcases := []dedupTestCase{
+		{"", "id", ""},
+		{
+			"{\"id\": 1, \"n\": 1}\n{\"id\": 2, \"n\": 2}\n{\"id\": 1, \"n\": 3}\n{\"n\": 4}\n{\"n\": 5}\n{\"id\": 1.0, \"n\": 6}\n",
+			"id",
+			"{\"id\": 1, \"n\": 1}\n{\"id\": 2, \"n\": 2}\n{\"n\": 4}\n{\"n\": 5}\n",
+		},
+		{
+			`{"id": "a"} {"id": "a"} {"id": "b"} {"id": ["a"]} {"id": {"x": "a"}} {"id": null}`,
+			"id",
+			"{\"id\": \"a\"}\n{\"id\": \"b\"}\n{\"id\": [\"a\"]}\n{\"id\": {\"x\": \"a\"}}\n{\"id\": null}\n",
+		},
+		{
+			`{"a": 1, "b": [1, 2]} {"b":[1,2],"a":1} {"a": 1, "b": [2, 1]} {"a": 1} {"a": 1, "a": 1}`,
+			"",
+			"{\"a\": 1, \"b\": [1, 2]}\n{\"a\": 1, \"b\": [2, 1]}\n{\"a\": 1}\n{\"a\": 1, \"a\": 1}\n",
+		},
+		{
+			`{"e": {"id": 1}} {"e": {"id": 2}} {"e": {"id": 1, "x": 0}}`,
+			"e.id",
+			"{\"e\": {\"id\": 1}}\n{\"e\": {\"id\": 2}}\n",
+		},
+		{`1 2 1 "1" true true`, "", "1\n2\n\"1\"\ntrue\n"},
+		{
+			`{"event_id": 9007199254740993} {"event_id": 9007199254740992} {"event_id": 9007199254740993}`,
+			"event_id",
+			"{\"event_id\": 9007199254740993}\n{\"event_id\": 9007199254740992}\n",
+		},
+		{`100 1e2 100.0e0 0.1E3 -0 0 0.00 1e-2 0.010 -1`, "", "100\n-0\n1e-2\n-1\n"},
+	}
+
+	for _, testcase := range testcases {
+		for _, bloomFilterBytes := range []int{0, 1024} {
+			out := &bytes.Buffer{}
+
+			err := Dedup(out, strings.NewReader(testcase.input), gojsonlex.ParsePath(testcase.key), bloomFilterBytes)
+			if err != nil {
+				t.Errorf("testcase '%s': %v", testcase.input, err)
+				continue
+			}
+
+			if out.String() != testcase.output {
+				t.Errorf("testcase '%s' (bloom %d): got '%s', expected '%s'",
+					testcase.input, bloomFilterBytes, out.String(), testcase.output)
+			}
+		}
+	}
+}
+
+func TestDedupBloomFilter(t *testing.T) {
+	input := &bytes.Buffer{}
+
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(input, "{\"id\": %d}\n{\"id\": %d}\n", i, i/2)
+	}
+
+	out := &bytes.Buffer{}
+
+	// 2 bytes per distinct value
+	if err := Dedup(out, input, gojsonlex.ParsePath("id"), 20000); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	unique := strings.Count(out.String(), "\n")
+	if unique > 10000 || unique < 9900 {
+		t.Errorf("got %d unique records, expected about 10000", unique)
+	}
+}
@@ -0,0 +1,171 @@
+package ndjson
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+// objectRecorder records members of top-level objects scanned by a RecordScanner
+type objectRecorder struct {
+	gojsonlex.TokenRecorder
+	memberStarts []int // indices of tokens starting members
+	isObject     bool  // reports whether the last record is an object
+}
+
+// onToken records the token of a record omitting the enclosing braces and separators
+func (r *objectRecorder) onToken(tr *gojsonlex.PathTracker, t *gojsonlex.TokenGeneric, role gojsonlex.TokenRole) {
+	switch {
+	case role == gojsonlex.TokenRoleSeparator:
+		return
+	case tr.Depth() == 0 && role != gojsonlex.TokenRoleClose:
+		// top-level scalar
+		r.isObject = false
+		return
+	case tr.Depth() == 1 && role == gojsonlex.TokenRoleOpen:
+		r.isObject = t.Delim() == '{'
+		return
+	case tr.Depth() == 0:
+		return
+	case tr.Depth() == 1 && role == gojsonlex.TokenRoleKey:
+		r.memberStarts = append(r.memberStarts, len(r.Tokens()))
+	}
+
+	r.Record(*t)
+}
+
+// joinRecord is a record of the buffered side of a join
+type joinRecord struct {
+	tokensEnd                int // index of the token following the record in the recorder
+	membersStart, membersEnd int // range of memberStarts in the recorder
+}
+
+// Join performs an inner join of two streams of top-level objects (e.g. NDJSON records).
+// For every record of big and every record of small having equal scalars at paths bigKey
+// and smallKey respectively it writes to dst an object with all members of the big record
+// followed by the members of the small record which keys are not present in the big one.
+// Records of small are buffered in memory, while records of big are streamed one by one,
+// the output follows the order of big. Records without a scalar key are skipped. The
+// output is compact JSON, one object per line.
+func Join(dst io.Writer, big io.Reader, bigKey gojsonlex.Path, small io.Reader, smallKey gojsonlex.Path) error {
+	smallSide := objectRecorder{}
+
+	index, err := buildJoinIndex(&smallSide, small, smallKey)
+	if err != nil {
+		return err
+	}
+
+	s, err := gojsonlex.NewRecordScanner(big, gojsonlex.Path{}, bigKey)
+	if err != nil {
+		return err
+	}
+
+	bigSide := objectRecorder{}
+	s.OnToken(bigSide.onToken)
+
+	w := gojsonlex.NewTokenWriter(dst)
+
+	for {
+		bigSide.Reset()
+		bigSide.memberStarts = bigSide.memberStarts[:0]
+
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if !bigSide.isObject {
+			return fmt.Errorf("record is not an object")
+		}
+
+		value, ok := s.Field(0)
+		if !ok {
+			continue
+		}
+
+		for _, rec := range index[newSortKey(true, &value)] {
+			if err := writeJoined(w, &bigSide, &smallSide, rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+func buildJoinIndex(r *objectRecorder, src io.Reader, key gojsonlex.Path) (map[sortKey][]joinRecord, error) {
+	s, err := gojsonlex.NewRecordScanner(src, gojsonlex.Path{}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.OnToken(r.onToken)
+
+	index := make(map[sortKey][]joinRecord)
+
+	for {
+		rec := joinRecord{membersStart: len(r.memberStarts)}
+
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !r.isObject {
+			return nil, fmt.Errorf("record is not an object")
+		}
+
+		rec.tokensEnd, rec.membersEnd = len(r.Tokens()), len(r.memberStarts)
+
+		value, ok := s.Field(0)
+		if !ok {
+			continue
+		}
+
+		k := newSortKey(true, &value)
+		index[k] = append(index[k], rec)
+	}
+
+	return index, nil
+}
+
+func writeJoined(w *gojsonlex.TokenWriter, big, small *objectRecorder, rec joinRecord) error {
+	if err := w.WriteToken(gojsonlex.NewTokenGenericFromDelim('{')); err != nil {
+		return err
+	}
+
+	if err := w.WriteTokens(big.Tokens()); err != nil {
+		return err
+	}
+
+	for i := rec.membersStart; i < rec.membersEnd; i++ {
+		start, end := small.memberStarts[i], rec.tokensEnd
+		if i+1 < rec.membersEnd {
+			end = small.memberStarts[i+1]
+		}
+
+		if big.hasMember(small.Tokens()[start].String()) {
+			continue
+		}
+
+		if err := w.WriteTokens(small.Tokens()[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return w.WriteToken(gojsonlex.NewTokenGenericFromDelim('}'))
+}
+
+// hasMember reports whether the last recorded object has a member with the given key
+func (r *objectRecorder) hasMember(key string) bool {
+	for _, start := range r.memberStarts {
+		if r.Tokens()[start].String() == key {
+			return true
+		}
+	}
+
+	return false
+}
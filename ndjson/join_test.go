@@ -0,0 +1,113 @@
+package ndjson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type joinTestCase struct {
+	big      string
+	bigKey   string
+	small    string
+	smallKey string
+	output   string
+}
+
+func TestJoin(t *testing.T) {
+	users := `{"id": 1, "name": "alice"}
+{"id": 2, "name": "bob", "tags": ["admin"]}
+{"id": 2, "name": "robert"}
+{"name": "nobody"}
+`
+
+	testcases := []joinTestCase{
+		{
+			`{"user": 2, "ev": "login"} {"user": 3, "ev": "login"} {"user": 1, "ev": "logout", "name": "x"}`,
+			"user", users, "id",
+			`{"user":2,"ev":"login","id":2,"name":"bob","tags":["admin"]}` + "\n" +
+				`{"user":2,"ev":"login","id":2,"name":"robert"}` + "\n" +
+				`{"user":1,"ev":"logout","name":"x","id":1}`,
+		},
+		{
+			`{"u": {"id": "1"}} {"u": {"id": 1}}`,
+			"u.id", users, "id",
+			`{"u":{"id":1},"id":1,"name":"alice"}`,
+		},
+		{`{"user": 1}`, "user", ``, "id", ``},
+		{``, "user", users, "id", ``},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := Join(out, strings.NewReader(testcase.big), gojsonlex.ParsePath(testcase.bigKey),
+			strings.NewReader(testcase.small), gojsonlex.ParsePath(testcase.smallKey))
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.big, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'", testcase.big, out.String(), testcase.output)
+		}
+	}
+}
+
+func TestJoinLargeRecords(t *testing.T) {
+	// every record is larger than the lexer buffer, so keys have to survive refills
+	pad := strings.Repeat("x", 5000)
+	big, small := &bytes.Buffer{}, &bytes.Buffer{}
+
+	for _, id := range []int{30, 10, 20} {
+		fmt.Fprintf(small, "{\"id\": %d, \"name\": \"%s\"}\n", id, pad)
+	}
+
+	for _, user := range []int{10, 5, 30, 20, 100} {
+		fmt.Fprintf(big, "{\"user\": %d, \"ev\": \"%s\"}\n", user, pad)
+	}
+
+	out := &bytes.Buffer{}
+
+	if err := Join(out, big, gojsonlex.ParsePath("user"), small, gojsonlex.ParsePath("id")); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var joined []int
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		var user int
+		if _, err := fmt.Sscanf(line, "{\"user\":%d,", &user); err != nil {
+			t.Fatalf("unexpected record '%.20s...': %v", line, err)
+		}
+
+		if !strings.Contains(line, fmt.Sprintf(",\"id\":%d,", user)) {
+			t.Errorf("record for user %d has been joined with a wrong one", user)
+		}
+
+		joined = append(joined, user)
+	}
+
+	if fmt.Sprint(joined) != "[10 30 20]" {
+		t.Errorf("got users %v, expected [10 30 20]", joined)
+	}
+}
+
+func TestJoinFails(t *testing.T) {
+	testcases := []joinTestCase{
+		{`[1]`, "id", `{"id": 1}`, "id", ""},
+		{`{"id": 1}`, "id", `"a"`, "id", ""},
+		{`{"id": 1`, "id", `{"id": 1}`, "id", ""},
+	}
+
+	for _, testcase := range testcases {
+		err := Join(&bytes.Buffer{}, strings.NewReader(testcase.big), gojsonlex.ParsePath(testcase.bigKey),
+			strings.NewReader(testcase.small), gojsonlex.ParsePath(testcase.smallKey))
+		if err == nil {
+			t.Errorf("testcase '%s' and '%s': must have failed", testcase.big, testcase.small)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+// Package ndjson contains helpers for streams of records, e.g. NDJSON: sorting,
+// deduplication, joining and selection of the top records. Records are scanned one by one
+// with gojsonlex.RecordScanner, only the helpers that need it buffer more.
+package ndjson
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// sortKey is the value a record is sorted by
+type sortKey struct {
+	rank byte   // missing, null, false, true, number or string
+	str  string // string or significant digits of the number
+
+	// numbers are compared exactly: by the sign, the exponent and the significant digits
+	sign int
+	exp  int
+}
+
+const (
+	sortRankMissing byte = iota
+	sortRankNull
+	sortRankFalse
+	sortRankTrue
+	sortRankNumber
+	sortRankString
+)
+
+func newSortKey(found bool, t *gojsonlex.TokenGeneric) sortKey {
+	if !found {
+		return sortKey{rank: sortRankMissing}
+	}
+
+	switch t.Type() {
+	case gojsonlex.LexerTokenTypeNull:
+		return sortKey{rank: sortRankNull}
+	case gojsonlex.LexerTokenTypeBool:
+		if t.Bool() {
+			return sortKey{rank: sortRankTrue}
+		}
+
+		return sortKey{rank: sortRankFalse}
+	case gojsonlex.LexerTokenTypeNumber:
+		return newNumberSortKey(t.NumberRaw())
+	}
+
+	return sortKey{rank: sortRankString, str: t.StringCopy()}
+}
+
+// newNumberSortKey creates a key for the textual number s splitting its canonical form
+func newNumberSortKey(s string) sortKey {
+	canonical := string(jsonutil.AppendCanonicalNumber(nil, s))
+	if canonical == "0" {
+		return sortKey{rank: sortRankNumber}
+	}
+
+	k := sortKey{rank: sortRankNumber, sign: 1}
+
+	if canonical[0] == '-' {
+		k.sign = -1
+		canonical = canonical[1:]
+	}
+
+	i := strings.IndexByte(canonical, 'e')
+	k.str = canonical[:i]
+	k.exp, _ = strconv.Atoi(canonical[i+1:])
+
+	return k
+}
+
+func (k *sortKey) compare(other *sortKey) int {
+	switch {
+	case k.rank != other.rank:
+		return int(k.rank) - int(other.rank)
+	case k.rank == sortRankString:
+		return strings.Compare(k.str, other.str)
+	case k.rank != sortRankNumber:
+		return 0
+	case k.sign != other.sign:
+		return k.sign - other.sign
+	case k.exp != other.exp:
+		// digits are placed right after the decimal point, so the longer integer part wins
+		if k.exp < other.exp {
+			return -k.sign
+		}
+
+		return k.sign
+	}
+
+	return k.sign * strings.Compare(k.str, other.str)
+}
+
+// appendCompact appends the valid JSON value raw to dst removing insignificant whitespaces
+func appendCompact(dst, raw []byte) []byte {
+	inString, escaped := false, false
+
+	for _, c := range raw {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case !inString && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			continue
+		}
+
+		dst = append(dst, c)
+	}
+
+	return dst
+}
+
+type sortRecord struct {
+	key sortKey
+	raw []byte
+}
+
+// sortRun is a sorted sequence of records, either in memory or spilled to a file
+type sortRun interface {
+	next() (sortRecord, error)
+}
+
+type memorySortRun struct {
+	records []sortRecord
+}
+
+func (r *memorySortRun) next() (sortRecord, error) {
+	if len(r.records) == 0 {
+		return sortRecord{}, io.EOF
+	}
+
+	rec := r.records[0]
+	r.records = r.records[1:]
+
+	return rec, nil
+}
+
+type fileSortRun struct {
+	s *gojsonlex.RecordScanner
+}
+
+func (r *fileSortRun) next() (sortRecord, error) {
+	if err := r.s.Next(); err != nil {
+		return sortRecord{}, err
+	}
+
+	value, found := r.s.Field(0)
+
+	return sortRecord{newSortKey(found, &value), r.s.Raw()}, nil
+}
+
+type mergeItem struct {
+	rec sortRecord
+	run int // index of the run the record comes from, earlier runs win ties
+}
+
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if c := h[i].rec.key.compare(&h[j].rec.key); c != 0 {
+		return c < 0
+	}
+
+	return h[i].run < h[j].run
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// sortMaxFanIn is the max number of runs merged at once
+const sortMaxFanIn = 16
+
+// sortFile is a run spilled to a temporary file
+type sortFile struct {
+	f     *os.File
+	level int // number of merge passes the records have been through
+}
+
+type externalSorter struct {
+	key       gojsonlex.Path
+	maxMemory int
+	tempDir   string
+
+	records []sortRecord
+	arena   []byte     // compacted raw bytes of records in memory
+	files   []sortFile // runs from the oldest to the newest
+}
+
+// add adds a record to the current run spilling the run to a file if memory limit has
+// been reached
+func (s *externalSorter) add(key sortKey, raw []byte) error {
+	// records that have already been added keep pointing to the old array in case
+	// arena gets reallocated, records are compacted to fit into a line
+	start := len(s.arena)
+	s.arena = appendCompact(s.arena, raw)
+	s.records = append(s.records, sortRecord{key, s.arena[start:]})
+
+	if len(s.arena)+len(s.records)*sortRecordOverhead < s.maxMemory {
+		return nil
+	}
+
+	return s.spill()
+}
+
+// sortRecordOverhead is an approximate size of sortRecord in memory
+const sortRecordOverhead = 64
+
+func (s *externalSorter) sortRun() {
+	sort.SliceStable(s.records, func(i, j int) bool {
+		return s.records[i].key.compare(&s.records[j].key) < 0
+	})
+}
+
+// writeRecord writes the record followed by a newline
+func writeRecord(w *bufio.Writer, rec *sortRecord) error {
+	if _, err := w.Write(rec.raw); err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+// createFile creates a temporary file for a run and writes records of the given runs
+// to it in sorted order
+func (s *externalSorter) createFile(level int, runs []sortRun) (sortFile, error) {
+	f, err := ioutil.TempFile(s.tempDir, "gojsonlex-sort-")
+	if err != nil {
+		return sortFile{}, err
+	}
+
+	file := sortFile{f, level}
+
+	w := bufio.NewWriter(f)
+
+	if err := mergeRuns(w, runs); err != nil {
+		removeSortFiles(file)
+		return sortFile{}, err
+	}
+
+	if err := w.Flush(); err != nil {
+		removeSortFiles(file)
+		return sortFile{}, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		removeSortFiles(file)
+		return sortFile{}, err
+	}
+
+	return file, nil
+}
+
+func (s *externalSorter) spill() error {
+	s.sortRun()
+
+	file, err := s.createFile(0, []sortRun{&memorySortRun{s.records}})
+	if err != nil {
+		return err
+	}
+
+	s.files = append(s.files, file)
+	s.records = s.records[:0]
+	s.arena = s.arena[:0]
+
+	// the newest runs of the same level are merged into a run of the next level, so that
+	// there are at most sortMaxFanIn-1 files per level and every record is merged
+	// a logarithmic number of times
+	for len(s.files) >= sortMaxFanIn {
+		tail := s.files[len(s.files)-sortMaxFanIn:]
+		if tail[0].level != tail[len(tail)-1].level {
+			break
+		}
+
+		runs, err := s.fileRuns(tail)
+		if err != nil {
+			return err
+		}
+
+		merged, err := s.createFile(tail[0].level+1, runs)
+		if err != nil {
+			return err
+		}
+
+		removeSortFiles(tail...)
+
+		s.files = append(s.files[:len(s.files)-sortMaxFanIn], merged)
+	}
+
+	return nil
+}
+
+// fileRuns creates runs reading records from the given files
+func (s *externalSorter) fileRuns(files []sortFile) ([]sortRun, error) {
+	runs := make([]sortRun, 0, len(files)+1)
+
+	for _, file := range files {
+		scanner, err := gojsonlex.NewRecordScanner(bufio.NewReader(file.f), gojsonlex.Path{}, s.key)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, &fileSortRun{scanner})
+	}
+
+	return runs, nil
+}
+
+func removeSortFiles(files ...sortFile) {
+	for _, file := range files {
+		file.f.Close()
+		os.Remove(file.f.Name())
+	}
+}
+
+func (s *externalSorter) cleanup() {
+	removeSortFiles(s.files...)
+}
+
+// mergeRuns writes records of the runs to w in sorted order, earlier runs win ties
+func mergeRuns(w *bufio.Writer, runs []sortRun) error {
+	h := make(mergeHeap, 0, len(runs))
+
+	for i, run := range runs {
+		rec, err := run.next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		h = append(h, mergeItem{rec, i})
+	}
+
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		if err := writeRecord(w, &h[0].rec); err != nil {
+			return err
+		}
+
+		rec, err := runs[h[0].run].next()
+		if err == io.EOF {
+			heap.Pop(&h)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		h[0].rec = rec
+		heap.Fix(&h, 0)
+	}
+
+	return nil
+}
+
+// merge writes records of all runs to dst in sorted order
+func (s *externalSorter) merge(dst io.Writer) error {
+	s.sortRun()
+
+	runs, err := s.fileRuns(s.files)
+	if err != nil {
+		return err
+	}
+
+	runs = append(runs, &memorySortRun{s.records})
+
+	w := bufio.NewWriter(dst)
+
+	if err := mergeRuns(w, runs); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// Sort reads top-level values (e.g. NDJSON records) from src and writes them to dst
+// one per line sorted by the value at path key inside every record. Records are ordered by
+// the type of the value first: records without the value, null, false, true, numbers and
+// strings, then numbers (exactly, regardless of their size and precision) and strings are
+// compared by value. The sort is stable, records are written in compact form. About
+// maxMemory bytes of records are sorted in memory at a time, sorted runs exceeding it are
+// spilled to temporary files in tempDir (the default directory for temporary files if
+// empty) and merged afterwards, at most 16 at a time.
+func Sort(dst io.Writer, src io.Reader, key gojsonlex.Path, maxMemory int, tempDir string) error {
+	scanner, err := gojsonlex.NewRecordScanner(src, gojsonlex.Path{}, key)
+	if err != nil {
+		return err
+	}
+
+	s := &externalSorter{
+		key:       key,
+		maxMemory: maxMemory,
+		tempDir:   tempDir,
+	}
+
+	defer s.cleanup()
+
+	for {
+		if err := scanner.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		value, found := scanner.Field(0)
+
+		if err := s.add(newSortKey(found, &value), scanner.Raw()); err != nil {
+			return err
+		}
+	}
+
+	return s.merge(dst)
+}
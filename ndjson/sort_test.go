@@ -0,0 +1,174 @@
+package ndjson
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type sortTestCase struct {
+	input  string
+	key    string
+	output string
+}
+
+func TestSort(t *testing.T) {
+	testcases := []sortTestCase{
+		{"", "a", ""},
+		{`{"a": 2} {"a": 1}`, "a", "{\"a\":1}\n{\"a\":2}\n"},
+		{
+			"{\"a\": \"b\", \"n\": 1}\n{\"a\": 10, \"n\": 2}\n{\"n\": 3}\n{\"a\": true, \"n\": 4}\n" +
+				"{\"a\": null, \"n\": 5}\n{\"a\": false, \"n\": 6}\n{\"a\": \"a\", \"n\": 7}\n" +
+				"{\"a\": -1, \"n\": 8}\n{\"a\": 10, \"n\": 9}\n",
+			"a",
+			"{\"n\":3}\n{\"a\":null,\"n\":5}\n{\"a\":false,\"n\":6}\n{\"a\":true,\"n\":4}\n" +
+				"{\"a\":-1,\"n\":8}\n{\"a\":10,\"n\":2}\n{\"a\":10,\"n\":9}\n" +
+				"{\"a\":\"a\",\"n\":7}\n{\"a\":\"b\",\"n\":1}\n",
+		},
+		{`{"a": {"b": "y"}} {"a": {"b": "x"}}`, "a.b", "{\"a\":{\"b\":\"x\"}}\n{\"a\":{\"b\":\"y\"}}\n"},
+		{
+			"9007199254740993 9007199254740992 1e16 -0.5 -5e-1 -1 0.0 -0 1.5 15e-1 1.0000000000000000001 1",
+			"",
+			"-1\n-0.5\n-5e-1\n0.0\n-0\n1\n1.0000000000000000001\n1.5\n15e-1\n" +
+				"9007199254740992\n9007199254740993\n1e16\n",
+		},
+		{"{\n  \"a\": \"x y\\\" \\\\\",\n  \"b\": [1, 2]\n}\n[\n]", "a", "[]\n{\"a\":\"x y\\\" \\\\\",\"b\":[1,2]}\n"},
+	}
+
+	for _, testcase := range testcases {
+		for _, maxMemory := range []int{1, 1 << 20} {
+			out := &bytes.Buffer{}
+
+			err := Sort(out, strings.NewReader(testcase.input), gojsonlex.ParsePath(testcase.key), maxMemory, "")
+			if err != nil {
+				t.Errorf("testcase '%s': %v", testcase.input, err)
+				continue
+			}
+
+			if out.String() != testcase.output {
+				t.Errorf("testcase '%s' (memory %d): got '%s', expected '%s'",
+					testcase.input, maxMemory, out.String(), testcase.output)
+			}
+		}
+	}
+}
+
+func TestSortSpills(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gojsonlex-test-")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rnd := rand.New(rand.NewSource(1))
+	input := &bytes.Buffer{}
+
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(input, "{\"seq\": %d, \"key\": %d}\n", i, rnd.Intn(100))
+	}
+
+	out := &bytes.Buffer{}
+
+	if err := Sort(out, bytes.NewReader(input.Bytes()), gojsonlex.ParsePath("key"), 4096, tempDir); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 5000 {
+		t.Fatalf("got %d records, expected 5000", len(lines))
+	}
+
+	prevKey, prevSeq := -1, -1
+
+	for _, line := range lines {
+		var seq, key int
+		if _, err := fmt.Sscanf(line, "{\"seq\":%d,\"key\":%d}", &seq, &key); err != nil {
+			t.Fatalf("unexpected record '%s': %v", line, err)
+		}
+
+		if key < prevKey || key == prevKey && seq < prevSeq {
+			t.Fatalf("record '%s' is out of order", line)
+		}
+
+		prevKey, prevSeq = key, seq
+	}
+
+	files, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("%d temporary files have not been removed", len(files))
+	}
+}
+
+func TestSortMergesInPasses(t *testing.T) {
+	s := &externalSorter{maxMemory: 1}
+	defer s.cleanup()
+
+	for i := 0; i < 1000; i++ {
+		raw := []byte(strconv.Itoa(i % 7))
+		if err := s.add(newNumberSortKey(string(raw)), raw); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	// 1000 runs make 3 levels: 3*256 + 14*16 + 8
+	if len(s.files) != 3+14+8 {
+		t.Errorf("got %d temporary files, expected %d", len(s.files), 3+14+8)
+	}
+
+	out := &bytes.Buffer{}
+	if err := s.merge(out); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if lines := strings.Count(out.String(), "\n"); lines != 1000 {
+		t.Errorf("got %d records, expected 1000", lines)
+	}
+
+	if !strings.HasPrefix(out.String(), "0\n0\n") || !strings.HasSuffix(out.String(), "6\n6\n") {
+		t.Errorf("records are out of order")
+	}
+}
+
+func TestSortLargeRecords(t *testing.T) {
+	// every record is larger than the lexer buffer, so keys have to survive refills
+	pad := strings.Repeat("x", 5000)
+	input := &bytes.Buffer{}
+
+	for _, k := range []int{30, 10, 20, 5, 100} {
+		fmt.Fprintf(input, "{\"k\": %d, \"pad\": \"%s\"}\n", k, pad)
+	}
+
+	for _, maxMemory := range []int{1, 1 << 20} {
+		out := &bytes.Buffer{}
+
+		if err := Sort(out, bytes.NewReader(input.Bytes()), gojsonlex.ParsePath("k"), maxMemory, ""); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		var keys []int
+
+		for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+			var k int
+			if _, err := fmt.Sscanf(line, "{\"k\":%d,", &k); err != nil {
+				t.Fatalf("unexpected record '%.20s...': %v", line, err)
+			}
+
+			keys = append(keys, k)
+		}
+
+		if fmt.Sprint(keys) != "[5 10 20 30 100]" {
+			t.Errorf("memory %d: got keys %v, expected [5 10 20 30 100]", maxMemory, keys)
+		}
+	}
+}
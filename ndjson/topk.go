@@ -0,0 +1,90 @@
+package ndjson
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type topKRecord struct {
+	value float64
+	seq   int // sequence number of the record, earlier records win ties
+	raw   []byte
+}
+
+// topKHeap is a min-heap of records, the record to be evicted first is at the top
+type topKHeap []topKRecord
+
+func (h topKHeap) Len() int { return len(h) }
+func (h topKHeap) Less(i, j int) bool {
+	if h[i].value != h[j].value {
+		return h[i].value < h[j].value
+	}
+
+	return h[i].seq > h[j].seq
+}
+func (h topKHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *topKHeap) Push(x interface{}) { *h = append(*h, x.(topKRecord)) }
+func (h *topKHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// TopK reads records found at path records from r (e.g. "*" for elements of a top-level
+// array or an empty path for NDJSON) and returns raw bytes of the k records with the
+// largest numbers at path by (relative to the record) in descending order. Records
+// without a number at path by are skipped, the earlier record wins a tie. Only k records
+// are kept in memory at a time.
+func TopK(r io.Reader, records gojsonlex.Path, by gojsonlex.Path, k int) ([][]byte, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("invalid k %d", k)
+	}
+
+	s, err := gojsonlex.NewRecordScanner(r, records, by)
+	if err != nil {
+		return nil, err
+	}
+
+	h := make(topKHeap, 0, k)
+
+	for seq := 0; ; seq++ {
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		field, ok := s.Field(0)
+		if !ok || field.Type() != gojsonlex.LexerTokenTypeNumber || k == 0 {
+			continue
+		}
+
+		value := field.Number()
+
+		if len(h) == k {
+			if value <= h[0].value {
+				continue
+			}
+
+			// the evicted record's buffer is reused
+			evicted := heap.Pop(&h).(topKRecord)
+			heap.Push(&h, topKRecord{value, seq, append(evicted.raw[:0], s.Raw()...)})
+
+			continue
+		}
+
+		heap.Push(&h, topKRecord{value, seq, append([]byte(nil), s.Raw()...)})
+	}
+
+	result := make([][]byte, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&h).(topKRecord).raw
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package ndjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type topKTestCase struct {
+	input   string
+	records string
+	by      string
+	k       int
+	output  []string
+}
+
+func TestTopK(t *testing.T) {
+	ndjson := `{"id": 1, "size": 10}
+{"id": 2, "size": 30}
+{"id": 3}
+{"id": 4, "size": "40"}
+{"id": 5, "size": 20}
+{"id": 6, "size": 30}
+`
+
+	testcases := []topKTestCase{
+		{ndjson, "", "size", 2, []string{`{"id": 2, "size": 30}`, `{"id": 6, "size": 30}`}},
+		{ndjson, "", "size", 1, []string{`{"id": 2, "size": 30}`}},
+		{ndjson, "", "size", 10, []string{
+			`{"id": 2, "size": 30}`, `{"id": 6, "size": 30}`, `{"id": 5, "size": 20}`, `{"id": 1, "size": 10}`,
+		}},
+		{ndjson, "", "size", 0, []string{}},
+		{ndjson, "", "id", 1, []string{`{"id": 6, "size": 30}`}},
+		{`{"events": [{"m": {"v": 1}}, {"m": {"v": 3}}, {"m": [1, 2]}, {"m": {"v": 2}}]}`,
+			"events.*", "m.v", 2, []string{`{"m": {"v": 3}}`, `{"m": {"v": 2}}`}},
+		{`[5, "a", 7, [8], 6]`, "*", "", 2, []string{`7`, `6`}},
+	}
+
+	for _, testcase := range testcases {
+		r := iotest.HalfReader(strings.NewReader(testcase.input))
+
+		output, err := TopK(r, gojsonlex.ParsePath(testcase.records), gojsonlex.ParsePath(testcase.by), testcase.k)
+		if err != nil {
+			t.Errorf("testcase '%s' by '%s': %v", testcase.input, testcase.by, err)
+			continue
+		}
+
+		got := make([]string, 0, len(output))
+		for _, raw := range output {
+			got = append(got, string(raw))
+		}
+
+		if strings.Join(got, "|") != strings.Join(testcase.output, "|") {
+			t.Errorf("testcase '%s' by '%s': got %v, expected %v", testcase.input, testcase.by, got,
+				testcase.output)
+		}
+	}
+}
+
+func TestTopKFails(t *testing.T) {
+	testcases := []string{
+		`{"size": 1}{"size": 2`,
+		`{"size": 1}]`,
+	}
+
+	for _, testcase := range testcases {
+		if _, err := TopK(strings.NewReader(testcase), gojsonlex.Path{}, gojsonlex.ParsePath("size"), 1); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase)
+		}
+	}
+}
+
+func TestTopKLargeInput(t *testing.T) {
+	b := strings.Builder{}
+	// the padding exceeds the default buffer of the lexer several times
+	b.WriteString(`{"padding": "` + strings.Repeat("x", 3*4096) + `", "events": [`)
+	for i := 0; i < 10000; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		fmt.Fprintf(&b, `{"id": %d, "size": %d}`, i, i%97)
+	}
+	b.WriteString(`]}`)
+
+	output, err := TopK(strings.NewReader(b.String()), gojsonlex.ParsePath("events.*"), gojsonlex.ParsePath("size"), 3)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := `{"id": 96, "size": 96}|{"id": 193, "size": 96}|{"id": 290, "size": 96}`
+
+	got := make([]string, 0, len(output))
+	for _, raw := range output {
+		got = append(got, string(raw))
+	}
+
+	if strings.Join(got, "|") != expected {
+		t.Errorf("got %v, expected %s", got, expected)
+	}
+}
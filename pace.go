@@ -0,0 +1,92 @@
+package gojsonlex
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	// pacingSlack is the min delay worth sleeping, smaller delays are accumulated
+	pacingSlack = time.Millisecond
+	// pacingMaxBurst limits the credit accumulated while the producer has been idle
+	pacingMaxBurst = time.Second
+	// pacingChunks is the number of chunks a second worth of bytes is split into by
+	// PacedWriter, so that consumers get the data steadily
+	pacingChunks = 10
+)
+
+// pacer computes delays keeping the rate of some operations under the limit
+type pacer struct {
+	rate  float64 // units per second, 0 if unlimited
+	start time.Time
+	done  float64 // units done since start
+}
+
+// delay returns how long to wait before doing n more units
+func (p *pacer) delay(n int) time.Duration {
+	if p.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+
+	due := p.start.Add(time.Duration(p.done / p.rate * float64(time.Second)))
+	if now.Sub(due) > pacingMaxBurst {
+		p.start, p.done, due = now, 0, now
+	}
+
+	p.done += float64(n)
+
+	return due.Sub(now)
+}
+
+// PacedWriter is an io.Writer that limits the rate of writing to the underlying writer,
+// see NewPacedWriter
+type PacedWriter struct {
+	w     io.Writer
+	pacer pacer
+}
+
+// NewPacedWriter returns a writer passing data to w at the rate of at most bytesPerSec
+// (averaged over short bursts), Write blocks as needed. Wrapping the destination of a
+// transform or a TokenWriter with it allows to replay captured streams into downstream
+// systems without overloading them. A non-positive rate means no limit.
+func NewPacedWriter(w io.Writer, bytesPerSec int) *PacedWriter {
+	return &PacedWriter{w: w, pacer: pacer{rate: float64(bytesPerSec)}}
+}
+
+func (w *PacedWriter) Write(b []byte) (int, error) {
+	if w.pacer.rate <= 0 {
+		return w.w.Write(b)
+	}
+
+	chunkSize := int(w.pacer.rate) / pacingChunks
+	if chunkSize == 0 {
+		chunkSize = 1
+	}
+
+	written := 0
+
+	for written < len(b) {
+		chunk := b[written:]
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if d := w.pacer.delay(len(chunk)); d > 0 {
+			time.Sleep(d)
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
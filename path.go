@@ -0,0 +1,381 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathSegment struct {
+	key      string
+	index    int  // -1 if the segment can not be an array index
+	wildcard bool // matches any key or index
+}
+
+// Path addresses values inside a JSON document. Path is parsed from a dot-separated
+// string like "cells.3.value", numeric segments match array indices as well as object
+// keys, "*" matches any key or index. Dots, asterisks and backslashes inside keys must
+// be escaped with a backslash. An empty path addresses top-level values.
+type Path struct {
+	segments []pathSegment
+	foldKeys bool
+}
+
+// ParsePath parses the given dot-separated path
+func ParsePath(s string) Path {
+	p := Path{}
+	if s == "" {
+		return p
+	}
+
+	key := make([]byte, 0, len(s))
+	escaped := false // reports whether the current segment contains escaped symbols
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			key = append(key, s[i])
+			escaped = true
+		case s[i] == '.':
+			p.segments = append(p.segments, newPathSegment(string(key), escaped))
+			key = key[:0]
+			escaped = false
+		default:
+			key = append(key, s[i])
+		}
+	}
+
+	p.segments = append(p.segments, newPathSegment(string(key), escaped))
+
+	return p
+}
+
+func newPathSegment(key string, escaped bool) pathSegment {
+	seg := pathSegment{key: key, index: -1}
+
+	if key == "*" && !escaped {
+		seg.wildcard = true
+		return seg
+	}
+
+	if index, err := strconv.Atoi(key); err == nil && index >= 0 && key[0] != '+' {
+		seg.index = index
+	}
+
+	return seg
+}
+
+// String returns the textual representation of the path that can be parsed back with
+// ParsePath
+func (p Path) String() string {
+	b := strings.Builder{}
+
+	for i, seg := range p.segments {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+
+		if seg.wildcard {
+			b.WriteByte('*')
+			continue
+		}
+
+		writeEscapedKey(&b, seg.key)
+	}
+
+	return b.String()
+}
+
+// writeEscapedKey writes the key escaping symbols that have special meaning in paths
+func writeEscapedKey(b *strings.Builder, key string) {
+	for i := 0; i < len(key); i++ {
+		if key[i] == '.' || key[i] == '*' || key[i] == '\\' {
+			b.WriteByte('\\')
+		}
+
+		b.WriteByte(key[i])
+	}
+}
+
+// FoldKeys returns a copy of the path that matches object keys case-insensitively
+// (under Unicode case-folding)
+func (p Path) FoldKeys() Path {
+	p.foldKeys = true
+	return p
+}
+
+// join returns the concatenation of the paths
+func (p Path) join(q Path) Path {
+	segments := make([]pathSegment, 0, len(p.segments)+len(q.segments))
+	segments = append(segments, p.segments...)
+	segments = append(segments, q.segments...)
+
+	return Path{segments: segments, foldKeys: p.foldKeys || q.foldKeys}
+}
+
+// prefix returns the path consisting of the first n segments
+func (p Path) prefix(n int) Path {
+	return Path{segments: p.segments[:n], foldKeys: p.foldKeys}
+}
+
+// Len returns the number of segments in the path
+func (p Path) Len() int {
+	return len(p.segments)
+}
+
+func (p *Path) keyMatches(key []byte, seg *pathSegment) bool {
+	if p.foldKeys {
+		return strings.EqualFold(unsafeStringFromBytes(key), seg.key)
+	}
+
+	return string(key) == seg.key
+}
+
+// TokenRole describes the structural role of a token in a JSON document, see PathTracker
+type TokenRole byte
+
+const (
+	TokenRoleSeparator TokenRole = iota // ',' or ':'
+	TokenRoleKey                        // object key
+	TokenRoleScalar                     // scalar value
+	TokenRoleOpen                       // '{' or '[' opening a value
+	TokenRoleClose                      // '}' or ']'
+)
+
+// StartsValue reports whether a token with the role starts a new value
+func (r TokenRole) StartsValue() bool {
+	return r == TokenRoleScalar || r == TokenRoleOpen
+}
+
+type pathFrame struct {
+	isObject  bool
+	expectKey bool   // reports whether the next string in the object is a key
+	key       []byte // deep copy of the current key in the object
+	index     int    // index of the current element in the array
+}
+
+// PathTracker follows a stream of tokens (including delimiters) and maintains the
+// position of the last started value in the document. It is the building block of
+// the transforms and the record helpers living in the subpackages. The zero value is
+// ready to use.
+type PathTracker struct {
+	frames []pathFrame
+	depth  int // number of open containers, len(frames) may be larger for reuse
+
+	valueDepth int // depth at which the last value started
+}
+
+func (p *PathTracker) top() *pathFrame {
+	if p.depth == 0 {
+		return nil
+	}
+
+	return &p.frames[p.depth-1]
+}
+
+func (p *PathTracker) beginValue() {
+	p.valueDepth = p.depth
+
+	if frame := p.top(); frame != nil && !frame.isObject {
+		frame.index++
+	}
+}
+
+func (p *PathTracker) endValue() {
+	if frame := p.top(); frame != nil && frame.isObject {
+		frame.expectKey = true
+	}
+}
+
+func (p *PathTracker) push(isObject bool) {
+	if p.depth == len(p.frames) {
+		p.frames = append(p.frames, pathFrame{})
+	}
+
+	frame := &p.frames[p.depth]
+	frame.isObject = isObject
+	frame.expectKey = isObject
+	frame.key = frame.key[:0]
+	frame.index = -1
+
+	p.depth++
+}
+
+// Feed updates the position according to the next token and returns its role. An error
+// is returned if the token violates the structure of JSON (e.g. a number in place of an
+// object key or a mismatched closing delimiter).
+func (p *PathTracker) Feed(t *TokenGeneric) (TokenRole, error) {
+	if t.t != LexerTokenTypeDelim {
+		if frame := p.top(); frame != nil && frame.isObject && frame.expectKey {
+			if t.t != LexerTokenTypeString {
+				return TokenRoleKey, fmt.Errorf("expected object key, got %s", t.t)
+			}
+
+			frame.key = append(frame.key[:0], t.str...)
+			frame.expectKey = false
+
+			return TokenRoleKey, nil
+		}
+
+		p.beginValue()
+		p.endValue()
+
+		return TokenRoleScalar, nil
+	}
+
+	switch t.delim {
+	case '{', '[':
+		p.beginValue()
+		p.push(t.delim == '{')
+
+		return TokenRoleOpen, nil
+	case '}', ']':
+		frame := p.top()
+		if frame == nil || frame.isObject != (t.delim == '}') {
+			return TokenRoleClose, fmt.Errorf("unexpected delimiter '%c'", t.delim)
+		}
+
+		p.depth--
+		p.endValue()
+
+		return TokenRoleClose, nil
+	}
+
+	return TokenRoleSeparator, nil
+}
+
+// Matches reports whether the last started value is located at the given path
+func (p *PathTracker) Matches(path Path) bool {
+	return p.matchesAt(p.valueDepth, path)
+}
+
+// Path returns the position of the last started value in the form accepted by ParsePath
+func (p *PathTracker) Path() string {
+	return p.pathAt(p.valueDepth)
+}
+
+// Depth returns the number of open objects and arrays
+func (p *PathTracker) Depth() int {
+	return p.depth
+}
+
+// ValueDepth returns the depth at which the last started value is located, i.e. the
+// number of segments in its path
+func (p *PathTracker) ValueDepth() int {
+	return p.valueDepth
+}
+
+// Key returns the key of the member the last started value belongs to, ok is false for
+// array elements and top-level values. The key is valid until the next call to Feed.
+func (p *PathTracker) Key() (key string, ok bool) {
+	if p.valueDepth == 0 {
+		return "", false
+	}
+
+	frame := &p.frames[p.valueDepth-1]
+	if !frame.isObject {
+		return "", false
+	}
+
+	return unsafeStringFromBytes(frame.key), true
+}
+
+// Context returns the context of the last started value, offset is the offset of its
+// first byte in the input. The context is valid until the next call to Feed.
+func (p *PathTracker) Context(offset int64) TokenContext {
+	return TokenContext{tr: p, depth: p.valueDepth, offset: offset}
+}
+
+// keyPath returns the position of the member whose key has just been read in the form
+// accepted by ParsePath
+func (p *PathTracker) keyPath() string {
+	return p.pathAt(p.depth)
+}
+
+// pathAt returns the position described by the first depth frames in the form accepted
+// by ParsePath
+func (p *PathTracker) pathAt(depth int) string {
+	b := strings.Builder{}
+
+	for i := 0; i < depth; i++ {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+
+		if frame := &p.frames[i]; frame.isObject {
+			writeEscapedKey(&b, unsafeStringFromBytes(frame.key))
+		} else {
+			b.WriteString(strconv.Itoa(frame.index))
+		}
+	}
+
+	return b.String()
+}
+
+// segmentsAt returns keys and indices of the position described by the first depth frames
+func (p *PathTracker) segmentsAt(depth int) []string {
+	segments := make([]string, 0, depth)
+
+	for i := 0; i < depth; i++ {
+		if frame := &p.frames[i]; frame.isObject {
+			segments = append(segments, string(frame.key))
+		} else {
+			segments = append(segments, strconv.Itoa(frame.index))
+		}
+	}
+
+	return segments
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// pointerAt returns the position described by the first depth frames as a JSON Pointer
+// (RFC 6901)
+func (p *PathTracker) pointerAt(depth int) string {
+	b := strings.Builder{}
+
+	for i := 0; i < depth; i++ {
+		b.WriteByte('/')
+
+		if frame := &p.frames[i]; frame.isObject {
+			pointerEscaper.WriteString(&b, unsafeStringFromBytes(frame.key))
+		} else {
+			b.WriteString(strconv.Itoa(frame.index))
+		}
+	}
+
+	return b.String()
+}
+
+// containerMatches reports whether the innermost open container is located at the
+// given path
+func (p *PathTracker) containerMatches(path Path) bool {
+	return p.depth > 0 && p.matchesAt(p.depth-1, path)
+}
+
+// matchesAt reports whether the position described by the first depth frames
+// matches the given path
+func (p *PathTracker) matchesAt(depth int, path Path) bool {
+	if depth != len(path.segments) {
+		return false
+	}
+
+	for i, seg := range path.segments {
+		frame := &p.frames[i]
+
+		if seg.wildcard {
+			continue
+		}
+
+		if frame.isObject {
+			if !path.keyMatches(frame.key, &seg) {
+				return false
+			}
+		} else if frame.index != seg.index {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+package gojsonlex
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+type parsePathTestCase struct {
+	input  string
+	output []string
+}
+
+func TestParsePath(t *testing.T) {
+	testcases := []parsePathTestCase{
+		{"", nil},
+		{"cells", []string{"cells"}},
+		{"cells.3.value", []string{"cells", "3", "value"}},
+		{"a..b", []string{"a", "", "b"}},
+		{`a\.b.c`, []string{"a.b", "c"}},
+		{`a\\.b`, []string{`a\`, "b"}},
+		{`a.*.b`, []string{"a", "*", "b"}},
+		{`a.\*`, []string{"a", "*"}},
+	}
+
+	for _, testcase := range testcases {
+		p := ParsePath(testcase.input)
+
+		if p.Len() != len(testcase.output) {
+			t.Errorf("testcase '%s': expected %d segments, got %d",
+				testcase.input, len(testcase.output), p.Len())
+			continue
+		}
+
+		for i, seg := range p.segments {
+			if seg.key != testcase.output[i] {
+				t.Errorf("testcase '%s': expected segment '%s', got '%s'",
+					testcase.input, testcase.output[i], seg.key)
+			}
+		}
+
+		if p.String() != testcase.input {
+			t.Errorf("testcase '%s': got '%s' after round trip", testcase.input, p.String())
+		}
+	}
+}
+
+type pathTrackerTestCase struct {
+	input  string
+	path   string
+	output []string // string values found at path
+}
+
+func TestPathTracker(t *testing.T) {
+	testcases := []pathTrackerTestCase{
+		{`"hello"`, "", []string{"hello"}},
+		{`{"a": "b"}`, "a", []string{"b"}},
+		{`{"a": {"b": "c", "d": "e"}}`, "a.d", []string{"e"}},
+		{`{"a": ["b", "c", "d"]}`, "a.1", []string{"c"}},
+		{`{"a": [{"b": "c"}, {"b": "d"}]}`, "a.1.b", []string{"d"}},
+		{`{"a": [[], {}, "x"], "b": "y"}`, "a.2", []string{"x"}},
+		{`{"a": "b"} {"a": "c"}`, "a", []string{"b", "c"}},
+		{`{"1": "b"}`, "1", []string{"b"}},
+		{`["a", "b"]`, "a", nil},
+		{`{"a": ["b", "c"], "d": {"e": "f"}}`, "*.*", []string{"b", "c", "f"}},
+		{`{"a": {"x": "b"}, "d": {"x": "f", "y": "g"}}`, "*.x", []string{"b", "f"}},
+		{`{"*": "a", "b": "c"}`, `\*`, []string{"a"}},
+		{`{"Origin": "a", "origin": "b", "ORIGIN": "c"}`, "origin", []string{"b"}},
+	}
+
+	testPathTracker(t, testcases, false)
+}
+
+func TestPathTrackerFoldKeys(t *testing.T) {
+	testcases := []pathTrackerTestCase{
+		{`{"Origin": "a", "origin": "b", "ORIGIN": "c"}`, "origin", []string{"a", "b", "c"}},
+		{`{"Bands": [{"NAME": "Muse"}]}`, "bands.0.name", []string{"Muse"}},
+		{`{"Straße": "a", "STRASSE": "b"}`, "straße", []string{"a"}},
+		{`{"ab": "a"}`, "abc", nil},
+	}
+
+	testPathTracker(t, testcases, true)
+}
+
+func testPathTracker(t *testing.T, testcases []pathTrackerTestCase, foldKeys bool) {
+	for _, testcase := range testcases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Errorf("testcase '%s': could not create lexer: %v", testcase.input, err)
+			continue
+		}
+
+		l.skipDelims = false
+		l.SetBufSize(4)
+
+		path := ParsePath(testcase.path)
+		if foldKeys {
+			path = path.FoldKeys()
+		}
+
+		tracker := PathTracker{}
+
+		var found []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("testcase '%s': %v", testcase.input, err)
+				break
+			}
+
+			role, err := tracker.Feed(&token)
+			if err != nil {
+				t.Errorf("testcase '%s': %v", testcase.input, err)
+				break
+			}
+
+			if role == TokenRoleScalar && tracker.Matches(path) {
+				found = append(found, token.StringCopy())
+			}
+		}
+
+		if strings.Join(found, ",") != strings.Join(testcase.output, ",") {
+			t.Errorf("testcase '%s': expected %v at '%s', got %v",
+				testcase.input, testcase.output, testcase.path, found)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/gibsn/gojsonlex/pbstruct
+
+go 1.13
+
+require (
+	github.com/gibsn/gojsonlex v0.0.0
+	google.golang.org/protobuf v1.28.1
+)
+
+replace github.com/gibsn/gojsonlex => ../
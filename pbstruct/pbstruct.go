@@ -0,0 +1,167 @@
+// Package pbstruct builds google.protobuf.Struct values (structpb) directly from streams of
+// JSON tokens produced by gojsonlex, without the interface{} intermediate that
+// structpb.NewValue requires.
+package pbstruct
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gibsn/gojsonlex"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// next returns the next token skipping ',' and ':', io.ErrUnexpectedEOF is returned if
+// the stream has ended
+func next(src gojsonlex.TokenSource) (gojsonlex.TokenGeneric, error) {
+	for {
+		t, err := src.TokenFast()
+		if err == io.EOF {
+			return t, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return t, err
+		}
+
+		if t.Type() == gojsonlex.LexerTokenTypeDelim && (t.Delim() == ',' || t.Delim() == ':') {
+			continue
+		}
+
+		return t, nil
+	}
+}
+
+func readValue(src gojsonlex.TokenSource, t gojsonlex.TokenGeneric) (*structpb.Value, error) {
+	switch t.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		return structpb.NewStringValue(t.StringCopy()), nil
+	case gojsonlex.LexerTokenTypeNumber, gojsonlex.LexerTokenTypeInt:
+		return structpb.NewNumberValue(t.Number()), nil
+	case gojsonlex.LexerTokenTypeBool:
+		return structpb.NewBoolValue(t.Bool()), nil
+	case gojsonlex.LexerTokenTypeNull:
+		return structpb.NewNullValue(), nil
+	}
+
+	switch t.Delim() {
+	case '{':
+		s, err := readStruct(src)
+		if err != nil {
+			return nil, err
+		}
+
+		return structpb.NewStructValue(s), nil
+	case '[':
+		l, err := readList(src)
+		if err != nil {
+			return nil, err
+		}
+
+		return structpb.NewListValue(l), nil
+	}
+
+	return nil, fmt.Errorf("unexpected delimiter '%s'", t.Delim())
+}
+
+// readStruct reads members of an object which '{' has already been read
+func readStruct(src gojsonlex.TokenSource) (*structpb.Struct, error) {
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+
+	for {
+		t, err := next(src)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Type() == gojsonlex.LexerTokenTypeDelim && t.Delim() == '}' {
+			return s, nil
+		}
+
+		if t.Type() != gojsonlex.LexerTokenTypeString {
+			return nil, fmt.Errorf("expected object key, got %s", t.Type())
+		}
+
+		key := t.StringCopy()
+
+		if t, err = next(src); err != nil {
+			return nil, err
+		}
+
+		if s.Fields[key], err = readValue(src, t); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readList reads elements of an array which '[' has already been read
+func readList(src gojsonlex.TokenSource) (*structpb.ListValue, error) {
+	l := &structpb.ListValue{}
+
+	for {
+		t, err := next(src)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Type() == gojsonlex.LexerTokenTypeDelim && t.Delim() == ']' {
+			return l, nil
+		}
+
+		v, err := readValue(src, t)
+		if err != nil {
+			return nil, err
+		}
+
+		l.Values = append(l.Values, v)
+	}
+}
+
+// ReadValue reads the next value from src, which must produce delimiters (e.g. a JSONLexer
+// after SetSkipDelims(false)). io.EOF is returned if src has no more values.
+func ReadValue(src gojsonlex.TokenSource) (*structpb.Value, error) {
+	t, err := src.TokenFast()
+	if err != nil {
+		return nil, err
+	}
+
+	return readValue(src, t)
+}
+
+// ReadStruct reads the next value from src like ReadValue, the value must be an object
+func ReadStruct(src gojsonlex.TokenSource) (*structpb.Struct, error) {
+	t, err := src.TokenFast()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Type() != gojsonlex.LexerTokenTypeDelim || t.Delim() != '{' {
+		return nil, fmt.Errorf("expected object, got %s", t.Type())
+	}
+
+	return readStruct(src)
+}
+
+// Unmarshal reads a single JSON value from r
+func Unmarshal(r io.Reader) (*structpb.Value, error) {
+	l, err := gojsonlex.NewJSONLexer(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l.SetSkipDelims(false)
+
+	v, err := ReadValue(l)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := l.TokenFast(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("more than one top-level value")
+		}
+
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,102 @@
+package pbstruct
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestUnmarshal(t *testing.T) {
+	testcases := []string{
+		`{"name": "Bob", "age": 42.5, "admin": false, "email": null}`,
+		`{"tags": ["a", "b", [1, {"x": []}]], "meta": {}, "nested": {"y": {"z": "é"}}}`,
+		`[1, "two", true, null]`,
+		`"just a string"`,
+		`{"dup": 1, "dup": 2}`,
+	}
+
+	for _, testcase := range testcases {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(testcase), &raw); err != nil {
+			t.Fatalf("testcase '%s': %v", testcase, err)
+		}
+
+		expected, err := structpb.NewValue(raw)
+		if err != nil {
+			t.Fatalf("testcase '%s': %v", testcase, err)
+		}
+
+		v, err := Unmarshal(strings.NewReader(testcase))
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase, err)
+			continue
+		}
+
+		if !proto.Equal(v, expected) {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase, v, expected)
+		}
+	}
+}
+
+func TestUnmarshalFails(t *testing.T) {
+	testcases := []string{
+		``,
+		`{"a": 1`,
+		`[1, 2`,
+		`{1: 2}`,
+		`]`,
+		`1 2`,
+	}
+
+	for _, testcase := range testcases {
+		if _, err := Unmarshal(strings.NewReader(testcase)); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase)
+		}
+	}
+}
+
+func TestReadStruct(t *testing.T) {
+	l, err := gojsonlex.NewJSONLexer(strings.NewReader(`{"a": 1} {"b": "x"} [1]`))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetSkipDelims(false)
+
+	for _, key := range []string{"a", "b"} {
+		s, err := ReadStruct(l)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if _, ok := s.Fields[key]; !ok || len(s.Fields) != 1 {
+			t.Errorf("got %v, expected a struct with the only field '%s'", s, key)
+		}
+	}
+
+	if _, err := ReadStruct(l); err == nil {
+		t.Errorf("reading an array as a struct must have failed")
+	}
+}
+
+func TestReadValueEOF(t *testing.T) {
+	l, err := gojsonlex.NewJSONLexer(strings.NewReader(`{"a": 1} `))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetSkipDelims(false)
+
+	if _, err := ReadValue(l); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if _, err := ReadValue(l); err != io.EOF {
+		t.Errorf("got %v, expected %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,164 @@
+package gojsonlex
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// PipelineStage processes tokens received from in and sends the results to out, it returns
+// once in is closed. Sends MUST also select on ctx.Done(), which is closed once any other
+// part of the pipeline has failed. out is closed by Pipeline after the stage returns.
+type PipelineStage func(ctx context.Context, in <-chan TokenGeneric, out chan<- TokenGeneric) error
+
+// PipelineSink consumes the tokens produced by the last stage of a pipeline, it returns
+// once in is closed
+type PipelineSink func(ctx context.Context, in <-chan TokenGeneric) error
+
+// Pipeline runs a source of tokens, a chain of stages and a sink on separate goroutines
+// connected with bounded queues. The first error returned by any of them cancels the
+// rest and is returned by Run.
+type Pipeline struct {
+	src       TokenSource
+	stages    []PipelineStage
+	queueSize int
+}
+
+// NewPipeline creates a new Pipeline reading tokens from src, strings of the tokens are
+// copied before being passed to the stages
+func NewPipeline(src TokenSource, stages ...PipelineStage) *Pipeline {
+	return &Pipeline{
+		src:       src,
+		stages:    stages,
+		queueSize: streamBufSize,
+	}
+}
+
+// SetQueueSize sets the max number of tokens queued between two parts of the pipeline.
+// MUST be called before Run.
+func (p *Pipeline) SetQueueSize(size int) {
+	p.queueSize = size
+}
+
+// Run runs the pipeline until src is exhausted, ctx is done or an error occurs. src MUST
+// NOT be used until Run returns.
+func (p *Pipeline) Run(ctx context.Context, sink PipelineSink) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g := group{cancel: cancel}
+
+	tokens := make(chan TokenGeneric, p.queueSize)
+	g.run(func() error {
+		defer close(tokens)
+		return produceTokens(ctx, p.src, tokens)
+	})
+
+	var in <-chan TokenGeneric = tokens
+
+	for _, stage := range p.stages {
+		stage, stageIn, out := stage, in, make(chan TokenGeneric, p.queueSize)
+		g.run(func() error {
+			defer close(out)
+			return stage(ctx, stageIn, out)
+		})
+
+		in = out
+	}
+
+	g.run(func() error {
+		return sink(ctx, in)
+	})
+
+	return g.wait()
+}
+
+// produceTokens sends all tokens of src with owned strings to out
+func produceTokens(ctx context.Context, src TokenSource, out chan<- TokenGeneric) error {
+	for {
+		t, err := src.TokenFast()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		t.own()
+
+		if err := sendToken(ctx, out, t); err != nil {
+			return err
+		}
+	}
+}
+
+// sendToken sends t to out unless ctx is done
+func sendToken(ctx context.Context, out chan<- TokenGeneric, t TokenGeneric) error {
+	select {
+	case out <- t:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MapStage returns a PipelineStage replacing every token with the result of fn, tokens
+// for which fn returns false are dropped
+func MapStage(fn func(TokenGeneric) (TokenGeneric, bool)) PipelineStage {
+	return func(ctx context.Context, in <-chan TokenGeneric, out chan<- TokenGeneric) error {
+		for t := range in {
+			result, ok := fn(t)
+			if !ok {
+				continue
+			}
+
+			if err := sendToken(ctx, out, result); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// TokenWriterSink returns a PipelineSink writing all tokens with tw, tw is flushed once
+// all tokens have been written
+func TokenWriterSink(tw *TokenWriter) PipelineSink {
+	return func(ctx context.Context, in <-chan TokenGeneric) error {
+		for t := range in {
+			if err := tw.WriteToken(t); err != nil {
+				return err
+			}
+		}
+
+		return tw.Flush()
+	}
+}
+
+// group runs functions on goroutines and keeps the first error, which cancels the rest
+type group struct {
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	cancel func()
+}
+
+func (g *group) run(f func() error) {
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+func (g *group) wait() error {
+	g.wg.Wait()
+	return g.err
+}
@@ -0,0 +1,103 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	input := `{"a": "x", "b": [1, "y"]} ["z", 2]`
+	expected := `{"A":"X","B":["Y"]}` + "\n" + `["Z"]`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetSkipDelims(false)
+
+	upper := MapStage(func(t TokenGeneric) (TokenGeneric, bool) {
+		if t.Type() == LexerTokenTypeString {
+			return NewTokenGenericFromString(strings.ToUpper(t.String())), true
+		}
+
+		return t, true
+	})
+	dropNumbers := MapStage(func(t TokenGeneric) (TokenGeneric, bool) {
+		return t, t.Type() != LexerTokenTypeNumber
+	})
+
+	var output bytes.Buffer
+	tw := NewTokenWriter(&output)
+
+	p := NewPipeline(l, upper, dropNumbers)
+	p.SetQueueSize(1)
+
+	err = p.Run(context.Background(), TokenWriterSink(tw))
+	if err != nil || output.String() != expected {
+		t.Errorf("got %q (error %v), expected %q", output.String(), err, expected)
+	}
+}
+
+func TestPipelineFails(t *testing.T) {
+	errStage := errors.New("stage failed")
+	errSink := errors.New("sink failed")
+
+	failingStage := func(ctx context.Context, in <-chan TokenGeneric, out chan<- TokenGeneric) error {
+		<-in
+		return errStage
+	}
+	failingSink := func(ctx context.Context, in <-chan TokenGeneric) error {
+		<-in
+		return errSink
+	}
+	drainingSink := func(ctx context.Context, in <-chan TokenGeneric) error {
+		for range in {
+		}
+
+		return nil
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	type testCase struct {
+		name   string
+		input  string
+		ctx    context.Context
+		stages []PipelineStage
+		sink   PipelineSink
+		err    error
+	}
+
+	huge := strings.Repeat(`{"a": "b"} `, 10*streamBufSize)
+
+	testCases := []testCase{
+		{"stage", huge, context.Background(), []PipelineStage{failingStage}, drainingSink, errStage},
+		{"sink", huge, context.Background(), []PipelineStage{MapStage(func(t TokenGeneric) (TokenGeneric, bool) {
+			return t, true
+		})}, failingSink, errSink},
+		{"source", `{"a": 1x}`, context.Background(), nil, drainingSink, nil},
+		{"context", huge, cancelled, nil, drainingSink, context.Canceled},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(strings.NewReader(testcase.input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		err = NewPipeline(l, testcase.stages...).Run(testcase.ctx, testcase.sink)
+		if err == nil {
+			t.Errorf("testcase '%s': pipeline must have failed", testcase.name)
+			continue
+		}
+
+		if testcase.err != nil && err != testcase.err {
+			t.Errorf("testcase '%s': got error %v, expected %v", testcase.name, err, testcase.err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gojsonlex
+
+import (
+	"io"
+	"sync"
+)
+
+var lexerPool sync.Pool
+
+// Acquire returns a lexer with default settings reading from r, it is taken from
+// a package-level pool when possible, so that e.g. HTTP handlers parsing request bodies
+// do not allocate a lexer per request. Release SHOULD be called once the lexer is no
+// longer needed.
+func Acquire(r io.Reader) *JSONLexer {
+	if l, ok := lexerPool.Get().(*JSONLexer); ok {
+		l.r = r
+		return l
+	}
+
+	l, _ := NewJSONLexer(r)
+
+	return l
+}
+
+// Release returns l to the pool used by Acquire, l and strings returned by it MUST NOT be
+// used afterwards. Settings of l are reverted to defaults. Closed lexers and lexers with
+// a buffer size set with SetBufSize are not pooled.
+func Release(l *JSONLexer) {
+	if l.bufSize != defaultBufSize || l.buf == nil {
+		return
+	}
+
+	// tokens returned before releasing are reported as stale by Validate
+	l.startEpoch()
+
+	l.releaseMemory()
+	released := *l
+
+	// scratch space is kept, so that the next user does not allocate it again
+	*l = newJSONLexer(nil, released.buf[:cap(released.buf)])
+	l.epoch = released.epoch
+	l.isObject = released.isObject[:0]
+	l.digitsBuf = released.digitsBuf[:0]
+	l.unescapeBuf = released.unescapeBuf[:0]
+
+	lexerPool.Put(l)
+}
@@ -0,0 +1,76 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	input := []byte(`{"a": [1, "b"]}`)
+
+	lexAll := func(l *JSONLexer) []string {
+		var output []string
+
+		for {
+			token, err := l.TokenFast()
+			if err == io.EOF {
+				return output
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			output = append(output, printToken(token))
+		}
+	}
+
+	l := Acquire(bytes.NewReader(input))
+	l.SetSkipDelims(false)
+
+	expected := []string{"{", "a", ":", "[", "1", ",", "b", "]", "}"}
+	if output := lexAll(l); !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	Release(l)
+
+	// settings of the released lexer must not leak
+	l = Acquire(bytes.NewReader(input))
+
+	expected = []string{"a", "1", "b"}
+	if output := lexAll(l); !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+
+	Release(l)
+}
+
+func TestAcquireReleaseAllocs(t *testing.T) {
+	if !zeroCopyStrings {
+		t.Skip("strings are copied in purego builds")
+	}
+
+	input := []byte(`{"type": "update", "values": [1.5, "xy", true, null]}`)
+	r := bytes.NewReader(input)
+
+	Release(Acquire(nil))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(input)
+		l := Acquire(r)
+
+		for {
+			if _, err := l.TokenFast(); err != nil {
+				break
+			}
+		}
+
+		Release(l)
+	})
+
+	if allocs != 0 {
+		t.Errorf("got %v allocations per input, expected 0", allocs)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build purego
+// +build purego
+
+package gojsonlex
+
+// zeroCopyStrings reports whether strings of tokens point into the buffer of the lexer.
+// Building with the purego tag (e.g. for TinyGo or WASM hosts that forbid unsafe) makes
+// the lexer copy every string instead, so strings stay valid forever and SetPoisonStrings
+// has no visible effect, at the cost of an allocation per string.
+const zeroCopyStrings = false
+
+func unsafeStringFromBytes(arr []byte) string {
+	return string(arr)
+}
+
+// unsafeBytesFromString returns the bytes of the given string, it MUST NOT be modified
+func unsafeBytesFromString(s string) []byte {
+	return []byte(s)
+}
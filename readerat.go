@@ -0,0 +1,44 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+)
+
+// ValueIndexEntry describes the location of a single JSON value inside a larger input
+type ValueIndexEntry struct {
+	Offset int64 // offset of the first byte of the value
+	Length int64 // length of the value in bytes
+}
+
+// ValueIndex is a prebuilt offset index of some input, n-th entry describes the location
+// of n-th value (e.g. n-th element of a huge top-level array).
+type ValueIndex []ValueIndexEntry
+
+// GetValueAt creates a JSONLexer that lexes only the n-th value described by index. All
+// reads are done via r.ReadAt strictly within the byte range of the value, so r can be
+// backed by a remote storage supporting range reads (e.g. S3).
+func GetValueAt(r io.ReaderAt, index ValueIndex, n int) (*JSONLexer, error) {
+	if n < 0 || n >= len(index) {
+		return nil, fmt.Errorf("value %d is out of index range [0, %d)", n, len(index))
+	}
+
+	entry := index[n]
+	if entry.Offset < 0 || entry.Length < 0 {
+		return nil, fmt.Errorf("invalid index entry %d: offset %d, length %d",
+			n, entry.Offset, entry.Length)
+	}
+
+	l, err := NewJSONLexer(io.NewSectionReader(r, entry.Offset, entry.Length))
+	if err != nil {
+		return nil, err
+	}
+
+	// small values are fetched with a single read (+1 lets the reader report EOF
+	// right away)
+	if entry.Length < defaultBufSize {
+		l.SetBufSize(int(entry.Length) + 1)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,77 @@
+package gojsonlex
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+type getValueAtTestCase struct {
+	n      int
+	output []string
+}
+
+func TestGetValueAt(t *testing.T) {
+	input := `[{"name": "Metallica"}, {"name": "Enter Shikari", "origin": "England"}, "Muse"]`
+	index := ValueIndex{
+		{Offset: 1, Length: 21},
+		{Offset: 24, Length: 46},
+		{Offset: 72, Length: 6},
+	}
+
+	testcases := []getValueAtTestCase{
+		{0, []string{"name", "Metallica"}},
+		{1, []string{"name", "Enter Shikari", "origin", "England"}},
+		{2, []string{"Muse"}},
+	}
+
+	for _, testcase := range testcases {
+		l, err := GetValueAt(strings.NewReader(input), index, testcase.n)
+		if err != nil {
+			t.Errorf("testcase %d: could not create lexer: %v", testcase.n, err)
+			continue
+		}
+
+		tokensFound := 0
+
+		for {
+			token, err := l.Token()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+
+				t.Errorf("testcase %d: %v", testcase.n, err)
+				break
+			}
+
+			if tokensFound >= len(testcase.output) {
+				tokensFound++
+				continue
+			}
+
+			if token != testcase.output[tokensFound] {
+				t.Errorf("testcase %d: expected token '%s', got '%v'",
+					testcase.n, testcase.output[tokensFound], token)
+			}
+
+			tokensFound++
+		}
+
+		if tokensFound != len(testcase.output) {
+			t.Errorf("testcase %d: expected %d tokens, got %d",
+				testcase.n, len(testcase.output), tokensFound)
+		}
+	}
+}
+
+func TestGetValueAtFails(t *testing.T) {
+	r := strings.NewReader(`["Muse"]`)
+	index := ValueIndex{{Offset: 1, Length: 6}, {Offset: -1, Length: 6}}
+
+	for _, n := range []int{-1, 1, 2} {
+		if _, err := GetValueAt(r, index, n); err == nil {
+			t.Errorf("testcase %d: must have failed", n)
+		}
+	}
+}
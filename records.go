@@ -0,0 +1,211 @@
+package gojsonlex
+
+import (
+	"io"
+)
+
+// capturingReader keeps all data read from r starting from some offset, so that raw bytes
+// of values can be retrieved by their offsets in the stream
+type capturingReader struct {
+	r    io.Reader
+	data []byte
+	base int64 // offset of data[0] in the stream
+}
+
+func (c *capturingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.data = append(c.data, p[:n]...)
+
+	return n, err
+}
+
+// bytes returns the captured data between the given stream offsets
+func (c *capturingReader) bytes(start, end int64) []byte {
+	return c.data[start-c.base : end-c.base]
+}
+
+// discard drops the captured data before the given stream offset
+func (c *capturingReader) discard(offset int64) {
+	if offset <= c.base {
+		return
+	}
+
+	c.data = append(c.data[:0], c.data[offset-c.base:]...)
+	c.base = offset
+}
+
+// RecordScanner splits the input into records: values found at the given path (e.g. "*"
+// for elements of a top-level array or an empty path for NDJSON). For every record it
+// captures its raw bytes and the scalar values at the given field paths relative to the
+// record. Only the current record is kept in memory.
+type RecordScanner struct {
+	l       *JSONLexer
+	tr      PathTracker
+	cr      *capturingReader
+	records Path
+	fields  []Path // absolute paths of the fields
+
+	raw    []byte         // raw bytes of the current record
+	values []TokenGeneric // values of the fields in the current record
+	found  []bool         // reports whether a field has been found in the current record
+	arena  []byte         // copies of string values
+
+	onToken func(token *TokenGeneric, role TokenRole) // called for every token of records
+}
+
+// NewRecordScanner creates a RecordScanner reading records found at path records from r,
+// fields are relative to the records
+func NewRecordScanner(r io.Reader, records Path, fields ...Path) (*RecordScanner, error) {
+	cr := &capturingReader{r: r}
+
+	l, err := NewJSONLexer(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	l.SetSkipDelims(false)
+
+	s := &RecordScanner{
+		l:       l,
+		cr:      cr,
+		records: records,
+		values:  make([]TokenGeneric, len(fields)),
+		found:   make([]bool, len(fields)),
+	}
+
+	for _, field := range fields {
+		s.fields = append(s.fields, records.join(field))
+	}
+
+	return s, nil
+}
+
+// inputOffset returns the offset of the next byte to be processed by the lexer
+func (s *RecordScanner) inputOffset() int64 {
+	return s.l.InputOffset()
+}
+
+// SetPrecisionLossPolicy sets the policy for numbers of the records that can not be
+// represented by float64 exactly, see JSONLexer.SetPrecisionLossPolicy. MUST be called
+// before scanning started.
+func (s *RecordScanner) SetPrecisionLossPolicy(p PrecisionLossPolicy) {
+	s.l.SetPrecisionLossPolicy(p)
+}
+
+// OnToken sets the function called for every token of every record (including
+// delimiters) along with its role, tr describes the position of the token. Strings of
+// the token are valid only until fn returns. MUST be called before scanning started.
+func (s *RecordScanner) OnToken(fn func(tr *PathTracker, t *TokenGeneric, role TokenRole)) {
+	s.onToken = nil
+	if fn != nil {
+		s.onToken = func(t *TokenGeneric, role TokenRole) {
+			fn(&s.tr, t, role)
+		}
+	}
+}
+
+// Raw returns raw bytes of the current record as they appear in the input, they are
+// valid until the next call to Next
+func (s *RecordScanner) Raw() []byte {
+	return s.raw
+}
+
+// Field returns the scalar value of the i-th field of the current record, ok is false if
+// the record has no scalar at the path of the field. Strings of the value are valid until
+// the next call to Next.
+func (s *RecordScanner) Field(i int) (value TokenGeneric, ok bool) {
+	return s.values[i], s.found[i]
+}
+
+// Next scans the next record, io.EOF is returned once the input has been exhausted
+func (s *RecordScanner) Next() error {
+	s.cr.discard(s.inputOffset())
+
+	for {
+		token, err := s.l.TokenFast()
+		if err != nil {
+			return err
+		}
+
+		role, err := s.tr.Feed(&token)
+		if err != nil {
+			return err
+		}
+
+		if role.StartsValue() && s.tr.Matches(s.records) {
+			return s.scanRecord(token, role)
+		}
+
+		// data outside records is not needed
+		if len(s.cr.data) > defaultBufSize {
+			s.cr.discard(s.inputOffset())
+		}
+	}
+}
+
+// scanRecord scans the record started by the given token
+func (s *RecordScanner) scanRecord(token TokenGeneric, role TokenRole) error {
+	start, end := s.l.currTokenOffsets()
+
+	s.arena = s.arena[:0]
+	for i := range s.found {
+		s.found[i] = false
+	}
+
+	if s.onToken != nil {
+		s.onToken(&token, role)
+	}
+
+	if role == TokenRoleScalar {
+		s.matchFields(token)
+		s.raw = s.cr.bytes(start, end)
+
+		return nil
+	}
+
+	for recordDepth := s.tr.depth - 1; s.tr.depth != recordDepth; {
+		token, err := s.l.TokenFast()
+		if err != nil {
+			return err
+		}
+
+		role, err := s.tr.Feed(&token)
+		if err != nil {
+			return err
+		}
+
+		if s.onToken != nil {
+			s.onToken(&token, role)
+		}
+
+		if role == TokenRoleScalar {
+			s.matchFields(token)
+		}
+	}
+
+	_, end = s.l.currTokenOffsets()
+	s.raw = s.cr.bytes(start, end)
+
+	return nil
+}
+
+func (s *RecordScanner) matchFields(token TokenGeneric) {
+	for i, field := range s.fields {
+		if s.found[i] || !s.tr.Matches(field) {
+			continue
+		}
+
+		if token.str != "" {
+			// str also holds the text of numbers, which lives in the lexer buffer just
+			// like strings do. Values that have already been copied keep pointing to
+			// the old array in case arena gets reallocated
+			start := len(s.arena)
+			s.arena = append(s.arena, token.str...)
+			token.str = unsafeStringFromBytes(s.arena[start:])
+			token.epoch = 0
+		}
+
+		s.values[i] = token
+		s.found[i] = true
+	}
+}
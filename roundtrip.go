@@ -0,0 +1,184 @@
+package gojsonlex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// tokenQueue passes tokens between goroutines, it is unbounded
+type tokenQueue struct {
+	mu     sync.Mutex
+	tokens []TokenGeneric
+	head   int
+}
+
+func (q *tokenQueue) push(t TokenGeneric) {
+	q.mu.Lock()
+	q.tokens = append(q.tokens, t)
+	q.mu.Unlock()
+}
+
+func (q *tokenQueue) pop() (TokenGeneric, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.head == len(q.tokens) {
+		return TokenGeneric{}, false
+	}
+
+	t := q.tokens[q.head]
+	q.head++
+
+	if q.head == len(q.tokens) {
+		q.tokens, q.head = q.tokens[:0], 0
+	}
+
+	return t, true
+}
+
+func isSeparator(t *TokenGeneric) bool {
+	return t.t == LexerTokenTypeDelim && (t.delim == ',' || t.delim == ':')
+}
+
+// tokensEqual reports whether the tokens have the same type and value
+func tokensEqual(a, b *TokenGeneric) bool {
+	if a.t != b.t {
+		return false
+	}
+
+	switch a.t {
+	case LexerTokenTypeDelim:
+		return a.delim == b.delim
+	case LexerTokenTypeString:
+		return a.str == b.str
+	case LexerTokenTypeNumber:
+		return a.Number() == b.Number()
+	case LexerTokenTypeInt:
+		return a.integer == b.integer && a.negative == b.negative
+	case LexerTokenTypeBool:
+		return a.boolean == b.boolean
+	}
+
+	return true
+}
+
+// writeTokensQueued writes all tokens of src to w pushing them to q before they are written
+func writeTokensQueued(w *TokenWriter, src *JSONLexer, q *tokenQueue) error {
+	for {
+		token, err := src.TokenFast()
+		if err == io.EOF {
+			return w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		if isSeparator(&token) {
+			continue
+		}
+
+		token.str = token.StringCopy()
+		q.push(token)
+
+		if err := w.WriteToken(token); err != nil {
+			return err
+		}
+	}
+}
+
+// compareTokens lexes r checking that its tokens are the ones popped from q
+func compareTokens(r io.Reader, q *tokenQueue) error {
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		return err
+	}
+
+	l.SetSkipDelims(false)
+
+	for i := 0; ; {
+		token, err := l.TokenFast()
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("could not lex output: %w", err)
+		}
+
+		if err == nil && isSeparator(&token) {
+			continue
+		}
+
+		expected, ok := q.pop()
+
+		switch {
+		case err == io.EOF && ok:
+			return fmt.Errorf("output ends before token %d (%s)", i, expected.t)
+		case err == io.EOF:
+			return nil
+		case !ok:
+			return fmt.Errorf("unexpected token %d (%s) in output", i, token.t)
+		case !tokensEqual(&token, &expected):
+			return fmt.Errorf("token %d differs: got %s '%s', expected %s '%s'", i, token.t,
+				printableToken(&token), expected.t, printableToken(&expected))
+		}
+
+		i++
+	}
+}
+
+func printableToken(t *TokenGeneric) string {
+	switch t.t {
+	case LexerTokenTypeDelim:
+		return string(rune(t.delim))
+	case LexerTokenTypeString:
+		return StringDeepCopy(t.str)
+	case LexerTokenTypeNumber:
+		return fmt.Sprint(t.Number())
+	case LexerTokenTypeInt:
+		if t.negative {
+			return fmt.Sprintf("-%d", t.integer)
+		}
+
+		return fmt.Sprint(t.integer)
+	case LexerTokenTypeBool:
+		return fmt.Sprint(t.boolean)
+	}
+
+	return "null"
+}
+
+// RoundTrip lexes JSON from r, writes its tokens with TokenWriter and lexes the output
+// checking that it produces exactly the same tokens (separators aside). It returns nil
+// for inputs that survive the round trip and an error describing the input error or the
+// first difference otherwise. The output is checked while it is being written, so memory
+// usage does not depend on the size of the input. Transforms and transcoders may use it
+// to validate their JSON output.
+func RoundTrip(r io.Reader) error {
+	src, err := NewJSONLexer(r)
+	if err != nil {
+		return err
+	}
+
+	src.SetSkipDelims(false)
+
+	pr, pw := io.Pipe()
+	q := &tokenQueue{}
+
+	compared := make(chan error, 1)
+
+	go func() {
+		err := compareTokens(pr, q)
+		pr.CloseWithError(err) // unblocks the writer in case of an early error
+		compared <- err
+	}()
+
+	writeErr := writeTokensQueued(NewTokenWriter(pw), src, q)
+	pw.CloseWithError(writeErr)
+
+	compareErr := <-compared
+
+	if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		return writeErr
+	}
+
+	return compareErr
+}
@@ -0,0 +1,162 @@
+package gojsonlex
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// SSEReader reads a stream of Server-Sent Events (text/event-stream) and lexes the data of
+// every event as JSON. Data of an event (all "data:" lines joined with newlines) is
+// buffered, lexing is done with the same JSONLexer for all events. A malformed payload
+// does not affect the following events.
+type SSEReader struct {
+	r *bufio.Reader
+	l *JSONLexer
+
+	line  []byte
+	data  []byte // data of the current event
+	dataR bytes.Reader
+
+	event string
+	id    string
+}
+
+// NewSSEReader creates a new SSEReader reading from r
+func NewSSEReader(r io.Reader) (*SSEReader, error) {
+	l, err := NewJSONLexer(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSEReader{
+		r: bufio.NewReaderSize(r, defaultBufSize),
+		l: l,
+	}, nil
+}
+
+// Lexer returns the lexer used for all events, it can be tuned with setters before the
+// first Next call, the settings are preserved between events
+func (s *SSEReader) Lexer() *JSONLexer {
+	return s.l
+}
+
+// readLine reads the next line without the line ending, the line is valid until the next
+// call
+func (s *SSEReader) readLine() ([]byte, error) {
+	s.line = s.line[:0]
+
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		s.line = append(s.line, chunk...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			// an incomplete line can not finish an event
+			return nil, err
+		}
+
+		break
+	}
+
+	line := s.line[:len(s.line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	return line, nil
+}
+
+// Next skips the rest of the current event and advances to the next event carrying data,
+// events without data are ignored. io.EOF is returned once the input has been exhausted,
+// an event not terminated by an empty line is discarded.
+func (s *SSEReader) Next() error {
+	if s.r == nil {
+		return ErrClosed
+	}
+
+	s.data = s.data[:0]
+	s.event = ""
+	hasData := false
+
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+
+		if len(line) == 0 {
+			if hasData {
+				break
+			}
+
+			s.event = ""
+			continue
+		}
+
+		if line[0] == ':' {
+			continue // comment
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+		}
+
+		switch string(field) {
+		case "data":
+			if hasData {
+				s.data = append(s.data, '\n')
+			}
+
+			s.data = append(s.data, value...)
+			hasData = true
+		case "event":
+			s.event = string(value)
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				s.id = string(value)
+			}
+		}
+	}
+
+	s.dataR.Reset(s.data)
+	s.l.reset(&s.dataR)
+
+	return nil
+}
+
+// Close releases the memory held by the reader and its lexer, all subsequent calls return
+// ErrClosed. Close does not close the underlying reader.
+func (s *SSEReader) Close() error {
+	s.r = nil
+	s.line, s.data = nil, nil
+	s.dataR.Reset(nil)
+
+	return s.l.Close()
+}
+
+// Event returns the type of the current event, "message" if it has not been set
+func (s *SSEReader) Event() string {
+	if s.event == "" {
+		return "message"
+	}
+
+	return s.event
+}
+
+// LastEventID returns the last event ID seen in the stream so far
+func (s *SSEReader) LastEventID() string {
+	return s.id
+}
+
+// TokenFast returns the next token of the data of the current event, io.EOF is returned
+// at the end of the data. Errors are sticky until the next Next call.
+func (s *SSEReader) TokenFast() (TokenGeneric, error) {
+	return s.l.TokenFast()
+}
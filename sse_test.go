@@ -0,0 +1,85 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSSEReader(t *testing.T) {
+	input := ": keep-alive\n\n" +
+		"event: update\nid: 1\ndata: {\"a\": \ndata: [1, 2]}\n\n" +
+		"data:\"x\"\r\n\r\n" +
+		"event: ping\n\n" +
+		"id: 2\nretry: 100\ndata: [tru]\n\n" +
+		"data: null\n\n" +
+		"data: 42\n"
+	expected := []string{
+		"update 1: { a : [ 1 , 2 ] }",
+		"message 1: x",
+		"message 2: [ error",
+		"message 2: <nil>",
+	}
+
+	r, err := NewSSEReader(iotest.OneByteReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("could not create reader: %v", err)
+	}
+
+	r.Lexer().SetSkipDelims(false)
+
+	var output []string
+
+	for {
+		if err := r.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		var tokens []string
+
+		for {
+			token, err := r.TokenFast()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				tokens = append(tokens, "error")
+				break
+			}
+
+			tokens = append(tokens, printToken(token))
+		}
+
+		output = append(output,
+			fmt.Sprintf("%s %s: %s", r.Event(), r.LastEventID(), strings.Join(tokens, " ")))
+	}
+
+	if strings.Join(output, "|") != strings.Join(expected, "|") {
+		t.Errorf("got %q, expected %q", output, expected)
+	}
+}
+
+func TestSSEReaderClose(t *testing.T) {
+	s, err := NewSSEReader(strings.NewReader("data: {\"a\": 1}\n\ndata: [2]\n\n"))
+	if err != nil {
+		t.Fatalf("could not create reader: %v", err)
+	}
+
+	if err := s.Next(); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	s.Close()
+
+	if _, err := s.TokenFast(); err != ErrClosed {
+		t.Errorf("TokenFast: got %v, expected ErrClosed", err)
+	}
+
+	if err := s.Next(); err != ErrClosed {
+		t.Errorf("Next: got %v, expected ErrClosed", err)
+	}
+}
@@ -0,0 +1,111 @@
+package gojsonlex
+
+import (
+	"context"
+	"io"
+)
+
+// streamBufSize is the number of tokens Stream may lex ahead of the consumer by default
+const streamBufSize = 256
+
+// StreamStringPolicy defines how Stream makes strings of delivered tokens outlive the
+// lexing of subsequent tokens
+type StreamStringPolicy byte
+
+const (
+	// StreamStringsCopy copies every string to a new allocation (default)
+	StreamStringsCopy StreamStringPolicy = iota
+	// StreamStringsArena copies strings into shared chunks of the buffer size, so that
+	// most tokens cost no allocation. A chunk is kept in memory as long as any string
+	// pointing into it is referenced.
+	StreamStringsArena
+	// StreamStringsUnsafe does not copy strings at all: they keep pointing into the
+	// lexer buffer, which is not reused while streaming (every refill allocates a new
+	// one). All delivered tokens share the epoch of the lexer, so strings stay valid
+	// until the lexer is used again after the stream ends (e.g. Reset or Release), and
+	// Validate reports them as stale afterwards. A single string pins the whole buffer
+	// it has been read from.
+	StreamStringsUnsafe
+)
+
+// SetStreamPrefetch sets the number of tokens Stream may lex ahead of the consumer, i.e.
+// the capacity of the token channel. Deeper prefetch means fewer stalls of the consumer
+// at the cost of memory, with 0 the lexing goroutine waits for every token to be received.
+// The default is 256. MUST be called before Stream.
+func (l *JSONLexer) SetStreamPrefetch(n int) {
+	l.streamPrefetch = n
+}
+
+// SetStreamStrings sets the policy for strings of tokens delivered by Stream, see
+// StreamStringPolicy. MUST be called before Stream.
+func (l *JSONLexer) SetStreamStrings(p StreamStringPolicy) {
+	l.streamStrings = p
+}
+
+// Stream lexes the rest of the input on a separate goroutine and delivers tokens over
+// a buffered channel, so that reading (e.g. from the network) overlaps with processing
+// of tokens. Strings of delivered tokens are owned by the caller unless
+// StreamStringsUnsafe has been set, see SetStreamPrefetch and SetStreamStrings for
+// tuning. The token channel is closed once the input ends, an error occurs or ctx is
+// done; in the last two cases the error (ctx.Err() for ctx) is sent to the error channel
+// before the token channel is closed. The lexer MUST NOT be used until the token channel
+// is closed.
+func (l *JSONLexer) Stream(ctx context.Context) (<-chan TokenGeneric, <-chan error) {
+	tokens := make(chan TokenGeneric, l.streamPrefetch)
+	errs := make(chan error, 1)
+
+	if l.streamStrings == StreamStringsUnsafe {
+		// strings of the first token must belong to the shared epoch too
+		l.startEpoch()
+		l.pinBuffers = true
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(tokens)
+		defer func() { l.pinBuffers = false }()
+
+		var arena []byte
+
+		for {
+			t, err := l.TokenFast()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			switch {
+			case t.epoch == 0 || t.str == "":
+				// nothing to copy
+			case l.streamStrings == StreamStringsCopy:
+				t.own()
+			case l.streamStrings == StreamStringsArena:
+				if len(t.str) > cap(arena)-len(arena) {
+					// strings that have already been copied keep pointing to the old chunk
+					size := l.bufSize
+					if len(t.str) > size {
+						size = len(t.str)
+					}
+
+					arena = make([]byte, 0, size)
+				}
+
+				start := len(arena)
+				arena = append(arena, t.str...)
+				t.str, t.epoch = unsafeStringFromBytes(arena[start:]), 0
+			}
+
+			select {
+			case tokens <- t:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return tokens, errs
+}
@@ -0,0 +1,136 @@
+package gojsonlex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestJSONLexerStream(t *testing.T) {
+	type testCase struct {
+		input  string
+		output []string
+		err    bool
+	}
+
+	testCases := []testCase{
+		{`{"a": [1, "b", true, null]}`, []string{"{", "a", ":", "[", "1", ",", "b", ",", "true", ",", "<nil>", "]", "}"}, false},
+		{`"a" "b"`, []string{"a", "b"}, false},
+		{``, nil, false},
+		{`{"a": 1x}`, []string{"{", "a", ":"}, true},
+	}
+
+	for _, testcase := range testCases {
+		l, err := NewJSONLexer(iotest.OneByteReader(strings.NewReader(testcase.input)))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+		l.SetSkipDelims(false)
+
+		tokens, errs := l.Stream(context.Background())
+
+		var output []string
+		for token := range tokens {
+			output = append(output, fmt.Sprint(token.jsonToken()))
+		}
+
+		err = <-errs
+		if (err != nil) != testcase.err {
+			t.Errorf("testcase '%s': unexpected error %v", testcase.input, err)
+			continue
+		}
+
+		if strings.Join(output, "|") != strings.Join(testcase.output, "|") {
+			t.Errorf("testcase '%s': got %v, expected %v", testcase.input, output, testcase.output)
+		}
+	}
+}
+
+func TestJSONLexerStreamCancel(t *testing.T) {
+	input := strings.Repeat(`{"a": "b"} `, 10*streamBufSize)
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tokens, errs := l.Stream(ctx)
+
+	<-tokens
+	cancel()
+
+	n := 1
+	for range tokens {
+		n++
+	}
+
+	if n >= 10*streamBufSize {
+		t.Errorf("stream has not been cancelled")
+	}
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestJSONLexerStreamStrings(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&input, `{"key%d": "value \"%d\"", "n": %d.5} `, i, i, i)
+	}
+
+	policies := []StreamStringPolicy{StreamStringsCopy, StreamStringsArena, StreamStringsUnsafe}
+
+	for _, policy := range policies {
+		l, err := NewJSONLexer(strings.NewReader(input.String()))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+		l.SetBufSize(16)
+		l.SetPoisonStrings(true)
+		l.SetRawNumbers(true)
+		l.SetStreamPrefetch(0)
+		l.SetStreamStrings(policy)
+
+		tokens, errs := l.Stream(context.Background())
+		if cap(tokens) != 0 {
+			t.Errorf("policy %d: got prefetch %d, expected 0", policy, cap(tokens))
+		}
+
+		// strings are checked only once the whole input has been lexed
+		var received []TokenGeneric
+		for token := range tokens {
+			received = append(received, token)
+		}
+
+		if err := <-errs; err != nil {
+			t.Fatalf("policy %d: %v", policy, err)
+		}
+
+		var expected strings.Builder
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(&expected, `key%d|value "%d"|n|%d.5|`, i, i, i)
+		}
+
+		var output strings.Builder
+		for _, token := range received {
+			fmt.Fprintf(&output, "%s|", token.String())
+
+			if err := l.Validate(token); err != nil {
+				t.Errorf("policy %d: token '%s' is reported as stale", policy, token.String())
+			}
+		}
+
+		if output.String() != expected.String() {
+			t.Errorf("policy %d: got '%s', expected '%s'", policy, output.String(), expected.String())
+		}
+
+		l.Reset(strings.NewReader(""))
+
+		if err := l.Validate(received[0]); (err != nil) != (policy == StreamStringsUnsafe) {
+			t.Errorf("policy %d: unexpected result of Validate after Reset: %v", policy, err)
+		}
+	}
+}
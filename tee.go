@@ -0,0 +1,44 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+)
+
+// TeeTokens reads all tokens from src and writes every one of them to each of the sinks
+// in order, so that several consumers (e.g. a validator, an extractor and an archiver)
+// are fed in one pass. Tokens are passed without copying: their strings are valid until
+// WriteToken returns and sinks keeping them longer MUST copy them (e.g. with StringCopy).
+// Sinks having a Flush() error method (e.g. TokenWriter) are flushed at the end of src.
+// The first error of src is returned as is, the first error of a sink stops the tee and
+// is returned along with the index of the sink.
+func TeeTokens(src TokenSource, sinks ...TokenSink) error {
+	for {
+		t, err := src.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		for i, sink := range sinks {
+			if err := sink.WriteToken(t); err != nil {
+				return fmt.Errorf("sink %d: %w", i, err)
+			}
+		}
+	}
+
+	for i, sink := range sinks {
+		f, ok := sink.(interface{ Flush() error })
+		if !ok {
+			continue
+		}
+
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("sink %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
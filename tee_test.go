@@ -0,0 +1,82 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// keysSink collects copies of object keys
+type keysSink struct {
+	keys []string
+}
+
+func (s *keysSink) WriteToken(t TokenGeneric) error {
+	if t.IsKey() {
+		s.keys = append(s.keys, t.StringCopy())
+	}
+
+	return nil
+}
+
+// failingSink fails once n tokens have been written
+type failingSink struct {
+	n int
+}
+
+func (s *failingSink) WriteToken(t TokenGeneric) error {
+	if s.n == 0 {
+		return errors.New("sink is full")
+	}
+
+	s.n--
+
+	return nil
+}
+
+func TestTeeTokens(t *testing.T) {
+	input := `{"a": "x", "b": [1, {"c": null}]} {"d": true}`
+	expected := `{"a":"x","b":[1,{"c":null}]}` + "\n" + `{"d":true}`
+
+	newLexer := func() *JSONLexer {
+		l, err := NewJSONLexer(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("could not create lexer: %v", err)
+		}
+
+		l.SetBufSize(4)
+		l.SetSkipDelims(false)
+
+		return l
+	}
+
+	var first, second bytes.Buffer
+	keys := &keysSink{}
+
+	err := TeeTokens(newLexer(), NewTokenWriter(&first), keys, NewTokenWriter(&second))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if first.String() != expected || second.String() != expected {
+		t.Errorf("got %q and %q, expected %q", first.String(), second.String(), expected)
+	}
+
+	if strings.Join(keys.keys, ",") != "a,b,c,d" {
+		t.Errorf("got keys %v, expected [a b c d]", keys.keys)
+	}
+
+	err = TeeTokens(newLexer(), keys, &failingSink{n: 3})
+	if err == nil || !strings.HasPrefix(err.Error(), "sink 1: ") {
+		t.Errorf("got error %v, expected error of sink 1", err)
+	}
+
+	l := newLexer()
+	l.SetMaxDepth(1)
+
+	var posErr *PositionError
+	if err := TeeTokens(l, keys); !errors.As(err, &posErr) {
+		t.Errorf("got error %v, expected *PositionError", err)
+	}
+}
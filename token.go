@@ -2,21 +2,26 @@ package gojsonlex
 
 import (
 	"fmt"
-	"reflect"
+	"strconv"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
-	"unsafe"
+
+	"github.com/gibsn/gojsonlex/jsonutil"
 )
 
+// TokenType is the kind of a token. Values are fixed and never reused, so they may be
+// persisted or sent over the wire.
 type TokenType byte
 
 const (
-	LexerTokenTypeDelim TokenType = iota
-	LexerTokenTypeString
-	LexerTokenTypeNumber
-	LexerTokenTypeBool
-	LexerTokenTypeNull
+	LexerTokenTypeDelim  TokenType = 0
+	LexerTokenTypeString TokenType = 1
+	LexerTokenTypeNumber TokenType = 2
+	LexerTokenTypeBool   TokenType = 3
+	LexerTokenTypeNull   TokenType = 4
+	// LexerTokenTypeInt is a number without fractional part and exponent
+	LexerTokenTypeInt TokenType = 5
 )
 
 const (
@@ -24,73 +29,133 @@ const (
 	utf16MaxWordsForRune = 2
 )
 
+var tokenTypeNames = [...]string{
+	LexerTokenTypeDelim:  "delim",
+	LexerTokenTypeString: "string",
+	LexerTokenTypeNumber: "number",
+	LexerTokenTypeBool:   "bool",
+	LexerTokenTypeNull:   "null",
+	LexerTokenTypeInt:    "int",
+}
+
 func (t TokenType) String() string {
+	if int(t) < len(tokenTypeNames) {
+		return tokenTypeNames[t]
+	}
+
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ParseTokenType returns the token type with the given name as returned by String
+func ParseTokenType(s string) (TokenType, error) {
+	for t, name := range tokenTypeNames {
+		if name == s {
+			return TokenType(t), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown token type '%s'", s)
+}
+
+// IsScalar reports whether tokens of the type are scalar values
+func (t TokenType) IsScalar() bool {
 	switch t {
-	case LexerTokenTypeDelim:
-		return "delim"
-	case LexerTokenTypeString:
-		return "string"
-	case LexerTokenTypeNumber:
-		return "number"
-	case LexerTokenTypeBool:
-		return "bool"
-	case LexerTokenTypeNull:
-		return "null"
+	case LexerTokenTypeString, LexerTokenTypeNumber, LexerTokenTypeInt,
+		LexerTokenTypeBool, LexerTokenTypeNull:
+		return true
 	}
 
-	panic("unknown token type")
+	return false
 }
 
-func unsafeStringFromBytes(arr []byte) string {
-	slice := (*reflect.SliceHeader)(unsafe.Pointer(&arr))
-	str := (*reflect.StringHeader)(unsafe.Pointer(slice))
-	str.Data = slice.Data
-	str.Len = slice.Len
+// IsStructural reports whether tokens of the type form the structure of a document
+// (delimiters). Object keys are strings, see TokenGeneric.IsKey.
+func (t TokenType) IsStructural() bool {
+	return t == LexerTokenTypeDelim
+}
 
-	return *(*string)(unsafe.Pointer(str))
+// TokenTypeSet is a set of token types
+type TokenTypeSet uint32
+
+// NewTokenTypeSet creates a set of the given token types
+func NewTokenTypeSet(types ...TokenType) TokenTypeSet {
+	var s TokenTypeSet
+	for _, t := range types {
+		s |= 1 << t
+	}
+
+	return s
+}
+
+// Contains reports whether the set contains the given token type
+func (s TokenTypeSet) Contains(t TokenType) bool {
+	return s&(1<<t) != 0
 }
 
 type bytesUnescaper struct {
-	writeIter int
-	readIter  int
-	input     []byte
+	input  []byte
+	output []byte // may share the underlying array with input
 
 	// state modificators
 	pendingEscapedSymbol bool
 	pendingUnicodeBytes  byte
+	pendingUnicodeRune   rune // accumulates hex digits of the current unicode sequence
 
 	// since in UTF-16 rune may be encoded by either 1 or 2 words we
 	// may have to remember the previous word
 	pendingSecondUTF16SeqPoint bool
 	firstUTF16SeqPoint         rune
+
+	extendedEscapes bool // accept escapes from JSON5: \', \v and \0
 }
 
 // UnescapeBytesInplace iterates over the given slice of byte unescaping all
 // escaped symbols inplace. Since the unescaped symbols take less space the shrinked
 // slice of bytes is returned
 func UnescapeBytesInplace(input []byte) ([]byte, error) {
+	return unescapeBytesInplace(input, false)
+}
+
+func unescapeBytesInplace(input []byte, extendedEscapes bool) ([]byte, error) {
+	// unescaped symbols never take more space than escaped ones so writing can
+	// never overtake reading
+	u := bytesUnescaper{
+		input:           input,
+		output:          input[:0],
+		extendedEscapes: extendedEscapes,
+	}
+
+	return u.doUnescaping()
+}
+
+// UnescapeBytes unescapes all escaped symbols in src and appends the result to dst
+// returning the extended slice. Unlike UnescapeBytesInplace src is never modified,
+// which makes it suitable for immutable buffers (e.g. mmaped files). dst and src
+// MUST NOT overlap.
+func UnescapeBytes(dst, src []byte) ([]byte, error) {
 	u := bytesUnescaper{
-		input: input,
+		input:  src,
+		output: dst,
 	}
 
 	return u.doUnescaping()
 }
 
 func (u *bytesUnescaper) processUnicodeByte(c byte) error {
-	u.pendingUnicodeBytes--
-	if u.pendingUnicodeBytes != 0 {
-		return nil
+	v, ok := jsonutil.HexDigitValue(c)
+	if !ok {
+		return fmt.Errorf("invalid hex digit '%c' inside unicode sequence", c)
 	}
 
-	// processing the last byte of unicode sequence
-	utf16Sequence := u.input[u.readIter+1-utf16SequenceLength : u.readIter+1]
+	u.pendingUnicodeRune = u.pendingUnicodeRune<<4 | rune(v)
 
-	runeAsUint, err := HexBytesToUint(utf16Sequence)
-	if err != nil {
-		return fmt.Errorf("invalid unicode sequence \\u%s", utf16Sequence)
+	u.pendingUnicodeBytes--
+	if u.pendingUnicodeBytes != 0 {
+		return nil
 	}
 
-	outRune := rune(runeAsUint)
+	// processed the last byte of unicode sequence
+	outRune := u.pendingUnicodeRune
 
 	if utf16.IsSurrogate(outRune) && !u.pendingSecondUTF16SeqPoint {
 		u.pendingSecondUTF16SeqPoint = true
@@ -107,8 +172,10 @@ func (u *bytesUnescaper) processUnicodeByte(c byte) error {
 		u.pendingSecondUTF16SeqPoint = false
 	}
 
-	n := utf8.EncodeRune(u.input[u.writeIter:], outRune)
-	u.writeIter += n
+	var runeBuf [utf8.UTFMax]byte
+
+	n := utf8.EncodeRune(runeBuf[:], outRune)
+	u.output = append(u.output, runeBuf[:n]...)
 
 	return nil
 }
@@ -118,6 +185,7 @@ func (u *bytesUnescaper) processSpecialByte(c byte) error {
 
 	if c == 'u' || c == 'U' {
 		u.pendingUnicodeBytes = utf16SequenceLength
+		u.pendingUnicodeRune = 0
 		return nil
 	}
 
@@ -125,6 +193,10 @@ func (u *bytesUnescaper) processSpecialByte(c byte) error {
 		return fmt.Errorf("missing second sequence point for %x", u.firstUTF16SeqPoint)
 	}
 
+	if !u.extendedEscapes && (c == '\'' || c == 'v' || c == '0') {
+		return fmt.Errorf("invalid escape sequence \\%c", c)
+	}
+
 	var outRune byte
 
 	switch c {
@@ -144,12 +216,17 @@ func (u *bytesUnescaper) processSpecialByte(c byte) error {
 		outRune = '/'
 	case '"':
 		outRune = '"'
+	case '\'':
+		outRune = '\''
+	case 'v':
+		outRune = '\v'
+	case '0':
+		outRune = 0
 	default:
 		return fmt.Errorf("invalid escape sequence \\%c", c)
 	}
 
-	u.input[u.writeIter] = outRune
-	u.writeIter++
+	u.output = append(u.output, outRune)
 
 	return nil
 }
@@ -159,8 +236,7 @@ func (u *bytesUnescaper) processBackSlashByte(c byte) {
 }
 
 func (u *bytesUnescaper) processRegularByte(c byte) {
-	u.input[u.writeIter] = c
-	u.writeIter++
+	u.output = append(u.output, c)
 }
 
 func (u *bytesUnescaper) terminate() error {
@@ -169,16 +245,16 @@ func (u *bytesUnescaper) terminate() error {
 	}
 
 	if u.pendingEscapedSymbol || u.pendingUnicodeBytes > 0 {
-		return fmt.Errorf("incomplete escape sequence %s", string(u.input[u.writeIter:]))
+		return fmt.Errorf("incomplete escape sequence at the end of input")
 	}
 
 	return nil
 }
 
-func (u *bytesUnescaper) doUnescaping() (_ []byte, err error) {
-	for u.readIter = 0; u.readIter < len(u.input); u.readIter++ {
-		currByte := u.input[u.readIter]
-
+// unescapeChunk unescapes the given chunk of input appending the result to output.
+// Escape sequences may span several consecutive chunks.
+func (u *bytesUnescaper) unescapeChunk(chunk []byte) (err error) {
+	for _, currByte := range chunk {
 		switch {
 		case u.pendingUnicodeBytes > 0:
 			err = u.processUnicodeByte(currByte)
@@ -191,15 +267,23 @@ func (u *bytesUnescaper) doUnescaping() (_ []byte, err error) {
 		}
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	if err = u.terminate(); err != nil {
+	return nil
+}
+
+func (u *bytesUnescaper) doUnescaping() ([]byte, error) {
+	if err := u.unescapeChunk(u.input); err != nil {
+		return nil, err
+	}
+
+	if err := u.terminate(); err != nil {
 		return nil, err
 	}
 
-	return u.input[:u.writeIter], nil
+	return u.output, nil
 }
 
 // StringDeepCopy creates a copy of the given string with it's own underlying bytearray.
@@ -208,76 +292,49 @@ func StringDeepCopy(s string) string {
 	return unsafeStringFromBytes([]byte(s))
 }
 
-// IsDelim reports whether the given rune is a JSON delimiter
+// IsDelim is an alias of jsonutil.IsDelim kept for compatibility
 func IsDelim(c rune) bool {
-	switch c {
-	case '{', '}', '[', ']', ':', ',':
-		return true
-	}
-
-	return false
+	return jsonutil.IsDelim(c)
 }
 
-// IsValidEscapedSymbol reports whether the given rune is one of the special symbols
-// permitted in JSON
+// IsValidEscapedSymbol is an alias of jsonutil.IsValidEscapedSymbol kept for compatibility
 func IsValidEscapedSymbol(c rune) bool {
-	switch c {
-	case 'n', 'r', 't', 'b', 'f', '\\', '/', '"', 'u', 'U':
-		return true
-	}
-
-	return false
+	return jsonutil.IsValidEscapedSymbol(c)
 }
 
-// IsHexDigit reports whether the given rune is a valid hex digit
+// IsHexDigit is an alias of jsonutil.IsHexDigit kept for compatibility
 func IsHexDigit(c rune) bool {
-	switch {
-	case unicode.IsDigit(c):
-		fallthrough
-	case 'a' <= c && c <= 'f':
-		fallthrough
-	case 'A' <= c && c <= 'F':
-		return true
-	}
-
-	return false
+	return jsonutil.IsHexDigit(c)
 }
 
-// CanAppearInNUmber reports whether the given rune can appear in a JSON number
+// CanAppearInNumber is an alias of jsonutil.CanAppearInNumber kept for compatibility
 func CanAppearInNumber(c rune) bool {
-	switch {
-	case unicode.IsDigit(c):
-		fallthrough
-	case c == '-', c == '+':
-		fallthrough
-	case c == '.':
-		fallthrough
-	case c == 'e', c == 'E':
-		return true
-	}
-
-	return false
+	return jsonutil.CanAppearInNumber(c)
 }
 
-func HexBytesToUint(in []byte) (result uint64, err error) {
-	for _, c := range in {
-		result *= 0x10
-
-		var v byte
+// maxExactDecimalDigits is the number of significant decimal digits that always survive
+// a round trip through float64
+const maxExactDecimalDigits = 15
 
-		switch {
-		case '0' <= c && c <= '9':
-			v = c - '0'
-		case 'a' <= c && c <= 'f':
-			v = c - 'a' + 10
-		case 'A' <= c && c <= 'F':
-			v = c - 'A' + 10
-		default:
-			return 0, fmt.Errorf("'%s' is not a hex number", string(in))
-		}
+// numberLosesPrecision reports whether the textual number s differs from its float64
+// representation f. buf is used as a scratch space and is returned for reuse.
+func numberLosesPrecision(s string, f float64, buf []byte) (bool, []byte) {
+	buf = jsonutil.AppendSignificantDigits(buf[:0], s)
 
-		result += uint64(v)
+	inputDigits := len(buf)
+	if inputDigits <= maxExactDecimalDigits {
+		return false, buf
 	}
 
-	return result, nil
+	// shortest representation that converts back to exactly f
+	buf = strconv.AppendFloat(buf, f, 'e', -1, 64)
+	formatted := unsafeStringFromBytes(buf[inputDigits:])
+	buf = jsonutil.AppendSignificantDigits(buf, formatted)
+
+	return string(buf[:inputDigits]) != string(buf[inputDigits+len(formatted):]), buf
+}
+
+// HexBytesToUint is an alias of jsonutil.HexBytesToUint kept for compatibility
+func HexBytesToUint(in []byte) (result uint64, err error) {
+	return jsonutil.HexBytesToUint(in)
 }
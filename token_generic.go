@@ -1,49 +1,249 @@
 package gojsonlex
 
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
 // TokenGeneric is a generic struct used to represent any possible JSON token
 type TokenGeneric struct {
 	t TokenType
 
 	boolean bool
-	str     string
+	str     string // also the textual form of numbers returned by the lexer
 	number  float64
 	delim   byte
+
+	key      bool  // string is an object key
+	lossy    bool  // number could not be converted to float64 exactly
+	unparsed bool  // number has not been converted, see SetRawNumbers
+	class    uint8 // 1-based index of the value class of the string, see SetValueClasses
+
+	integer  uint64 // absolute value of an integer token
+	negative bool   // reports whether the integer is negative
+
+	epoch uint32 // epoch of the lexer the string belongs to, 0 if the string is owned
+}
+
+// Delim is a JSON delimiter: one of '{', '}', '[', ']', ':' and ','. It can be converted
+// to json.Delim directly.
+type Delim byte
+
+func (d Delim) String() string {
+	return string(rune(d))
+}
+
+// IsOpen reports whether the delimiter opens an object or an array
+func (d Delim) IsOpen() bool {
+	return d == '{' || d == '['
+}
+
+// IsClose reports whether the delimiter closes an object or an array
+func (d Delim) IsClose() bool {
+	return d == '}' || d == ']'
+}
+
+// Matches reports whether the delimiters open and close the same kind of container
+// (in any order)
+func (d Delim) Matches(other Delim) bool {
+	switch {
+	case d == '{':
+		return other == '}'
+	case d == '[':
+		return other == ']'
+	case d == '}':
+		return other == '{'
+	case d == ']':
+		return other == '['
+	}
+
+	return false
+}
+
+// TokenSource is anything producing a stream of JSON tokens (e.g. JSONLexer), io.EOF
+// is returned at the end of the stream
+type TokenSource interface {
+	TokenFast() (TokenGeneric, error)
+}
+
+// TokenSink is anything consuming a stream of JSON tokens (e.g. TokenWriter)
+type TokenSink interface {
+	WriteToken(t TokenGeneric) error
+}
+
+// TokenRecorder records tokens making copies of their strings (and of textual forms of
+// numbers), so that the recorded tokens stay valid after the lexer moves on. The copies are
+// kept in an arena reused after Reset. The zero value is ready to use.
+type TokenRecorder struct {
+	tokens []TokenGeneric
+	arena  []byte
+	pos    int // index of the next token replayed by TokenFast
+}
+
+// Reset drops all recorded tokens, the memory is reused by the following recordings
+func (r *TokenRecorder) Reset() {
+	r.tokens = r.tokens[:0]
+	r.arena = r.arena[:0]
+	r.pos = 0
 }
 
-func newTokenGenericFromString(s string) TokenGeneric {
+// Record appends a copy of the token to the recorded ones
+func (r *TokenRecorder) Record(t TokenGeneric) {
+	if t.t == LexerTokenTypeString || t.str != "" {
+		// strings that have already been recorded keep pointing to the old array in case
+		// arena gets reallocated
+		start := len(r.arena)
+		r.arena = append(r.arena, t.str...)
+		t.str = unsafeStringFromBytes(r.arena[start:])
+		t.epoch = 0
+	}
+
+	r.tokens = append(r.tokens, t)
+}
+
+// Tokens returns the recorded tokens, they are valid until Reset
+func (r *TokenRecorder) Tokens() []TokenGeneric {
+	return r.tokens
+}
+
+// TokenFast replays the recorded tokens making TokenRecorder a TokenSource, io.EOF is
+// returned once all of them have been replayed
+func (r *TokenRecorder) TokenFast() (TokenGeneric, error) {
+	if r.pos >= len(r.tokens) {
+		return TokenGeneric{}, io.EOF
+	}
+
+	r.pos++
+
+	return r.tokens[r.pos-1], nil
+}
+
+// NewTokenGenericFromString creates a string token
+func NewTokenGenericFromString(s string) TokenGeneric {
 	return TokenGeneric{
 		t:   LexerTokenTypeString,
 		str: s,
 	}
 }
 
-func newTokenGenericFromNumber(f float64) TokenGeneric {
+// NewTokenGenericFromBytesUnsafe creates a string token pointing into b without making
+// a copy, it is valid as long as b is not modified. This is the counterpart of BytesUnsafe
+// for passing reused buffers to TokenWriter without a conversion allocation.
+func NewTokenGenericFromBytesUnsafe(b []byte) TokenGeneric {
+	return NewTokenGenericFromString(unsafeStringFromBytes(b))
+}
+
+// NewTokenGenericFromNumber creates a number token
+func NewTokenGenericFromNumber(f float64) TokenGeneric {
 	return TokenGeneric{
 		t:      LexerTokenTypeNumber,
 		number: f,
 	}
 }
 
-func newTokenGenericFromBool(b bool) TokenGeneric {
+// NewTokenGenericFromRawNumber creates a number token from its textual form, which MUST
+// be a valid JSON number. The text is written by TokenWriter as is, so big and precise
+// numbers are not rounded, and is returned by NumberRaw.
+func NewTokenGenericFromRawNumber(s string) TokenGeneric {
+	return TokenGeneric{
+		t:        LexerTokenTypeNumber,
+		str:      s,
+		unparsed: true,
+	}
+}
+
+// NewTokenGenericFromInt64 creates an integer token
+func NewTokenGenericFromInt64(i int64) TokenGeneric {
+	t := TokenGeneric{
+		t:        LexerTokenTypeInt,
+		number:   float64(i),
+		integer:  uint64(i),
+		negative: i < 0,
+	}
+
+	if t.negative {
+		t.integer = -t.integer
+	}
+
+	return t
+}
+
+// NewTokenGenericFromUint64 creates an integer token
+func NewTokenGenericFromUint64(u uint64) TokenGeneric {
+	return TokenGeneric{
+		t:       LexerTokenTypeInt,
+		number:  float64(u),
+		integer: u,
+	}
+}
+
+// NewTokenGenericFromBool creates a bool token
+func NewTokenGenericFromBool(b bool) TokenGeneric {
 	return TokenGeneric{
 		t:       LexerTokenTypeBool,
 		boolean: b,
 	}
 }
 
-func newTokenGenericFromNull() TokenGeneric {
+// NewTokenGenericFromNull creates a null token
+func NewTokenGenericFromNull() TokenGeneric {
 	return TokenGeneric{
 		t: LexerTokenTypeNull,
 	}
 }
 
-func newTokenGenericFromDelim(d byte) TokenGeneric {
+// NewTokenGenericFromDelim creates a delimiter token
+func NewTokenGenericFromDelim(d byte) TokenGeneric {
 	return TokenGeneric{
 		t:     LexerTokenTypeDelim,
 		delim: d,
 	}
 }
 
+// jsonToken converts the token to json.Token the way JSONLexer.Token does it
+func (t *TokenGeneric) jsonToken() json.Token {
+	switch t.t {
+	case LexerTokenTypeNull:
+		return nil
+	case LexerTokenTypeDelim:
+		return json.Delim(t.delim)
+	case LexerTokenTypeInt:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+
+		return t.integer
+	case LexerTokenTypeNumber:
+		if t.lossy || t.unparsed {
+			return json.Number(t.str)
+		}
+
+		return t.number
+	case LexerTokenTypeString:
+		return t.str
+	case LexerTokenTypeBool:
+		return t.boolean
+	}
+
+	panic("unknown token type")
+}
+
+// own makes the string of the token owned, so that it stays valid after the next call
+// to the lexer
+func (t *TokenGeneric) own() {
+	if t.epoch != 0 {
+		t.str, t.epoch = StringDeepCopy(t.str), 0
+	}
+}
+
+func (t *TokenGeneric) isCloseDelim() bool {
+	return t.t == LexerTokenTypeDelim && (t.delim == '}' || t.delim == ']')
+}
+
 // Type returns type of the token
 func (t *TokenGeneric) Type() TokenType {
 	return t.t
@@ -55,23 +255,117 @@ func (t *TokenGeneric) String() string {
 	return t.str
 }
 
+// BytesUnsafe returns the contents of a string token as a slice pointing into the internal
+// lexer buffer, so it can be passed to APIs taking []byte without a conversion allocation.
+// It is valid under the same conditions as String() and MUST NOT be modified.
+func (t *TokenGeneric) BytesUnsafe() []byte {
+	return unsafeBytesFromString(t.str)
+}
+
+// IsKey reports whether the token is a string used as an object key. Only the lexer sets
+// it, so tokens created with NewTokenGenericFromString are never keys.
+func (t *TokenGeneric) IsKey() bool {
+	return t.key
+}
+
+// Class returns the index of the first class passed to JSONLexer.SetValueClasses that
+// matches the string value, -1 if none does (keys and other tokens are not classified)
+func (t *TokenGeneric) Class() int {
+	return int(t.class) - 1
+}
+
+// StringEquals reports whether the token is a string equal to s. The comparison is done
+// against the internal lexer buffer, so no allocations or copies are made. This is the
+// preferred way to check whether a key is the one you are looking for.
+func (t *TokenGeneric) StringEquals(s string) bool {
+	return t.t == LexerTokenTypeString && t.str == s
+}
+
+// StringEqualFold is the same as StringEquals but compares strings case-insensitively
+// (under Unicode case-folding)
+func (t *TokenGeneric) StringEqualFold(s string) bool {
+	return t.t == LexerTokenTypeString && strings.EqualFold(t.str, s)
+}
+
 // StringCopy return a deep copy of string
 func (t *TokenGeneric) StringCopy() string {
 	return StringDeepCopy(t.str)
 }
 
+// WriteStringTo writes the contents of a string token to w without making any
+// intermediate copies. Since hash.Hash is an io.Writer, this is the cheapest way to hash
+// string values (e.g. for dedup or sharding) without materializing them.
+func (t *TokenGeneric) WriteStringTo(w io.Writer) (int, error) {
+	return w.Write(unsafeBytesFromString(t.str))
+}
+
 func (t *TokenGeneric) Bool() bool {
 	return t.boolean
 }
 
-func (t *TokenGeneric) Delim() byte {
-	return t.delim
+// Delim returns the delimiter of a delimiter token
+func (t *TokenGeneric) Delim() Delim {
+	return Delim(t.delim)
 }
 
 func (t *TokenGeneric) Number() float64 {
+	if t.unparsed {
+		// the syntax has been validated by the lexer
+		n, _ := strconv.ParseFloat(t.str, 64)
+		return n
+	}
+
 	return t.number
 }
 
+// NumberRaw returns the textual form of a number token exactly as it appears in the input
+// (e.g. "1.570e+10"), it is valid under the same conditions as String(). Number tokens not
+// returned by the lexer have no textual form, for them as well as for other tokens an
+// empty string is returned.
+func (t *TokenGeneric) NumberRaw() string {
+	if t.t != LexerTokenTypeNumber && t.t != LexerTokenTypeInt {
+		return ""
+	}
+
+	return t.str
+}
+
+// Int64 returns the value of an integer token (see SetIntegers). An error is returned for
+// other tokens and for integers out of the int64 range.
+func (t *TokenGeneric) Int64() (int64, error) {
+	switch {
+	case t.t != LexerTokenTypeInt:
+		return 0, fmt.Errorf("%s token is not an integer", t.t)
+	case t.negative:
+		// -(1 << 63) is converted correctly as well
+		return -int64(t.integer), nil
+	case t.integer > math.MaxInt64:
+		return 0, fmt.Errorf("%d overflows int64", t.integer)
+	}
+
+	return int64(t.integer), nil
+}
+
+// Uint64 returns the value of an integer token (see SetIntegers). An error is returned for
+// other tokens and for negative integers.
+func (t *TokenGeneric) Uint64() (uint64, error) {
+	switch {
+	case t.t != LexerTokenTypeInt:
+		return 0, fmt.Errorf("%s token is not an integer", t.t)
+	case t.negative && t.integer != 0:
+		return 0, fmt.Errorf("-%d overflows uint64", t.integer)
+	}
+
+	return t.integer, nil
+}
+
+// IsLossyNumber reports whether the number could not be converted to float64 without
+// loss of precision. Such numbers are only marked with PrecisionLossRaw policy, in that
+// case String() returns their textual form.
+func (t *TokenGeneric) IsLossyNumber() bool {
+	return t.lossy
+}
+
 func (t *TokenGeneric) IsNull() bool {
 	return t.t == LexerTokenTypeNull
 }
@@ -0,0 +1,301 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokenGenericWriteStringTo(t *testing.T) {
+	input := `{"ip": "5.61.233.11", "ua": "\"Go-http-client/1.1\"", "desc": "почта"}`
+	expected := []string{"ip", "5.61.233.11", "ua", "\"Go-http-client/1.1\"", "desc", "почта"}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	for i := 0; ; i++ {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not get next token: %v", err)
+		}
+
+		out := &bytes.Buffer{}
+		if _, err := token.WriteStringTo(out); err != nil {
+			t.Errorf("testcase '%s': %v", expected[i], err)
+			continue
+		}
+
+		if out.String() != expected[i] {
+			t.Errorf("testcase '%s': got '%s'", expected[i], out.String())
+		}
+
+		h1, h2 := fnv.New64a(), fnv.New64a()
+		token.WriteStringTo(h1)
+		h2.Write([]byte(expected[i]))
+
+		if h1.Sum64() != h2.Sum64() {
+			t.Errorf("testcase '%s': hashes do not match", expected[i])
+		}
+	}
+}
+
+func TestTokenGenericWriteStringToAllocs(t *testing.T) {
+	token := NewTokenGenericFromString("5.61.233.11")
+	h := fnv.New64a()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		token.WriteStringTo(h)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestTokenGenericBytesUnsafe(t *testing.T) {
+	input := `["5.61.233.11", "\"Go-http-client/1.1\"", "почта", ""]`
+	expected := []string{"5.61.233.11", "\"Go-http-client/1.1\"", "почта", ""}
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	for i := 0; ; i++ {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not get next token: %v", err)
+		}
+
+		if !bytes.Equal(token.BytesUnsafe(), []byte(expected[i])) {
+			t.Errorf("testcase '%s': got '%s'", expected[i], token.BytesUnsafe())
+		}
+	}
+
+	token := NewTokenGenericFromString("5.61.233.11")
+	h := fnv.New64a()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		h.Write(token.BytesUnsafe())
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+type tokenGenericStringEqualsTestCase struct {
+	token  TokenGeneric
+	input  string
+	output bool
+}
+
+func TestTokenGenericStringEquals(t *testing.T) {
+	testcases := []tokenGenericStringEqualsTestCase{
+		{NewTokenGenericFromString("origin"), "origin", true},
+		{NewTokenGenericFromString("origin"), "Origin", false},
+		{NewTokenGenericFromString("origin"), "origins", false},
+		{NewTokenGenericFromString(""), "", true},
+		{NewTokenGenericFromNull(), "", false},
+		{NewTokenGenericFromBool(true), "true", false},
+	}
+
+	for _, testcase := range testcases {
+		currOut := testcase.token.StringEquals(testcase.input)
+
+		if currOut != testcase.output {
+			t.Errorf("testcase '%s': got '%t', expected '%t'",
+				testcase.input, currOut, testcase.output)
+		}
+	}
+}
+
+func TestTokenGenericStringEqualFold(t *testing.T) {
+	testcases := []tokenGenericStringEqualsTestCase{
+		{NewTokenGenericFromString("origin"), "origin", true},
+		{NewTokenGenericFromString("origin"), "ORIGIN", true},
+		{NewTokenGenericFromString("Проверка"), "пРОВЕРКА", true},
+		{NewTokenGenericFromString("origin"), "origins", false},
+		{NewTokenGenericFromNull(), "", false},
+	}
+
+	for _, testcase := range testcases {
+		currOut := testcase.token.StringEqualFold(testcase.input)
+
+		if currOut != testcase.output {
+			t.Errorf("testcase '%s': got '%t', expected '%t'",
+				testcase.input, currOut, testcase.output)
+		}
+	}
+}
+
+type delimTestCase struct {
+	delim   Delim
+	isOpen  bool
+	isClose bool
+	matches Delim
+}
+
+func TestDelim(t *testing.T) {
+	testcases := []delimTestCase{
+		{'{', true, false, '}'},
+		{'[', true, false, ']'},
+		{'}', false, true, '{'},
+		{']', false, true, '['},
+		{':', false, false, 0},
+		{',', false, false, 0},
+	}
+
+	for _, testcase := range testcases {
+		if testcase.delim.String() != json.Delim(testcase.delim).String() {
+			t.Errorf("testcase '%s': got '%s' from json.Delim",
+				testcase.delim, json.Delim(testcase.delim))
+		}
+
+		if testcase.delim.IsOpen() != testcase.isOpen || testcase.delim.IsClose() != testcase.isClose {
+			t.Errorf("testcase '%s': got IsOpen() %t, IsClose() %t",
+				testcase.delim, testcase.delim.IsOpen(), testcase.delim.IsClose())
+		}
+
+		for _, other := range []Delim{'{', '}', '[', ']', ':', ','} {
+			if testcase.delim.Matches(other) != (other == testcase.matches) {
+				t.Errorf("testcase '%s': got Matches('%s') %t",
+					testcase.delim, other, testcase.delim.Matches(other))
+			}
+		}
+	}
+
+	token := NewTokenGenericFromDelim('[')
+	if d := token.Delim(); !d.IsOpen() || d != '[' {
+		t.Errorf("got delimiter '%s', expected '['", d)
+	}
+}
+
+type tokenGenericIntegerTestCase struct {
+	token TokenGeneric
+	i     string // result of Int64(), "!" for an error
+	u     string // result of Uint64(), "!" for an error
+}
+
+func TestTokenGenericIntegers(t *testing.T) {
+	testcases := []tokenGenericIntegerTestCase{
+		{NewTokenGenericFromInt64(-5), "-5", "!"},
+		{NewTokenGenericFromInt64(math.MinInt64), "-9223372036854775808", "!"},
+		{NewTokenGenericFromInt64(math.MaxInt64), "9223372036854775807", "9223372036854775807"},
+		{NewTokenGenericFromUint64(math.MaxUint64), "!", "18446744073709551615"},
+		{NewTokenGenericFromUint64(0), "0", "0"},
+		{NewTokenGenericFromNumber(1), "!", "!"},
+		{NewTokenGenericFromString("1"), "!", "!"},
+	}
+
+	format := func(v interface{}, err error) string {
+		if err != nil {
+			return "!"
+		}
+
+		return fmt.Sprint(v)
+	}
+
+	for _, testcase := range testcases {
+		i, err := testcase.token.Int64()
+		if got := format(i, err); got != testcase.i {
+			t.Errorf("testcase '%v': got Int64() = %s, expected %s", testcase.token, got, testcase.i)
+		}
+
+		u, err := testcase.token.Uint64()
+		if got := format(u, err); got != testcase.u {
+			t.Errorf("testcase '%v': got Uint64() = %s, expected %s", testcase.token, got, testcase.u)
+		}
+	}
+}
+
+func TestTokenRecorder(t *testing.T) {
+	input := `{"name": "Bob", "big": 12345678901234567890, "tags": ["a\nb", 1.50, null]}`
+	expected := `{"name":"Bob","big":12345678901234567890,"tags":["a\nb",1.50,null]}`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	// strings and raw numbers of the lexer get overwritten while recording
+	l.SetBufSize(4)
+	l.SetSkipDelims(false)
+	l.SetRawNumbers(true)
+	l.SetPoisonStrings(true)
+
+	r := TokenRecorder{}
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not get next token: %v", err)
+		}
+
+		r.Record(token)
+	}
+
+	b := &bytes.Buffer{}
+	w := NewTokenWriter(b)
+
+	// TokenRecorder is a TokenSource replaying the recorded tokens
+	var src TokenSource = &r
+
+	for {
+		token, err := src.TokenFast()
+		if err == io.EOF {
+			break
+		}
+
+		if err := w.WriteToken(token); err != nil {
+			t.Fatalf("could not write token: %v", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if got := b.String(); got != expected {
+		t.Errorf("got '%s', expected '%s'", got, expected)
+	}
+
+	r.Reset()
+	if len(r.Tokens()) != 0 {
+		t.Errorf("got %d tokens after Reset", len(r.Tokens()))
+	}
+}
+
+func TestNewTokenGenericFromRawNumber(t *testing.T) {
+	token := NewTokenGenericFromRawNumber("9007199254740993")
+
+	if token.Type() != LexerTokenTypeNumber || token.NumberRaw() != "9007199254740993" {
+		t.Errorf("got %s token '%s'", token.Type(), token.NumberRaw())
+	}
+
+	b := &bytes.Buffer{}
+	w := NewTokenWriter(b)
+
+	if err := w.WriteToken(token); err != nil {
+		t.Fatalf("could not write token: %v", err)
+	}
+	w.Flush()
+
+	if b.String() != "9007199254740993" {
+		t.Errorf("got '%s'", b.String())
+	}
+}
@@ -84,6 +84,60 @@ func TestUnescapeBytesInplaceFails(t *testing.T) {
 	}
 }
 
+type unescapeBytesTestCase struct {
+	dst    []byte
+	src    []byte
+	output []byte
+}
+
+func TestUnescapeBytes(t *testing.T) {
+	testcases := []unescapeBytesTestCase{
+		{nil, []byte(""), []byte("")},
+		{nil, []byte("hello\\nworld"), []byte("hello\nworld")},
+		{[]byte("prefix "), []byte("hello\\tworld"), []byte("prefix hello\tworld")},
+		{[]byte("prefix "), []byte("\\\"hello world\\\""), []byte("prefix \"hello world\"")},
+		{
+			make([]byte, 0, 64),
+			[]byte("hello \\u043f\\u0440\\u0438\\u0432\\u0435\\u0442 world"),
+			[]byte("hello привет world"),
+		},
+		{nil, []byte("hello \\UD83D\\UDCA9 world"), []byte("hello 💩 world")},
+	}
+	for _, testcase := range testcases {
+		currIn := string(testcase.src) // making a copy
+
+		currOut, err := UnescapeBytes(testcase.dst, testcase.src)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", currIn, err)
+			continue
+		}
+
+		if string(testcase.output) != string(currOut) {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				currIn, string(currOut), string(testcase.output))
+		}
+
+		if currIn != string(testcase.src) {
+			t.Errorf("testcase '%s': src was modified to '%s'", currIn, string(testcase.src))
+		}
+	}
+}
+
+func TestUnescapeBytesFails(t *testing.T) {
+	testcases := []unescapeBytesTestCase{
+		{src: []byte("\\")},
+		{src: []byte("\\a")},
+		{src: []byte("\\u043")},
+		{src: []byte("hello \\ud83d\\ufca9 world")},
+		{src: []byte("hello \\ud83d world")},
+	}
+	for _, testcase := range testcases {
+		if _, err := UnescapeBytes(nil, testcase.src); err == nil {
+			t.Errorf("testcase '%s': must have failed", string(testcase.src))
+		}
+	}
+}
+
 type hexBytesToUintTestcase struct {
 	input  []byte
 	output uint64
@@ -165,3 +219,48 @@ func TestCanAppearInNumber(t *testing.T) {
 }
 
 // TODO tests for IsDelim
+
+type tokenTypeTestCase struct {
+	tokenType    TokenType
+	name         string
+	isScalar     bool
+	isStructural bool
+}
+
+func TestTokenType(t *testing.T) {
+	testcases := []tokenTypeTestCase{
+		{LexerTokenTypeDelim, "delim", false, true},
+		{LexerTokenTypeString, "string", true, false},
+		{LexerTokenTypeNumber, "number", true, false},
+		{LexerTokenTypeBool, "bool", true, false},
+		{LexerTokenTypeNull, "null", true, false},
+		{LexerTokenTypeInt, "int", true, false},
+	}
+
+	for _, testcase := range testcases {
+		if testcase.tokenType.String() != testcase.name {
+			t.Errorf("testcase '%s': got name '%s'", testcase.name, testcase.tokenType)
+		}
+
+		parsed, err := ParseTokenType(testcase.name)
+		if err != nil || parsed != testcase.tokenType {
+			t.Errorf("testcase '%s': parsed as %d (%v)", testcase.name, parsed, err)
+		}
+
+		if testcase.tokenType.IsScalar() != testcase.isScalar {
+			t.Errorf("testcase '%s': got IsScalar() %t", testcase.name, !testcase.isScalar)
+		}
+
+		if testcase.tokenType.IsStructural() != testcase.isStructural {
+			t.Errorf("testcase '%s': got IsStructural() %t", testcase.name, !testcase.isStructural)
+		}
+	}
+
+	if name := TokenType(200).String(); name != "TokenType(200)" {
+		t.Errorf("got name '%s' for an unknown type", name)
+	}
+
+	if _, err := ParseTokenType("object"); err == nil {
+		t.Errorf("parsing an unknown type must have failed")
+	}
+}
@@ -0,0 +1,328 @@
+package gojsonlex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// KeyPolicy defines what TokenWriter does with tokens other than strings written in place
+// of object keys
+type KeyPolicy byte
+
+const (
+	// KeyPolicyError makes such keys an error (default)
+	KeyPolicyError KeyPolicy = iota
+	// KeyPolicyStringify writes such keys as strings formatted the same way as values,
+	// e.g. 1.50 becomes "1.5" and null becomes "null"
+	KeyPolicyStringify
+	// KeyPolicyPassThrough writes such keys as they are, e.g. {1:true}. The output is not
+	// valid JSON, it is meant for consumers accepting lenient dialects.
+	KeyPolicyPassThrough
+)
+
+type writerFrame struct {
+	isObject  bool
+	expectKey bool // reports whether the next token in the object must be a key
+	elems     int  // number of elements (or members) written so far
+}
+
+// TokenWriter serializes a stream of JSON tokens into compact JSON. TokenWriter places
+// commas and colons itself, so ',' and ':' delimiters are ignored and may be omitted
+// by the caller. Top-level values are separated with newlines.
+//
+// TokenWriter does its own buffering, Flush MUST be called once all tokens have been
+// written.
+type TokenWriter struct {
+	w   io.Writer
+	buf []byte
+
+	stack          []writerFrame
+	topLevelValues int
+
+	escapeFlags EscapeFlags
+	jsonSeq     bool
+	keyPolicy   KeyPolicy
+	keyBuf      []byte // scratch space for stringified keys
+
+	tokenPacer pacer
+}
+
+// NewTokenWriter creates a new TokenWriter writing to w
+func NewTokenWriter(w io.Writer) *TokenWriter {
+	return &TokenWriter{
+		w:   w,
+		buf: make([]byte, 0, defaultBufSize),
+	}
+}
+
+// SetEscapeFlags sets flags used to escape strings, see EscapeFlags
+func (tw *TokenWriter) SetEscapeFlags(flags EscapeFlags) {
+	tw.escapeFlags = flags
+}
+
+// SetJSONSeq makes TokenWriter produce a JSON text sequence (RFC 7464, application/json-seq):
+// every top-level value is preceded by the ASCII Record Separator 0x1E and followed by
+// a newline. MUST be called before writing started.
+func (tw *TokenWriter) SetJSONSeq(enabled bool) {
+	tw.jsonSeq = enabled
+}
+
+// SetKeyPolicy sets the policy for tokens other than strings written in place of object
+// keys, see KeyPolicy
+func (tw *TokenWriter) SetKeyPolicy(p KeyPolicy) {
+	tw.keyPolicy = p
+}
+
+// SetPace limits the rate of writing to at most tokensPerSec tokens (',' and ':' aside)
+// and bytesPerSec bytes per second, non-positive values mean no limit. Write calls block
+// as needed, buffered data is flushed before blocking so that consumers get it steadily.
+// It allows to replay captured streams into downstream systems without overloading them.
+// MUST be called before writing started.
+func (tw *TokenWriter) SetPace(tokensPerSec, bytesPerSec int) {
+	tw.tokenPacer = pacer{rate: float64(tokensPerSec)}
+
+	if bytesPerSec > 0 {
+		tw.w = NewPacedWriter(tw.w, bytesPerSec)
+	}
+}
+
+// Depth returns the number of currently open objects and arrays
+func (tw *TokenWriter) Depth() int {
+	return len(tw.stack)
+}
+
+func (tw *TokenWriter) top() *writerFrame {
+	if len(tw.stack) == 0 {
+		return nil
+	}
+
+	return &tw.stack[len(tw.stack)-1]
+}
+
+// beginValue writes a separator required before a new value (if any)
+func (tw *TokenWriter) beginValue() error {
+	frame := tw.top()
+
+	switch {
+	case frame == nil && tw.jsonSeq:
+		tw.buf = append(tw.buf, recordSeparator)
+	case frame == nil:
+		if tw.topLevelValues > 0 {
+			tw.buf = append(tw.buf, '\n')
+		}
+	case frame.isObject && frame.expectKey:
+		return fmt.Errorf("expected object key")
+	case !frame.isObject && frame.elems > 0:
+		tw.buf = append(tw.buf, ',')
+	}
+
+	return nil
+}
+
+// endValue updates the state of the enclosing container once a value has been written
+func (tw *TokenWriter) endValue() {
+	frame := tw.top()
+
+	if frame == nil {
+		if tw.jsonSeq {
+			tw.buf = append(tw.buf, '\n')
+		}
+
+		tw.topLevelValues++
+		return
+	}
+
+	frame.elems++
+
+	if frame.isObject {
+		frame.expectKey = true
+	}
+}
+
+func (tw *TokenWriter) writeKey(t *TokenGeneric) (err error) {
+	frame := tw.top()
+
+	if t.t != LexerTokenTypeString && tw.keyPolicy == KeyPolicyError {
+		return fmt.Errorf("expected object key, got %s", t.t)
+	}
+
+	if frame.elems > 0 {
+		tw.buf = append(tw.buf, ',')
+	}
+
+	switch {
+	case t.t == LexerTokenTypeString:
+		tw.buf = append(tw.buf, '"')
+		tw.buf = AppendEscapedString(tw.buf, t.str, tw.escapeFlags)
+		tw.buf = append(tw.buf, '"')
+	case tw.keyPolicy == KeyPolicyStringify:
+		if tw.keyBuf, err = appendScalar(tw.keyBuf[:0], t); err != nil {
+			return err
+		}
+
+		tw.buf = append(tw.buf, '"')
+		tw.buf = AppendEscapedString(tw.buf, unsafeStringFromBytes(tw.keyBuf), tw.escapeFlags)
+		tw.buf = append(tw.buf, '"')
+	default:
+		if tw.buf, err = appendScalar(tw.buf, t); err != nil {
+			return err
+		}
+	}
+
+	tw.buf = append(tw.buf, ':')
+	frame.expectKey = false
+
+	return nil
+}
+
+func (tw *TokenWriter) writeDelim(d byte) error {
+	switch d {
+	case ',', ':':
+		return nil
+	case '{', '[':
+		if err := tw.beginValue(); err != nil {
+			return err
+		}
+
+		tw.buf = append(tw.buf, d)
+		tw.stack = append(tw.stack, writerFrame{isObject: d == '{', expectKey: d == '{'})
+	case '}', ']':
+		frame := tw.top()
+		if frame == nil || frame.isObject != (d == '}') {
+			return fmt.Errorf("unexpected delimiter '%c'", d)
+		}
+		if frame.isObject && !frame.expectKey {
+			return fmt.Errorf("missing value for the last key before '%c'", d)
+		}
+
+		tw.buf = append(tw.buf, d)
+		tw.stack = tw.stack[:len(tw.stack)-1]
+		tw.endValue()
+	default:
+		return fmt.Errorf("unknown delimiter '%c'", d)
+	}
+
+	return nil
+}
+
+func appendNumber(dst []byte, f float64) ([]byte, error) {
+	return jsonutil.AppendNumber(dst, f)
+}
+
+// appendScalar appends a scalar token other than a string
+func appendScalar(dst []byte, t *TokenGeneric) (_ []byte, err error) {
+	switch t.t {
+	case LexerTokenTypeNumber:
+		if t.lossy || t.unparsed {
+			return append(dst, t.str...), nil
+		}
+
+		return appendNumber(dst, t.number)
+	case LexerTokenTypeInt:
+		if t.negative {
+			dst = append(dst, '-')
+		}
+
+		return strconv.AppendUint(dst, t.integer, 10), nil
+	case LexerTokenTypeBool:
+		return strconv.AppendBool(dst, t.boolean), nil
+	case LexerTokenTypeNull:
+		return append(dst, "null"...), nil
+	}
+
+	return dst, fmt.Errorf("unexpected %s token", t.t)
+}
+
+func (tw *TokenWriter) writeScalar(t *TokenGeneric) (err error) {
+	if frame := tw.top(); frame != nil && frame.isObject && frame.expectKey {
+		return tw.writeKey(t)
+	}
+
+	if err := tw.beginValue(); err != nil {
+		return err
+	}
+
+	if t.t == LexerTokenTypeString {
+		tw.buf = append(tw.buf, '"')
+		tw.buf = AppendEscapedString(tw.buf, t.str, tw.escapeFlags)
+		tw.buf = append(tw.buf, '"')
+	} else if tw.buf, err = appendScalar(tw.buf, t); err != nil {
+		return err
+	}
+
+	tw.endValue()
+
+	return nil
+}
+
+// WriteToken writes the given token. An error is returned if the token violates the
+// structure of JSON written so far (e.g. a number in place of an object key, unless
+// allowed by SetKeyPolicy).
+func (tw *TokenWriter) WriteToken(t TokenGeneric) error {
+	var err error
+
+	if tw.tokenPacer.rate > 0 && !isSeparator(&t) {
+		if err := tw.pace(); err != nil {
+			return err
+		}
+	}
+
+	if t.t == LexerTokenTypeDelim {
+		err = tw.writeDelim(t.delim)
+	} else {
+		err = tw.writeScalar(&t)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(tw.buf) >= defaultBufSize {
+		return tw.Flush()
+	}
+
+	return nil
+}
+
+// pace blocks until the next token may be written
+func (tw *TokenWriter) pace() error {
+	d := tw.tokenPacer.delay(1)
+	if d < pacingSlack {
+		return nil
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	time.Sleep(d)
+
+	return nil
+}
+
+// WriteTokens writes all the given tokens one by one
+func (tw *TokenWriter) WriteTokens(tokens []TokenGeneric) error {
+	for _, t := range tokens {
+		if err := tw.WriteToken(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush writes all buffered data to the underlying writer
+func (tw *TokenWriter) Flush() error {
+	if len(tw.buf) == 0 {
+		return nil
+	}
+
+	_, err := tw.w.Write(tw.buf)
+	tw.buf = tw.buf[:0]
+
+	return err
+}
@@ -0,0 +1,366 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tokenWriterTestCase struct {
+	input  []TokenGeneric
+	output string
+}
+
+func TestTokenWriter(t *testing.T) {
+	testcases := []tokenWriterTestCase{
+		{
+			[]TokenGeneric{NewTokenGenericFromString("hello\n")},
+			`"hello\n"`,
+		},
+		{
+			[]TokenGeneric{
+				NewTokenGenericFromDelim('{'),
+				NewTokenGenericFromString("a"),
+				NewTokenGenericFromNumber(1),
+				NewTokenGenericFromString("b"),
+				NewTokenGenericFromDelim('['),
+				NewTokenGenericFromBool(true),
+				NewTokenGenericFromNull(),
+				NewTokenGenericFromNumber(-2.5),
+				NewTokenGenericFromDelim(']'),
+				NewTokenGenericFromDelim('}'),
+			},
+			`{"a":1,"b":[true,null,-2.5]}`,
+		},
+		{
+			// separators are ignored
+			[]TokenGeneric{
+				NewTokenGenericFromDelim('['),
+				NewTokenGenericFromNumber(1e21),
+				NewTokenGenericFromDelim(','),
+				NewTokenGenericFromNumber(1e-7),
+				NewTokenGenericFromDelim(','),
+				NewTokenGenericFromDelim('{'),
+				NewTokenGenericFromDelim('}'),
+				NewTokenGenericFromDelim(']'),
+			},
+			`[1e+21,1e-7,{}]`,
+		},
+		{
+			[]TokenGeneric{
+				NewTokenGenericFromDelim('{'),
+				NewTokenGenericFromDelim('}'),
+				NewTokenGenericFromNumber(1),
+			},
+			"{}\n1",
+		},
+		{
+			[]TokenGeneric{
+				NewTokenGenericFromDelim('['),
+				NewTokenGenericFromInt64(math.MinInt64),
+				NewTokenGenericFromUint64(math.MaxUint64),
+				NewTokenGenericFromInt64(0),
+				NewTokenGenericFromDelim(']'),
+			},
+			`[-9223372036854775808,18446744073709551615,0]`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+		w := NewTokenWriter(out)
+
+		if err := w.WriteTokens(testcase.input); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.output, err)
+			continue
+		}
+
+		if err := w.Flush(); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.output, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s'", testcase.output, out.String())
+		}
+	}
+}
+
+func TestTokenWriterFails(t *testing.T) {
+	testcases := [][]TokenGeneric{
+		{NewTokenGenericFromDelim('}')},
+		{NewTokenGenericFromDelim('['), NewTokenGenericFromDelim('}')},
+		{NewTokenGenericFromDelim('{'), NewTokenGenericFromNumber(1)},
+		{NewTokenGenericFromDelim('{'), NewTokenGenericFromString("a"), NewTokenGenericFromDelim('}')},
+		{NewTokenGenericFromNumber(math.NaN())},
+		{NewTokenGenericFromDelim('x')},
+	}
+
+	for i, testcase := range testcases {
+		w := NewTokenWriter(&bytes.Buffer{})
+
+		if err := w.WriteTokens(testcase); err == nil {
+			t.Errorf("testcase %d: must have failed", i)
+		}
+	}
+}
+
+// readAllTokens lexes the whole input including delimiters making deep copies of strings
+func readAllTokens(input string) ([]TokenGeneric, error) {
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+
+	l.skipDelims = false
+	l.SetBufSize(16)
+
+	var tokens []TokenGeneric
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		token.str = token.StringCopy()
+		tokens = append(tokens, token)
+	}
+}
+
+func TestTokenWriterRoundTrip(t *testing.T) {
+	tokens, err := readAllTokens(jsonSample)
+	if err != nil {
+		t.Fatalf("could not lex sample: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	w := NewTokenWriter(out)
+
+	if err := w.WriteTokens(tokens); err != nil {
+		t.Fatalf("could not write tokens: %v", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	outTokens, err := readAllTokens(out.String())
+	if err != nil {
+		t.Fatalf("could not lex output '%s': %v", out.String(), err)
+	}
+
+	if len(tokens) != len(outTokens) {
+		t.Fatalf("token streams differ, output '%s'", out.String())
+	}
+
+	// the textual form of numbers may change, e.g. 1.570e+10 is written as 15700000000
+	for i := range tokens {
+		if !tokensEqual(&tokens[i], &outTokens[i]) {
+			t.Errorf("token %d differs, output '%s'", i, out.String())
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	testcases := []string{
+		jsonSample,
+		``,
+		`null`,
+		`{"a": [1, -0.5, 1e300, true, false, null, {}, []]}`,
+		`"Пр💩 \"\\\/\b\f\n\r\t \u0000"`,
+		`{"a": 1} [2] "3"`,
+		`[[[[[["` + strings.Repeat("long string ", 1024) + `"]]]]]]`,
+	}
+
+	for _, testcase := range testcases {
+		if err := RoundTrip(strings.NewReader(testcase)); err != nil {
+			t.Errorf("testcase '%s': %v", testcase, err)
+		}
+	}
+}
+
+func TestRoundTripFails(t *testing.T) {
+	testcases := []string{
+		`[tru]`,
+		`[1}`,
+		`{"a": 1]`,
+		`"\q"`,
+	}
+
+	for _, testcase := range testcases {
+		if err := RoundTrip(strings.NewReader(testcase)); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase)
+		}
+	}
+}
+
+func TestCompareTokensFails(t *testing.T) {
+	testcases := []struct {
+		output   string
+		expected []TokenGeneric
+	}{
+		{`[1]`, []TokenGeneric{NewTokenGenericFromDelim('['), NewTokenGenericFromNumber(2)}},
+		{`[1]`, []TokenGeneric{NewTokenGenericFromDelim('[')}},
+		{`[`, []TokenGeneric{NewTokenGenericFromDelim('['), NewTokenGenericFromDelim(']')}},
+		{`"a"`, []TokenGeneric{NewTokenGenericFromString("b")}},
+	}
+
+	for _, testcase := range testcases {
+		q := &tokenQueue{}
+		for _, token := range testcase.expected {
+			q.push(token)
+		}
+
+		if err := compareTokens(strings.NewReader(testcase.output), q); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase.output)
+		}
+	}
+}
+
+func TestTokenWriterRawNumbers(t *testing.T) {
+	input := `[1.570e+10,-0,1E400,0.10]`
+
+	l, err := NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	l.SetSkipDelims(false)
+	l.SetRawNumbers(true)
+
+	out := &bytes.Buffer{}
+	w := NewTokenWriter(out)
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if err := w.WriteToken(token); err != nil {
+			t.Fatalf("could not write token: %v", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	if out.String() != input {
+		t.Errorf("got '%s', expected '%s'", out.String(), input)
+	}
+}
+
+func TestTokenWriterKeyPolicy(t *testing.T) {
+	tokens := []TokenGeneric{
+		NewTokenGenericFromDelim('{'),
+		NewTokenGenericFromNumber(1.5), NewTokenGenericFromBool(true),
+		NewTokenGenericFromInt64(-2), NewTokenGenericFromNull(),
+		NewTokenGenericFromNull(), NewTokenGenericFromString("x"),
+		NewTokenGenericFromString("a"), NewTokenGenericFromNumber(1),
+		NewTokenGenericFromDelim('}'),
+	}
+
+	testcases := []struct {
+		policy   KeyPolicy
+		expected string
+	}{
+		{KeyPolicyStringify, `{"1.5":true,"-2":null,"null":"x","a":1}`},
+		{KeyPolicyPassThrough, `{1.5:true,-2:null,null:"x","a":1}`},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+		w := NewTokenWriter(out)
+		w.SetKeyPolicy(testcase.policy)
+
+		if err := w.WriteTokens(tokens); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.expected, err)
+			continue
+		}
+
+		w.Flush()
+
+		if out.String() != testcase.expected {
+			t.Errorf("testcase '%s': got '%s'", testcase.expected, out.String())
+		}
+	}
+
+	w := NewTokenWriter(&bytes.Buffer{})
+	if err := w.WriteTokens(tokens); err == nil {
+		t.Errorf("KeyPolicyError must be the default")
+	}
+
+	w = NewTokenWriter(&bytes.Buffer{})
+	w.SetKeyPolicy(KeyPolicyStringify)
+
+	err := w.WriteTokens([]TokenGeneric{NewTokenGenericFromDelim('{'), NewTokenGenericFromNumber(math.Inf(1))})
+	if err == nil {
+		t.Errorf("infinite key must have failed")
+	}
+}
+
+func TestTokenWriterPace(t *testing.T) {
+	tokens := []TokenGeneric{NewTokenGenericFromDelim('[')}
+	for i := 0; i < 28; i++ {
+		tokens = append(tokens, NewTokenGenericFromNumber(float64(i)), NewTokenGenericFromDelim(','))
+	}
+	tokens = append(tokens, NewTokenGenericFromDelim(']'))
+
+	out := &bytes.Buffer{}
+	w := NewTokenWriter(out)
+	w.SetPace(300, 0)
+
+	start := time.Now()
+
+	if err := w.WriteTokens(tokens); err != nil {
+		t.Fatalf("could not write tokens: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	// 30 tokens (separators aside), the last one is due in 29/300 s
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("30 tokens have been written in %v at 300 tokens/s", elapsed)
+	}
+
+	if !strings.HasPrefix(out.String(), "[0,1,2,") || !strings.HasSuffix(out.String(), ",27]") {
+		t.Errorf("got '%s'", out.String())
+	}
+}
+
+func TestPacedWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewPacedWriter(out, 5000)
+
+	input := bytes.Repeat([]byte("x"), 1000)
+	start := time.Now()
+
+	if n, err := w.Write(input); n != len(input) || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+
+	// 2 chunks of 500 bytes, the second one is due in 100 ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("1000 bytes have been written in %v at 5000 bytes/s", elapsed)
+	}
+
+	if !bytes.Equal(out.Bytes(), input) {
+		t.Errorf("got %d bytes, expected %d", out.Len(), len(input))
+	}
+
+	if n, err := NewPacedWriter(out, 0).Write(input); n != len(input) || err != nil {
+		t.Errorf("got %d, %v without a limit", n, err)
+	}
+}
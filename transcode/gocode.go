@@ -0,0 +1,241 @@
+package transcode
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type goCodeFrame struct {
+	isObject bool
+	empty    bool                // reports whether no members (or elements) have been written yet
+	keys     map[string]struct{} // keys of the object, duplicates do not compile
+}
+
+type goCodeGenerator struct {
+	*transcoder
+
+	out    io.Writer
+	buf    []byte
+	frames []goCodeFrame
+}
+
+func (g *goCodeGenerator) appendIndent() {
+	for i := 0; i < len(g.frames); i++ {
+		g.buf = append(g.buf, '\t')
+	}
+}
+
+// beginChild starts a new line for a member (or an element) of the innermost container
+func (g *goCodeGenerator) beginChild() {
+	frame := &g.frames[len(g.frames)-1]
+
+	if frame.empty {
+		g.buf = append(g.buf, '\n')
+		frame.empty = false
+	}
+
+	g.appendIndent()
+}
+
+// endValue finishes the line of a value nested into a container
+func (g *goCodeGenerator) endValue() {
+	if len(g.frames) > 0 {
+		g.buf = append(g.buf, ',', '\n')
+	}
+}
+
+func (g *goCodeGenerator) appendScalar(token *gojsonlex.TokenGeneric) {
+	switch token.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		g.buf = strconv.AppendQuote(g.buf, token.String())
+	case gojsonlex.LexerTokenTypeNumber:
+		// untyped constants would become ints in interface{}
+		g.buf = append(g.buf, "float64("...)
+		if token.IsLossyNumber() {
+			g.buf = append(g.buf, token.String()...)
+		} else {
+			g.buf = strconv.AppendFloat(g.buf, token.Number(), 'g', -1, 64)
+		}
+		g.buf = append(g.buf, ')')
+	case gojsonlex.LexerTokenTypeBool:
+		g.buf = strconv.AppendBool(g.buf, token.Bool())
+	case gojsonlex.LexerTokenTypeNull:
+		g.buf = append(g.buf, "nil"...)
+	}
+}
+
+func (g *goCodeGenerator) processToken(token *gojsonlex.TokenGeneric, role gojsonlex.TokenRole) error {
+	isElement := len(g.frames) > 0 && !g.frames[len(g.frames)-1].isObject
+
+	switch role {
+	case gojsonlex.TokenRoleKey:
+		frame := &g.frames[len(g.frames)-1]
+		if _, ok := frame.keys[token.String()]; ok {
+			return fmt.Errorf("duplicate key '%s'", gojsonlex.StringDeepCopy(token.String()))
+		}
+
+		frame.keys[token.StringCopy()] = struct{}{}
+
+		g.beginChild()
+		g.buf = strconv.AppendQuote(g.buf, token.String())
+		g.buf = append(g.buf, ':', ' ')
+	case gojsonlex.TokenRoleScalar:
+		if isElement {
+			g.beginChild()
+		}
+
+		g.appendScalar(token)
+		g.endValue()
+	case gojsonlex.TokenRoleOpen:
+		if isElement {
+			g.beginChild()
+		}
+
+		frame := goCodeFrame{isObject: token.Delim() == '{', empty: true}
+
+		if frame.isObject {
+			frame.keys = make(map[string]struct{})
+			g.buf = append(g.buf, "map[string]interface{}{"...)
+		} else {
+			g.buf = append(g.buf, "[]interface{}{"...)
+		}
+
+		g.frames = append(g.frames, frame)
+	case gojsonlex.TokenRoleClose:
+		frame := g.frames[len(g.frames)-1]
+		g.frames = g.frames[:len(g.frames)-1]
+
+		if !frame.empty {
+			g.appendIndent()
+		}
+
+		g.buf = append(g.buf, '}')
+		g.endValue()
+	}
+
+	return nil
+}
+
+// writeGoExpr formats the given Go expression with gofmt (which e.g. aligns values of
+// map literals) and writes it to dst
+func writeGoExpr(dst io.Writer, expr []byte) error {
+	const prefix = "package p\n\nvar v = "
+
+	src := make([]byte, 0, len(prefix)+len(expr))
+	src = append(src, prefix...)
+	src = append(src, expr...)
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("could not format generated code: %w", err)
+	}
+
+	formatted = formatted[bytes.Index(formatted, []byte("v = "))+len("v = "):]
+
+	_, err = dst.Write(formatted)
+
+	return err
+}
+
+// GenerateGoValue reads a single JSON value from src and writes to dst a gofmt-formatted
+// Go expression of type interface{} holding the same value the way encoding/json would
+// unmarshal it: map[string]interface{} for objects, []interface{} for arrays, float64
+// for numbers, string, bool and nil. It lets static JSON (e.g. configuration) be embedded
+// into a program at compile time. Objects with duplicate keys cause an error since such
+// map literals do not compile.
+func GenerateGoValue(dst io.Writer, src io.Reader) error {
+	t, err := newTranscoder(src)
+	if err != nil {
+		return err
+	}
+
+	g := &goCodeGenerator{transcoder: t, out: dst}
+
+	for values := 0; ; {
+		token, role, err := g.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == gojsonlex.TokenRoleSeparator {
+			continue
+		}
+
+		if role.StartsValue() && len(g.frames) == 0 {
+			if values++; values > 1 {
+				return fmt.Errorf("more than one top-level value")
+			}
+		}
+
+		if err := g.processToken(&token, role); err != nil {
+			return err
+		}
+	}
+
+	if len(g.buf) == 0 {
+		return fmt.Errorf("no value found")
+	}
+
+	return writeGoExpr(g.out, g.buf)
+}
+
+// GenerateGoTokens reads JSON from src and writes to dst a gofmt-formatted Go expression of
+// type []gojsonlex.TokenGeneric holding all tokens of the input (including delimiters),
+// e.g. to replay them through TokenWriter or a TokenSource consumer without parsing.
+func GenerateGoTokens(dst io.Writer, src io.Reader) error {
+	t, err := newTranscoder(src)
+	if err != nil {
+		return err
+	}
+
+	buf := append([]byte(nil), "[]gojsonlex.TokenGeneric{"...)
+	empty := true
+
+	for {
+		token, _, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if empty {
+			buf = append(buf, '\n')
+			empty = false
+		}
+
+		buf = append(buf, '\t')
+
+		switch token.Type() {
+		case gojsonlex.LexerTokenTypeDelim:
+			buf = append(buf, "gojsonlex.NewTokenGenericFromDelim("...)
+			buf = strconv.AppendQuoteRune(buf, rune(token.Delim()))
+		case gojsonlex.LexerTokenTypeString:
+			buf = append(buf, "gojsonlex.NewTokenGenericFromString("...)
+			buf = strconv.AppendQuote(buf, token.String())
+		case gojsonlex.LexerTokenTypeNumber:
+			buf = append(buf, "gojsonlex.NewTokenGenericFromNumber("...)
+			buf = strconv.AppendFloat(buf, token.Number(), 'g', -1, 64)
+		case gojsonlex.LexerTokenTypeBool:
+			buf = append(buf, "gojsonlex.NewTokenGenericFromBool("...)
+			buf = strconv.AppendBool(buf, token.Bool())
+		case gojsonlex.LexerTokenTypeNull:
+			buf = append(buf, "gojsonlex.NewTokenGenericFromNull("...)
+		}
+
+		buf = append(buf, ')', ',', '\n')
+	}
+
+	buf = append(buf, '}')
+
+	return writeGoExpr(dst, buf)
+}
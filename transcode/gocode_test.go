@@ -0,0 +1,113 @@
+package transcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type generateGoTestCase struct {
+	input  string
+	output string
+}
+
+func TestGenerateGoValue(t *testing.T) {
+	testcases := []generateGoTestCase{
+		{`42`, "float64(42)\n"},
+		{`"a\"b\n"`, "\"a\\\"b\\n\"\n"},
+		{`{}`, "map[string]interface{}{}\n"},
+		{
+			`{"name": "Bob", "score": 1.5e21, "admin": true, "tags": ["a", null], "meta": {}, "list": []}`,
+			"map[string]interface{}{\n" +
+				"\t\"name\":  \"Bob\",\n" +
+				"\t\"score\": float64(1.5e+21),\n" +
+				"\t\"admin\": true,\n" +
+				"\t\"tags\": []interface{}{\n" +
+				"\t\t\"a\",\n" +
+				"\t\tnil,\n" +
+				"\t},\n" +
+				"\t\"meta\": map[string]interface{}{},\n" +
+				"\t\"list\": []interface{}{},\n" +
+				"}\n",
+		},
+		{
+			`[[1], {"x": false}]`,
+			"[]interface{}{\n" +
+				"\t[]interface{}{\n" +
+				"\t\tfloat64(1),\n" +
+				"\t},\n" +
+				"\tmap[string]interface{}{\n" +
+				"\t\t\"x\": false,\n" +
+				"\t},\n" +
+				"}\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		if err := GenerateGoValue(out, strings.NewReader(testcase.input)); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+func TestGenerateGoValueFails(t *testing.T) {
+	testcases := []string{
+		``,
+		`1 2`,
+		`{"a": 1, "a": 2}`,
+		`[1, 2`,
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		if err := GenerateGoValue(out, strings.NewReader(testcase)); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase)
+		}
+	}
+}
+
+func TestGenerateGoTokens(t *testing.T) {
+	testcases := []generateGoTestCase{
+		{``, "[]gojsonlex.TokenGeneric{}\n"},
+		{
+			`{"a": [1, true, null]} "x"`,
+			"[]gojsonlex.TokenGeneric{\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim('{'),\n" +
+				"\tgojsonlex.NewTokenGenericFromString(\"a\"),\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim(':'),\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim('['),\n" +
+				"\tgojsonlex.NewTokenGenericFromNumber(1),\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim(','),\n" +
+				"\tgojsonlex.NewTokenGenericFromBool(true),\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim(','),\n" +
+				"\tgojsonlex.NewTokenGenericFromNull(),\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim(']'),\n" +
+				"\tgojsonlex.NewTokenGenericFromDelim('}'),\n" +
+				"\tgojsonlex.NewTokenGenericFromString(\"x\"),\n" +
+				"}\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		if err := GenerateGoTokens(out, strings.NewReader(testcase.input)); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
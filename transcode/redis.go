@@ -0,0 +1,85 @@
+package transcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+func appendRESPBulkString(dst []byte, s string) []byte {
+	dst = append(dst, '$')
+	dst = strconv.AppendInt(dst, int64(len(s)), 10)
+	dst = append(dst, '\r', '\n')
+	dst = append(dst, s...)
+
+	return append(dst, '\r', '\n')
+}
+
+// ToRedis reads top-level objects (e.g. NDJSON records) from src and writes to dst an HSET
+// command per object in the Redis protocol (RESP), ready for redis-cli --pipe. Objects are
+// flattened the way gojsonlex.FlattenObjects does it, the key of the hash is keyPrefix
+// followed by the scalar at path id inside the object. Objects without such a scalar
+// cause an error.
+func ToRedis(dst io.Writer, src io.Reader, keyPrefix string, id gojsonlex.Path) error {
+	f, err := gojsonlex.NewFlattener(src, id)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(dst)
+
+	var buf, numBuf []byte
+
+	for {
+		if err := f.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		idToken, ok := f.Field(0)
+		if !ok || idToken.IsNull() {
+			return fmt.Errorf("record has no scalar at path '%s'", id)
+		}
+
+		key := keyPrefix
+		switch idToken.Type() {
+		case gojsonlex.LexerTokenTypeString:
+			key += idToken.String()
+		case gojsonlex.LexerTokenTypeBool:
+			key += strconv.FormatBool(idToken.Bool())
+		case gojsonlex.LexerTokenTypeNumber:
+			if idToken.IsLossyNumber() {
+				key += idToken.String()
+				break
+			}
+
+			if numBuf, err = jsonutil.AppendNumber(numBuf[:0], idToken.Number()); err != nil {
+				return err
+			}
+
+			key += string(numBuf)
+		}
+
+		fields := f.Fields()
+
+		buf = append(buf[:0], '*')
+		buf = strconv.AppendInt(buf, int64(2+2*len(fields)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = appendRESPBulkString(buf, "HSET")
+		buf = appendRESPBulkString(buf, key)
+
+		for _, field := range fields {
+			buf = appendRESPBulkString(buf, field.Key)
+			buf = appendRESPBulkString(buf, field.Value)
+		}
+
+		w.Write(buf)
+	}
+
+	return w.Flush()
+}
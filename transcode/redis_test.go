@@ -0,0 +1,33 @@
+package transcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+func TestToRedis(t *testing.T) {
+	input := `{"id": 7, "name": "Bob"}
+{"id": "x", "empty": {}}
+{"id": "é", "n": null, "ok": true}
+`
+
+	expected := "*6\r\n$4\r\nHSET\r\n$6\r\nuser:7\r\n$2\r\nid\r\n$1\r\n7\r\n$4\r\nname\r\n$3\r\nBob\r\n" +
+		"*4\r\n$4\r\nHSET\r\n$6\r\nuser:x\r\n$2\r\nid\r\n$1\r\nx\r\n" +
+		"*6\r\n$4\r\nHSET\r\n$7\r\nuser:é\r\n$2\r\nid\r\n$2\r\né\r\n$2\r\nok\r\n$4\r\ntrue\r\n"
+
+	var out bytes.Buffer
+	if err := ToRedis(&out, strings.NewReader(input), "user:", gojsonlex.ParsePath("id")); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if out.String() != expected {
+		t.Errorf("got %q, expected %q", out.String(), expected)
+	}
+
+	if err := ToRedis(&out, strings.NewReader(`{"name": "Bob"}`), "user:", gojsonlex.ParsePath("id")); err == nil {
+		t.Errorf("record without id must have failed")
+	}
+}
@@ -0,0 +1,272 @@
+package transcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// SQLPlaceholder is the style of parameter placeholders in generated SQL statements
+type SQLPlaceholder byte
+
+const (
+	// SQLPlaceholderQuestion is "?" (MySQL, SQLite)
+	SQLPlaceholderQuestion SQLPlaceholder = iota
+	// SQLPlaceholderDollar is "$1", "$2" etc (PostgreSQL)
+	SQLPlaceholderDollar
+)
+
+const defaultSQLBatchSize = 100
+
+// SQLColumn maps the scalar value at Path inside a record to a column
+type SQLColumn struct {
+	Name string
+	Path gojsonlex.Path
+}
+
+// SQLOptions configure the statements generated by GenerateSQLInserts
+type SQLOptions struct {
+	Table   string
+	Columns []SQLColumn
+	// BatchSize is the maximum number of rows inserted by one statement, 100 if 0
+	BatchSize   int
+	Placeholder SQLPlaceholder
+}
+
+// appendSQLIdentifier appends the identifier quoted with double quotes as the SQL
+// standard requires
+func appendSQLIdentifier(dst []byte, name string) []byte {
+	dst = append(dst, '"')
+	dst = append(dst, strings.Replace(name, `"`, `""`, -1)...)
+
+	return append(dst, '"')
+}
+
+// buildSQLInsert builds an INSERT statement for the given number of rows
+func buildSQLInsert(opts *SQLOptions, rows int) string {
+	buf := append([]byte(nil), "INSERT INTO "...)
+	buf = appendSQLIdentifier(buf, opts.Table)
+	buf = append(buf, " ("...)
+
+	for i, column := range opts.Columns {
+		if i > 0 {
+			buf = append(buf, ", "...)
+		}
+
+		buf = appendSQLIdentifier(buf, column.Name)
+	}
+
+	buf = append(buf, ") VALUES "...)
+
+	param := 0
+
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			buf = append(buf, ", "...)
+		}
+
+		buf = append(buf, '(')
+
+		for i := range opts.Columns {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+
+			param++
+
+			if opts.Placeholder == SQLPlaceholderDollar {
+				buf = append(buf, '$')
+				buf = strconv.AppendInt(buf, int64(param), 10)
+			} else {
+				buf = append(buf, '?')
+			}
+		}
+
+		buf = append(buf, ')')
+	}
+
+	return string(buf)
+}
+
+// sqlArg converts a field value into a statement argument
+func sqlArg(found bool, t *gojsonlex.TokenGeneric) interface{} {
+	if !found {
+		return nil
+	}
+
+	switch t.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		return gojsonlex.StringDeepCopy(t.String())
+	case gojsonlex.LexerTokenTypeNumber:
+		if t.IsLossyNumber() {
+			// keeping the literal lets the database parse it with its own precision
+			return gojsonlex.StringDeepCopy(t.String())
+		}
+
+		return t.Number()
+	case gojsonlex.LexerTokenTypeBool:
+		return t.Bool()
+	}
+
+	return nil
+}
+
+func newSQLRecordScanner(src io.Reader, columns []SQLColumn) (*gojsonlex.RecordScanner, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+
+	fields := make([]gojsonlex.Path, 0, len(columns))
+	for _, column := range columns {
+		fields = append(fields, column.Path)
+	}
+
+	s, err := gojsonlex.NewRecordScanner(src, gojsonlex.Path{}, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.SetPrecisionLossPolicy(gojsonlex.PrecisionLossRaw)
+
+	return s, nil
+}
+
+// GenerateSQLInserts reads top-level values (e.g. NDJSON records) from src and calls fn
+// with parameterized INSERT statements adding a row per record to opts.Table, each
+// statement inserts up to opts.BatchSize rows. Column values are the scalars at the
+// column paths inside records: strings, float64 numbers (numbers that can not be
+// represented as float64 are passed as strings), bools and nil for nulls, missing and
+// non-scalar values. args are valid until fn returns. Parsing stops at the first error
+// returned by fn, which is returned as is.
+func GenerateSQLInserts(src io.Reader, opts SQLOptions, fn func(query string, args []interface{}) error) error {
+	if opts.BatchSize < 0 {
+		return fmt.Errorf("invalid batch size %d", opts.BatchSize)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultSQLBatchSize
+	}
+
+	s, err := newSQLRecordScanner(src, opts.Columns)
+	if err != nil {
+		return err
+	}
+
+	// all batches but the last one are full
+	fullBatchQuery := buildSQLInsert(&opts, opts.BatchSize)
+
+	args := make([]interface{}, 0, opts.BatchSize*len(opts.Columns))
+	rows := 0
+
+	for {
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		for i := range opts.Columns {
+			value, found := s.Field(i)
+			args = append(args, sqlArg(found, &value))
+		}
+
+		if rows++; rows == opts.BatchSize {
+			if err := fn(fullBatchQuery, args); err != nil {
+				return err
+			}
+
+			args, rows = args[:0], 0
+		}
+	}
+
+	if rows == 0 {
+		return nil
+	}
+
+	return fn(buildSQLInsert(&opts, rows), args)
+}
+
+// appendCSVField appends the field value in the CSV format understood by
+// COPY ... WITH (FORMAT csv): strings are always quoted, so that empty strings differ
+// from NULLs, which are written as empty fields
+func appendCSVField(dst []byte, found bool, t *gojsonlex.TokenGeneric) ([]byte, error) {
+	if !found {
+		return dst, nil
+	}
+
+	switch t.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		dst = append(dst, '"')
+		dst = append(dst, strings.Replace(t.String(), `"`, `""`, -1)...)
+		dst = append(dst, '"')
+	case gojsonlex.LexerTokenTypeNumber:
+		if t.IsLossyNumber() {
+			return append(dst, t.String()...), nil
+		}
+
+		return jsonutil.AppendNumber(dst, t.Number())
+	case gojsonlex.LexerTokenTypeBool:
+		dst = strconv.AppendBool(dst, t.Bool())
+	}
+
+	return dst, nil
+}
+
+// ToCSV reads top-level values (e.g. NDJSON records) from src and writes to dst
+// a CSV row per record with the scalars at the column paths inside records, preceded by
+// a header row with the column names. The output is meant to be loaded with
+// COPY ... WITH (FORMAT csv, HEADER): nulls, missing and non-scalar values are written as
+// empty fields, strings are always quoted.
+func ToCSV(dst io.Writer, src io.Reader, columns []SQLColumn) error {
+	s, err := newSQLRecordScanner(src, columns)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(dst)
+
+	var buf []byte
+
+	for i, column := range columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+
+		buf = append(buf, '"')
+		buf = append(buf, strings.Replace(column.Name, `"`, `""`, -1)...)
+		buf = append(buf, '"')
+	}
+
+	buf = append(buf, '\n')
+	w.Write(buf)
+
+	for {
+		if err := s.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		buf = buf[:0]
+
+		for i := range columns {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+
+			value, found := s.Field(i)
+			if buf, err = appendCSVField(buf, found, &value); err != nil {
+				return err
+			}
+		}
+
+		buf = append(buf, '\n')
+		w.Write(buf)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,112 @@
+package transcode
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type sqlStatement struct {
+	query string
+	args  []interface{}
+}
+
+func TestGenerateSQLInserts(t *testing.T) {
+	input := `{"id": 1, "user": {"name": "Bob"}, "admin": true}
+{"id": 2, "user": {"name": "O'Brien"}, "admin": null}
+{"id": 3, "user": "unknown"}
+`
+
+	columns := []SQLColumn{
+		{"id", gojsonlex.ParsePath("id")},
+		{"name", gojsonlex.ParsePath("user.name")},
+		{"is admin", gojsonlex.ParsePath("admin")},
+	}
+
+	var statements []sqlStatement
+
+	opts := SQLOptions{Table: "users", Columns: columns, BatchSize: 2, Placeholder: SQLPlaceholderDollar}
+	err := GenerateSQLInserts(strings.NewReader(input), opts, func(query string, args []interface{}) error {
+		statements = append(statements, sqlStatement{query, append([]interface{}(nil), args...)})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []sqlStatement{
+		{
+			`INSERT INTO "users" ("id", "name", "is admin") VALUES ($1, $2, $3), ($4, $5, $6)`,
+			[]interface{}{1.0, "Bob", true, 2.0, "O'Brien", nil},
+		},
+		{
+			`INSERT INTO "users" ("id", "name", "is admin") VALUES ($1, $2, $3)`,
+			[]interface{}{3.0, nil, nil},
+		},
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("got %v, expected %v", statements, expected)
+	}
+
+	statements = statements[:0]
+
+	opts = SQLOptions{Table: "t", Columns: columns[:1]}
+	err = GenerateSQLInserts(strings.NewReader(`{"id": 12345678901234567890123} {"id": "x"}`), opts,
+		func(query string, args []interface{}) error {
+			statements = append(statements, sqlStatement{query, append([]interface{}(nil), args...)})
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected = []sqlStatement{
+		{`INSERT INTO "t" ("id") VALUES (?), (?)`, []interface{}{"12345678901234567890123", "x"}},
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("got %v, expected %v", statements, expected)
+	}
+
+	errStop := errors.New("stop")
+	opts.BatchSize = 1
+	err = GenerateSQLInserts(strings.NewReader(`{"id": 1} {"id": 2}`), opts, func(string, []interface{}) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("got %v, expected %v", err, errStop)
+	}
+}
+
+func TestToCSV(t *testing.T) {
+	input := `{"id": 1, "tags": ["a"], "note": "say \"hi\", bye"}
+{"id": 2.5, "note": ""}
+{"id": null, "tags": ["b"], "note": null}
+`
+
+	columns := []SQLColumn{
+		{"id", gojsonlex.ParsePath("id")},
+		{"first tag", gojsonlex.ParsePath("tags.0")},
+		{"note", gojsonlex.ParsePath("note")},
+	}
+
+	expected := `"id","first tag","note"
+1,"a","say ""hi"", bye"
+2.5,,""
+,"b",
+`
+
+	var out bytes.Buffer
+	if err := ToCSV(&out, strings.NewReader(input), columns); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if out.String() != expected {
+		t.Errorf("got %q, expected %q", out.String(), expected)
+	}
+}
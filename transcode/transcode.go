@@ -0,0 +1,40 @@
+// Package transcode converts JSON to other formats (XML, YAML, CSV, SQL, Redis commands
+// and Go code) token by token, so that documents are never held in memory as a whole.
+// Unlike the transforms, transcoders normalize numbers.
+package transcode
+
+import (
+	"io"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+// transcoder is a common base for the converters that follow the structure of the input
+type transcoder struct {
+	l  *gojsonlex.JSONLexer
+	tr gojsonlex.PathTracker
+}
+
+func newTranscoder(src io.Reader) (*transcoder, error) {
+	l, err := gojsonlex.NewJSONLexer(src)
+	if err != nil {
+		return nil, err
+	}
+
+	l.SetSkipDelims(false)
+
+	return &transcoder{l: l}, nil
+}
+
+// next returns the next token from the input along with its role, io.EOF is returned
+// once the input has been exhausted
+func (t *transcoder) next() (gojsonlex.TokenGeneric, gojsonlex.TokenRole, error) {
+	token, err := t.l.TokenFast()
+	if err != nil {
+		return token, 0, err
+	}
+
+	role, err := t.tr.Feed(&token)
+
+	return token, role, err
+}
@@ -0,0 +1,284 @@
+package transcode
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// XMLOptions configure the mapping of JSON to XML done by ToXML
+type XMLOptions struct {
+	// RootElement is the name of the element every top-level value is written as,
+	// "root" if empty
+	RootElement string
+	// ItemElement is the name of the elements elements of arrays are written as,
+	// "item" if empty
+	ItemElement string
+	// AttributePrefix makes members with scalar values whose keys start with it
+	// written as attributes (with the prefix trimmed) of the element of the enclosing
+	// object, e.g. "@". Such members must precede all other members of the object.
+	// All members are written as elements if empty.
+	AttributePrefix string
+	// TextKey makes the scalar value of the member with this key written as the text
+	// content of the element of the enclosing object, e.g. "#text"
+	TextKey string
+}
+
+type xmlFrame struct {
+	name        []byte
+	startTagEnd bool // reports whether '>' of the start tag has been written
+}
+
+type xmlTranscoder struct {
+	*transcoder
+
+	opts XMLOptions
+	out  *bufio.Writer
+
+	frames []xmlFrame
+	depth  int
+
+	numBuf []byte
+}
+
+func isValidXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == utf8.RuneError:
+			return false
+		case unicode.IsLetter(r), r == '_', r == ':':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (x *xmlTranscoder) top() *xmlFrame {
+	if x.depth == 0 {
+		return nil
+	}
+
+	return &x.frames[x.depth-1]
+}
+
+// endStartTag writes '>' finishing the start tag of the innermost element
+func (x *xmlTranscoder) endStartTag() {
+	if frame := x.top(); frame != nil && !frame.startTagEnd {
+		x.out.WriteByte('>')
+		frame.startTagEnd = true
+	}
+}
+
+// valueName returns the name of the element of the value that has just been started
+func (x *xmlTranscoder) valueName() (string, error) {
+	if x.tr.ValueDepth() == 0 {
+		return x.opts.RootElement, nil
+	}
+
+	name, ok := x.tr.Key()
+	if !ok {
+		return x.opts.ItemElement, nil
+	}
+
+	if !isValidXMLName(name) {
+		return "", fmt.Errorf("key '%s' is not a valid XML name", gojsonlex.StringDeepCopy(name))
+	}
+
+	return name, nil
+}
+
+func (x *xmlTranscoder) writeText(token *gojsonlex.TokenGeneric) error {
+	var err error
+
+	switch token.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		err = xml.EscapeText(x.out, token.BytesUnsafe())
+	case gojsonlex.LexerTokenTypeNumber:
+		if token.IsLossyNumber() {
+			x.out.WriteString(token.String())
+			break
+		}
+
+		x.numBuf, err = jsonutil.AppendNumber(x.numBuf[:0], token.Number())
+		x.out.Write(x.numBuf)
+	case gojsonlex.LexerTokenTypeBool:
+		x.out.WriteString(strconv.FormatBool(token.Bool()))
+	}
+
+	return err
+}
+
+// memberKind returns the kind of the member the last started value belongs to: its key
+// with the attribute prefix trimmed for attributes
+func (x *xmlTranscoder) memberKind() (attr string, isAttr, isText bool) {
+	key, ok := x.tr.Key()
+	if !ok {
+		return "", false, false
+	}
+
+	if x.opts.TextKey != "" && key == x.opts.TextKey {
+		return "", false, true
+	}
+
+	prefix := x.opts.AttributePrefix
+	if prefix != "" && len(key) > len(prefix) && key[:len(prefix)] == prefix {
+		return key[len(prefix):], true, false
+	}
+
+	return "", false, false
+}
+
+func (x *xmlTranscoder) writeScalar(token *gojsonlex.TokenGeneric) error {
+	attr, isAttr, isText := x.memberKind()
+
+	switch {
+	case isAttr:
+		if x.top().startTagEnd {
+			return fmt.Errorf("attribute '%s' follows child elements", gojsonlex.StringDeepCopy(attr))
+		}
+		if !isValidXMLName(attr) {
+			return fmt.Errorf("'%s' is not a valid XML attribute name", gojsonlex.StringDeepCopy(attr))
+		}
+
+		x.out.WriteByte(' ')
+		x.out.WriteString(attr)
+		x.out.WriteString(`="`)
+		if err := x.writeText(token); err != nil {
+			return err
+		}
+		x.out.WriteByte('"')
+
+		return nil
+	case isText:
+		x.endStartTag()
+		return x.writeText(token)
+	}
+
+	name, err := x.valueName()
+	if err != nil {
+		return err
+	}
+
+	x.endStartTag()
+
+	if token.Type() == gojsonlex.LexerTokenTypeNull {
+		fmt.Fprintf(x.out, "<%s/>", name)
+		return nil
+	}
+
+	fmt.Fprintf(x.out, "<%s>", name)
+	if err := x.writeText(token); err != nil {
+		return err
+	}
+	fmt.Fprintf(x.out, "</%s>", name)
+
+	return nil
+}
+
+func (x *xmlTranscoder) open() error {
+	name, err := x.valueName()
+	if err != nil {
+		return err
+	}
+
+	x.endStartTag()
+
+	if x.depth == len(x.frames) {
+		x.frames = append(x.frames, xmlFrame{})
+	}
+
+	frame := &x.frames[x.depth]
+	frame.name = append(frame.name[:0], name...)
+	frame.startTagEnd = false
+	x.depth++
+
+	x.out.WriteByte('<')
+	x.out.Write(frame.name)
+
+	return nil
+}
+
+func (x *xmlTranscoder) close() {
+	frame := x.top()
+	x.depth--
+
+	if !frame.startTagEnd {
+		x.out.WriteString("/>")
+	} else {
+		x.out.WriteString("</")
+		x.out.Write(frame.name)
+		x.out.WriteByte('>')
+	}
+
+	if x.depth == 0 {
+		x.out.WriteByte('\n')
+	}
+}
+
+// ToXML converts JSON from src to XML written to dst token by token without
+// materializing documents. Every top-level value is written as a separate element, one
+// per line. Members of objects are written as elements named after their keys, elements
+// of arrays as elements named opts.ItemElement, scalars become text content (null is
+// written as an empty element). Keys that are not valid XML names cause an error. See
+// XMLOptions for the mapping of members to attributes and text.
+func ToXML(dst io.Writer, src io.Reader, opts XMLOptions) error {
+	if opts.RootElement == "" {
+		opts.RootElement = "root"
+	}
+	if opts.ItemElement == "" {
+		opts.ItemElement = "item"
+	}
+
+	t, err := newTranscoder(src)
+	if err != nil {
+		return err
+	}
+
+	x := &xmlTranscoder{
+		transcoder: t,
+		opts:       opts,
+		out:        bufio.NewWriter(dst),
+	}
+
+	for {
+		token, role, err := x.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch role {
+		case gojsonlex.TokenRoleScalar:
+			err = x.writeScalar(&token)
+			if err == nil && x.depth == 0 {
+				err = x.out.WriteByte('\n')
+			}
+		case gojsonlex.TokenRoleOpen:
+			err = x.open()
+		case gojsonlex.TokenRoleClose:
+			x.close()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return x.out.Flush()
+}
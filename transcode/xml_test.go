@@ -0,0 +1,80 @@
+package transcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type transcodeToXMLTestCase struct {
+	input  string
+	opts   XMLOptions
+	output string
+}
+
+func TestToXML(t *testing.T) {
+	attrs := XMLOptions{RootElement: "user", AttributePrefix: "@", TextKey: "#text"}
+
+	testcases := []transcodeToXMLTestCase{
+		{
+			`{"name": "Bob & <Alice>", "age": 42, "admin": false, "email": null}`,
+			XMLOptions{},
+			"<root><name>Bob &amp; &lt;Alice&gt;</name><age>42</age><admin>false</admin><email/></root>\n",
+		},
+		{
+			`{"tags": ["a", "b"], "empty": [], "obj": {}, "nested": [[1], {"x": 2}]}`,
+			XMLOptions{ItemElement: "tag"},
+			"<root><tags><tag>a</tag><tag>b</tag></tags><empty/><obj/>" +
+				"<nested><tag><tag>1</tag></tag><tag><x>2</x></tag></nested></root>\n",
+		},
+		{
+			`{"@id": 7, "@note": "say \"hi\"", "#text": "hello", "child": {"@k": "v"}}`,
+			attrs,
+			"<user id=\"7\" note=\"say &#34;hi&#34;\">hello<child k=\"v\"/></user>\n",
+		},
+		{
+			`1 "x" [true] {"a": 1e21}`,
+			XMLOptions{},
+			"<root>1</root>\n<root>x</root>\n<root><item>true</item></root>\n<root><a>1e+21</a></root>\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := ToXML(out, strings.NewReader(testcase.input), testcase.opts)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+type transcodeToXMLFailsTestCase struct {
+	input string
+	opts  XMLOptions
+}
+
+func TestToXMLFails(t *testing.T) {
+	testcases := []transcodeToXMLFailsTestCase{
+		{`{"a b": 1}`, XMLOptions{}},
+		{`{"1st": 1}`, XMLOptions{}},
+		{`{"": 1}`, XMLOptions{}},
+		{`{"a": 1, "@id": 2}`, XMLOptions{AttributePrefix: "@"}},
+		{`{"@a b": 1}`, XMLOptions{AttributePrefix: "@"}},
+		{`{"a": [1, 2`, XMLOptions{}},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		if err := ToXML(out, strings.NewReader(testcase.input), testcase.opts); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase.input)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package transcode
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// yamlFlushSize is the size of the output buffered before writing it to the destination
+const yamlFlushSize = 4096
+
+type yamlFrame struct {
+	isObject bool
+	indent   int  // indentation of members (or elements)
+	empty    bool // reports whether no members (or elements) have been written yet
+}
+
+type yamlEmitter struct {
+	*transcoder
+
+	out io.Writer
+
+	frames []yamlFrame
+
+	afterKey      bool // the current line ends with "key:" waiting for the value
+	pendingInline bool // the current line ends with "- " waiting for the value
+	documents     int
+
+	buf []byte
+}
+
+// isPlainYAMLString reports whether s can be written as a plain (unquoted) scalar and
+// still be read back as the same string
+func isPlainYAMLString(s string) bool {
+	if s == "" || s[len(s)-1] == ' ' {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_', c == '/':
+		case i > 0 && ('0' <= c && c <= '9' || c == '.' || c == '-' || c == ' '):
+		default:
+			return false
+		}
+	}
+
+	// words that YAML resolves to bools and nulls
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+		return false
+	}
+
+	return true
+}
+
+func (y *yamlEmitter) appendString(s string) {
+	if isPlainYAMLString(s) {
+		y.buf = append(y.buf, s...)
+		return
+	}
+
+	// JSON escape sequences are valid in YAML double-quoted scalars
+	y.buf = append(y.buf, '"')
+	y.buf = gojsonlex.AppendEscapedString(y.buf, s, 0)
+	y.buf = append(y.buf, '"')
+}
+
+func (y *yamlEmitter) appendScalar(token *gojsonlex.TokenGeneric) (err error) {
+	switch token.Type() {
+	case gojsonlex.LexerTokenTypeString:
+		y.appendString(token.String())
+	case gojsonlex.LexerTokenTypeNumber:
+		if token.IsLossyNumber() {
+			y.buf = append(y.buf, token.String()...)
+			break
+		}
+
+		y.buf, err = jsonutil.AppendNumber(y.buf, token.Number())
+	case gojsonlex.LexerTokenTypeBool:
+		y.buf = strconv.AppendBool(y.buf, token.Bool())
+	case gojsonlex.LexerTokenTypeNull:
+		y.buf = append(y.buf, "null"...)
+	}
+
+	return err
+}
+
+func (y *yamlEmitter) appendIndent(n int) {
+	for i := 0; i < n; i++ {
+		y.buf = append(y.buf, ' ')
+	}
+}
+
+func (y *yamlEmitter) top() *yamlFrame {
+	if len(y.frames) == 0 {
+		return nil
+	}
+
+	return &y.frames[len(y.frames)-1]
+}
+
+// beginChild starts a new line for a member (or an element) of the innermost container
+// unless it can be continued inline
+func (y *yamlEmitter) beginChild(frame *yamlFrame) {
+	switch {
+	case y.afterKey:
+		y.buf = append(y.buf, '\n')
+		y.appendIndent(frame.indent)
+		y.afterKey = false
+	case y.pendingInline:
+		y.pendingInline = false
+	default:
+		y.appendIndent(frame.indent)
+	}
+
+	frame.empty = false
+}
+
+// beginValue writes everything that precedes a value, the returned frame is the frame
+// of the enclosing container
+func (y *yamlEmitter) beginValue() *yamlFrame {
+	frame := y.top()
+
+	switch {
+	case frame == nil:
+		if y.documents > 0 {
+			y.buf = append(y.buf, "---\n"...)
+		}
+
+		y.documents++
+	case !frame.isObject:
+		y.beginChild(frame)
+		y.buf = append(y.buf, '-', ' ')
+		y.pendingInline = true
+	}
+
+	return frame
+}
+
+func (y *yamlEmitter) processToken(token *gojsonlex.TokenGeneric, role gojsonlex.TokenRole) error {
+	switch role {
+	case gojsonlex.TokenRoleKey:
+		y.beginChild(y.top())
+		y.appendString(token.String())
+		y.buf = append(y.buf, ':')
+		y.afterKey = true
+	case gojsonlex.TokenRoleScalar:
+		y.beginValue()
+
+		if y.afterKey {
+			y.buf = append(y.buf, ' ')
+		}
+
+		y.afterKey, y.pendingInline = false, false
+
+		if err := y.appendScalar(token); err != nil {
+			return err
+		}
+
+		y.buf = append(y.buf, '\n')
+	case gojsonlex.TokenRoleOpen:
+		// the container starts on the next line (or continues the current one after "- ")
+		// once it turns out to be non-empty
+		parent := y.beginValue()
+
+		frame := yamlFrame{isObject: token.Delim() == '{', empty: true}
+		if parent != nil {
+			frame.indent = parent.indent + 2
+		}
+
+		y.frames = append(y.frames, frame)
+	case gojsonlex.TokenRoleClose:
+		frame := y.frames[len(y.frames)-1]
+		y.frames = y.frames[:len(y.frames)-1]
+
+		if !frame.empty {
+			break
+		}
+
+		if y.afterKey {
+			y.buf = append(y.buf, ' ')
+		}
+
+		if frame.isObject {
+			y.buf = append(y.buf, "{}\n"...)
+		} else {
+			y.buf = append(y.buf, "[]\n"...)
+		}
+
+		y.afterKey, y.pendingInline = false, false
+	}
+
+	return nil
+}
+
+func (y *yamlEmitter) flush() error {
+	_, err := y.out.Write(y.buf)
+	y.buf = y.buf[:0]
+
+	return err
+}
+
+// ToYAML converts JSON from src to block style YAML written to dst token by token
+// without materializing documents. Every top-level value is written as a separate YAML
+// document, documents are separated with "---". Strings are quoted only if they would be
+// read back as something else otherwise (e.g. "true", "1.5" or "a: b").
+func ToYAML(dst io.Writer, src io.Reader) error {
+	t, err := newTranscoder(src)
+	if err != nil {
+		return err
+	}
+
+	y := &yamlEmitter{
+		transcoder: t,
+		out:        dst,
+	}
+
+	for {
+		token, role, err := y.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == gojsonlex.TokenRoleSeparator {
+			continue
+		}
+
+		if err := y.processToken(&token, role); err != nil {
+			return err
+		}
+
+		if len(y.buf) >= yamlFlushSize {
+			if err := y.flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return y.flush()
+}
@@ -0,0 +1,52 @@
+package transcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type transcodeToYAMLTestCase struct {
+	input  string
+	output string
+}
+
+func TestToYAML(t *testing.T) {
+	testcases := []transcodeToYAMLTestCase{
+		{
+			`{"name": "Bob", "age": 42, "admin": false, "email": null}`,
+			"name: Bob\nage: 42\nadmin: false\nemail: null\n",
+		},
+		{
+			`{"tags": ["a", "b"], "empty": [], "obj": {}, "nested": {"x": {"y": 1}}}`,
+			"tags:\n  - a\n  - b\nempty: []\nobj: {}\nnested:\n  x:\n    \"y\": 1\n",
+		},
+		{
+			`[[1, 2], {"a": 1, "b": [true]}, [], {}, [[]]]`,
+			"- - 1\n  - 2\n- a: 1\n  b:\n    - true\n- []\n- {}\n- - []\n",
+		},
+		{
+			`["yes", "No", "1.5", "", " x", "a: b", "# c", "line\nbreak", "usr/bin", "with space", "-x", "ключ"]`,
+			"- \"yes\"\n- \"No\"\n- \"1.5\"\n- \"\"\n- \" x\"\n- \"a: b\"\n- \"# c\"\n- \"line\\nbreak\"\n" +
+				"- usr/bin\n- with space\n- \"-x\"\n- \"ключ\"\n",
+		},
+		{
+			`{"true": 1, "a b": "c"} 1 "x" [] {}`,
+			"\"true\": 1\na b: c\n---\n1\n---\nx\n---\n[]\n---\n{}\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		if err := ToYAML(out, strings.NewReader(testcase.input)); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
@@ -0,0 +1,543 @@
+// Package transform contains streaming transforms of JSON documents built on top of
+// gojsonlex: they copy tokens from the input to the output modifying some of them on the
+// way, so memory consumption does not depend on the size of the input.
+package transform
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+// transformer is a common base for the streaming transforms that copy tokens from
+// a lexer to a TokenWriter modifying some of them on the way
+type transformer struct {
+	l  *gojsonlex.JSONLexer
+	w  *gojsonlex.TokenWriter
+	tr gojsonlex.PathTracker
+}
+
+func newTransformer(dst io.Writer, src io.Reader) (*transformer, error) {
+	l, err := gojsonlex.NewJSONLexer(src)
+	if err != nil {
+		return nil, err
+	}
+
+	l.SetSkipDelims(false)
+	// numbers are written exactly as they appear in the input
+	l.SetRawNumbers(true)
+
+	return &transformer{
+		l: l,
+		w: gojsonlex.NewTokenWriter(dst),
+	}, nil
+}
+
+// next returns the next token from the input along with its role, io.EOF is returned
+// once the input has been exhausted
+func (t *transformer) next() (gojsonlex.TokenGeneric, gojsonlex.TokenRole, error) {
+	token, err := t.l.TokenFast()
+	if err != nil {
+		return token, 0, err
+	}
+
+	role, err := t.tr.Feed(&token)
+
+	return token, role, err
+}
+
+// context returns the context of the value started by the last token returned by next
+func (t *transformer) context() gojsonlex.TokenContext {
+	return t.tr.Context(t.l.Context().Offset())
+}
+
+// skipRest skips the rest of the value that has been started by a token with the given role
+func (t *transformer) skipRest(role gojsonlex.TokenRole) error {
+	if role != gojsonlex.TokenRoleOpen {
+		return nil
+	}
+
+	for depth := t.tr.Depth() - 1; t.tr.Depth() != depth; {
+		if _, _, err := t.next(); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("unexpected EOF")
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplaceAtPath copies JSON from src to dst substituting every value found at path with
+// the given tokens. The replaced values are skipped without being buffered, so memory
+// consumption does not depend on the size of the input. The output is compact JSON.
+func ReplaceAtPath(dst io.Writer, src io.Reader, path gojsonlex.Path, value []gojsonlex.TokenGeneric) error {
+	t, err := newTransformer(dst, src)
+	if err != nil {
+		return err
+	}
+
+	for {
+		token, role, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role.StartsValue() && t.tr.Matches(path) {
+			if err := t.w.WriteTokens(value); err != nil {
+				return err
+			}
+
+			if err := t.skipRest(role); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := t.w.WriteToken(token); err != nil {
+			return err
+		}
+	}
+
+	return t.w.Flush()
+}
+
+// insertIntoContainers copies JSON from src to dst writing the given tokens right before
+// the end of every object (or array) found at path
+func insertIntoContainers(dst io.Writer, src io.Reader, path gojsonlex.Path, isObject bool, tokens []gojsonlex.TokenGeneric) error {
+	t, err := newTransformer(dst, src)
+	if err != nil {
+		return err
+	}
+
+	// since path has a fixed length, containers at path can not be nested into each other
+	targetDepth := -1
+
+	for {
+		token, role, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == gojsonlex.TokenRoleOpen && t.tr.Matches(path) && (token.Delim() == '{') == isObject {
+			targetDepth = t.tr.Depth()
+		}
+
+		if role == gojsonlex.TokenRoleClose && t.tr.Depth() == targetDepth-1 {
+			if err := t.w.WriteTokens(tokens); err != nil {
+				return err
+			}
+
+			targetDepth = -1
+		}
+
+		if err := t.w.WriteToken(token); err != nil {
+			return err
+		}
+	}
+
+	return t.w.Flush()
+}
+
+// InsertAtPath copies JSON from src to dst adding a new member with the given key and
+// value to the end of every object found at path. Existing members with the same key are
+// left intact. The output is compact JSON.
+func InsertAtPath(dst io.Writer, src io.Reader, path gojsonlex.Path, key string, value []gojsonlex.TokenGeneric) error {
+	tokens := make([]gojsonlex.TokenGeneric, 0, len(value)+1)
+	tokens = append(tokens, gojsonlex.NewTokenGenericFromString(key))
+	tokens = append(tokens, value...)
+
+	return insertIntoContainers(dst, src, path, true, tokens)
+}
+
+// AppendAtPath copies JSON from src to dst appending the given value to every array found
+// at path. The output is compact JSON.
+func AppendAtPath(dst io.Writer, src io.Reader, path gojsonlex.Path, value []gojsonlex.TokenGeneric) error {
+	return insertIntoContainers(dst, src, path, false, value)
+}
+
+// recordRest records the rest of the value that has been started by the given token
+func (t *transformer) recordRest(r *gojsonlex.TokenRecorder, token gojsonlex.TokenGeneric, role gojsonlex.TokenRole) error {
+	r.Record(token)
+
+	if role != gojsonlex.TokenRoleOpen {
+		return nil
+	}
+
+	for depth := t.tr.Depth() - 1; t.tr.Depth() != depth; {
+		token, _, err := t.next()
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("unexpected EOF")
+			}
+
+			return err
+		}
+
+		r.Record(token)
+	}
+
+	return nil
+}
+
+// FilterArray copies JSON from src to dst keeping only those elements of arrays found
+// at path that satisfy predicate. predicate is given the context of an element and
+// a TokenSource producing all its tokens (including delimiters), strings produced by it
+// are valid until predicate returns. Only one element at a time is buffered. The output
+// is compact JSON.
+func FilterArray(dst io.Writer, src io.Reader, path gojsonlex.Path, predicate func(ctx gojsonlex.TokenContext, elem gojsonlex.TokenSource) bool) error {
+	t, err := newTransformer(dst, src)
+	if err != nil {
+		return err
+	}
+
+	// since path has a fixed length, arrays at path can not be nested into each other
+	targetDepth := -1
+	elem := gojsonlex.TokenRecorder{}
+
+	for {
+		token, role, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role.StartsValue() && t.tr.ValueDepth() == targetDepth {
+			ctx := t.context()
+			elem.Reset()
+
+			if err := t.recordRest(&elem, token, role); err != nil {
+				return err
+			}
+
+			if !predicate(ctx, &elem) {
+				continue
+			}
+
+			if err := t.w.WriteTokens(elem.Tokens()); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if role == gojsonlex.TokenRoleOpen && token.Delim() == '[' && t.tr.Matches(path) {
+			targetDepth = t.tr.Depth()
+		}
+
+		if role == gojsonlex.TokenRoleClose && t.tr.Depth() == targetDepth-1 {
+			targetDepth = -1
+		}
+
+		if err := t.w.WriteToken(token); err != nil {
+			return err
+		}
+	}
+
+	return t.w.Flush()
+}
+
+// Coercion describes a conversion applied to a scalar value by Coerce
+type Coercion byte
+
+const (
+	// CoerceToNumber converts strings containing a valid JSON number to numbers keeping
+	// their exact text
+	CoerceToNumber Coercion = iota
+	// CoerceToString converts numbers and bools to strings, numbers keep their exact text
+	CoerceToString
+	// CoerceToBool converts strings "true" and "false" to bools
+	CoerceToBool
+)
+
+// coerce returns the token converted according to c, tokens that can not be converted
+// are returned intact
+func (c Coercion) coerce(token gojsonlex.TokenGeneric, buf []byte) (gojsonlex.TokenGeneric, []byte) {
+	switch {
+	case c == CoerceToNumber && token.Type() == gojsonlex.LexerTokenTypeString && jsonutil.IsValidNumber(token.String()):
+		// the text is written as is, so that big and precise numbers are not rounded
+		return gojsonlex.NewTokenGenericFromRawNumber(token.String()), buf
+	case c == CoerceToString && token.Type() == gojsonlex.LexerTokenTypeNumber:
+		if raw := token.NumberRaw(); raw != "" {
+			return gojsonlex.NewTokenGenericFromString(raw), buf
+		}
+
+		buf, _ = jsonutil.AppendNumber(buf[:0], token.Number())
+		return gojsonlex.NewTokenGenericFromBytesUnsafe(buf), buf
+	case c == CoerceToString && token.Type() == gojsonlex.LexerTokenTypeBool:
+		return gojsonlex.NewTokenGenericFromString(strconv.FormatBool(token.Bool())), buf
+	case c == CoerceToBool && token.Type() == gojsonlex.LexerTokenTypeString:
+		switch token.String() {
+		case "true":
+			return gojsonlex.NewTokenGenericFromBool(true), buf
+		case "false":
+			return gojsonlex.NewTokenGenericFromBool(false), buf
+		}
+	}
+
+	return token, buf
+}
+
+// PathCoercion binds a Coercion to a path
+type PathCoercion struct {
+	Path     gojsonlex.Path
+	Coercion Coercion
+}
+
+// Coerce copies JSON from src to dst converting scalar values found at the given paths
+// according to the bound coercions. Values that can not be converted (including objects
+// and arrays) are copied intact. The output is compact JSON.
+func Coerce(dst io.Writer, src io.Reader, coercions []PathCoercion) error {
+	t, err := newTransformer(dst, src)
+	if err != nil {
+		return err
+	}
+
+	var numBuf []byte
+
+	for {
+		token, role, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == gojsonlex.TokenRoleScalar {
+			for _, c := range coercions {
+				if t.tr.Matches(c.Path) {
+					token, numBuf = c.Coercion.coerce(token, numBuf)
+					break
+				}
+			}
+		}
+
+		if err := t.w.WriteToken(token); err != nil {
+			return err
+		}
+	}
+
+	return t.w.Flush()
+}
+
+// ValueTransformer computes a replacement for the scalar value tok, ctx describes its
+// position (e.g. ctx.String() is "users.3.ip"). If ok is false the value is copied
+// intact. Strings of tok are valid only until the transformer returns, while strings of
+// the result must stay valid until the next call of the transformer.
+type ValueTransformer func(ctx gojsonlex.TokenContext, tok gojsonlex.TokenGeneric) (result gojsonlex.TokenGeneric, ok bool)
+
+// PathTransformer binds a ValueTransformer to a path
+type PathTransformer struct {
+	Path        gojsonlex.Path
+	Transformer ValueTransformer
+}
+
+// TransformValues copies JSON from src to dst passing scalar values found at the given
+// paths through the bound transformers, the first matching transformer is applied.
+// Objects and arrays are copied intact. The output is compact JSON.
+func TransformValues(dst io.Writer, src io.Reader, transformers []PathTransformer) error {
+	t, err := newTransformer(dst, src)
+	if err != nil {
+		return err
+	}
+
+	for {
+		token, role, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == gojsonlex.TokenRoleScalar {
+			for _, pt := range transformers {
+				if !t.tr.Matches(pt.Path) {
+					continue
+				}
+
+				if result, ok := pt.Transformer(t.context(), token); ok {
+					token = result
+				}
+
+				break
+			}
+		}
+
+		if err := t.w.WriteToken(token); err != nil {
+			return err
+		}
+	}
+
+	return t.w.Flush()
+}
+
+// StripFlags tune the behaviour of StripNulls
+type StripFlags byte
+
+const (
+	// StripEmptyContainers makes StripNulls also drop members whose value is an empty
+	// object or array (including the ones that become empty after stripping)
+	StripEmptyContainers StripFlags = 1 << iota
+)
+
+type stripFrame struct {
+	open    byte   // '{' or '['
+	key     []byte // key of the container in the enclosing object
+	hasKey  bool
+	written bool // reports whether the container has been written to the output
+}
+
+type nullStripper struct {
+	*transformer
+
+	flags StripFlags
+
+	frames []stripFrame
+	depth  int
+
+	pendingKey    []byte // key that will be written only if its value is not dropped
+	hasPendingKey bool
+}
+
+// materialize writes all containers that have been postponed so far
+func (s *nullStripper) materialize() error {
+	for i := 0; i < s.depth; i++ {
+		frame := &s.frames[i]
+		if frame.written {
+			continue
+		}
+
+		if frame.hasKey {
+			key := gojsonlex.NewTokenGenericFromBytesUnsafe(frame.key)
+			if err := s.w.WriteToken(key); err != nil {
+				return err
+			}
+		}
+
+		if err := s.w.WriteToken(gojsonlex.NewTokenGenericFromDelim(frame.open)); err != nil {
+			return err
+		}
+
+		frame.written = true
+	}
+
+	return nil
+}
+
+func (s *nullStripper) writePendingKey() error {
+	if !s.hasPendingKey {
+		return nil
+	}
+
+	s.hasPendingKey = false
+
+	return s.w.WriteToken(gojsonlex.NewTokenGenericFromBytesUnsafe(s.pendingKey))
+}
+
+func (s *nullStripper) processOpen(token gojsonlex.TokenGeneric) error {
+	if s.depth == len(s.frames) {
+		s.frames = append(s.frames, stripFrame{})
+	}
+
+	frame := &s.frames[s.depth]
+	frame.open = byte(token.Delim())
+	frame.key = append(frame.key[:0], s.pendingKey...)
+	frame.hasKey = s.hasPendingKey
+	frame.written = false
+
+	s.depth++
+	s.hasPendingKey = false
+
+	// only object members can be dropped
+	if s.flags&StripEmptyContainers == 0 || !frame.hasKey {
+		return s.materialize()
+	}
+
+	return nil
+}
+
+func (s *nullStripper) processClose(token gojsonlex.TokenGeneric) error {
+	s.depth--
+
+	if !s.frames[s.depth].written {
+		return nil
+	}
+
+	return s.w.WriteToken(token)
+}
+
+func (s *nullStripper) processScalar(token gojsonlex.TokenGeneric) error {
+	if token.Type() == gojsonlex.LexerTokenTypeNull && s.hasPendingKey {
+		s.hasPendingKey = false
+		return nil
+	}
+
+	if err := s.materialize(); err != nil {
+		return err
+	}
+
+	if err := s.writePendingKey(); err != nil {
+		return err
+	}
+
+	return s.w.WriteToken(token)
+}
+
+// StripNulls copies JSON from src to dst dropping object members whose value is null,
+// nulls inside arrays are kept intact. See StripFlags for more options. The output is
+// compact JSON.
+func StripNulls(dst io.Writer, src io.Reader, flags StripFlags) error {
+	t, err := newTransformer(dst, src)
+	if err != nil {
+		return err
+	}
+
+	s := nullStripper{
+		transformer: t,
+		flags:       flags,
+	}
+
+	for {
+		token, role, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch role {
+		case gojsonlex.TokenRoleKey:
+			s.pendingKey = append(s.pendingKey[:0], token.String()...)
+			s.hasPendingKey = true
+		case gojsonlex.TokenRoleScalar:
+			err = s.processScalar(token)
+		case gojsonlex.TokenRoleOpen:
+			err = s.processOpen(token)
+		case gojsonlex.TokenRoleClose:
+			err = s.processClose(token)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.w.Flush()
+}
@@ -0,0 +1,485 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type replaceAtPathTestCase struct {
+	input  string
+	path   string
+	value  []gojsonlex.TokenGeneric
+	output string
+}
+
+func TestReplaceAtPath(t *testing.T) {
+	testcases := []replaceAtPathTestCase{
+		{
+			`{"a": 1, "b": 2}`,
+			"b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromString("two")},
+			`{"a":1,"b":"two"}`,
+		},
+		{
+			`{"a": {"b": [1, 2, {"c": 3}]}, "d": true}`,
+			"a.b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNull()},
+			`{"a":{"b":null},"d":true}`,
+		},
+		{
+			`{"a": {"b": [1, 2, {"c": 3}]}, "d": true}`,
+			"a.b.2.c",
+			[]gojsonlex.TokenGeneric{
+				gojsonlex.NewTokenGenericFromDelim('['),
+				gojsonlex.NewTokenGenericFromBool(false),
+				gojsonlex.NewTokenGenericFromDelim(']'),
+			},
+			`{"a":{"b":[1,2,{"c":[false]}]},"d":true}`,
+		},
+		{
+			`{"a": 1} {"a": 2} {"b": 3}`,
+			"a",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNumber(0)},
+			"{\"a\":0}\n{\"a\":0}\n{\"b\":3}",
+		},
+		{
+			`{"a": 1}`,
+			"b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNumber(0)},
+			`{"a":1}`,
+		},
+		{
+			`[1, 2, 3]`,
+			"",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromString("all")},
+			`"all"`,
+		},
+		{
+			// numbers beyond 2^53 and insignificant zeros are kept intact
+			`{"id": 12345678901234567890, "price": 1.10, "n": 9007199254740993}`,
+			"name",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNull()},
+			`{"id":12345678901234567890,"price":1.10,"n":9007199254740993}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := ReplaceAtPath(out, strings.NewReader(testcase.input), gojsonlex.ParsePath(testcase.path), testcase.value)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+func TestReplaceAtPathFails(t *testing.T) {
+	testcases := []string{
+		`{"a": [1, 2}`,
+		`{"a": [1, 2]`,
+		`{"a": }}`,
+		`{1: 2}`,
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+		value := []gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNull()}
+
+		if err := ReplaceAtPath(out, strings.NewReader(testcase), gojsonlex.ParsePath("a"), value); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase)
+		}
+	}
+}
+
+type insertAtPathTestCase struct {
+	input  string
+	path   string
+	key    string
+	value  []gojsonlex.TokenGeneric
+	output string
+}
+
+func TestInsertAtPath(t *testing.T) {
+	testcases := []insertAtPathTestCase{
+		{
+			`{"a": 1}`,
+			"",
+			"b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNumber(2)},
+			`{"a":1,"b":2}`,
+		},
+		{
+			`{}`,
+			"",
+			"b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNumber(2)},
+			`{"b":2}`,
+		},
+		{
+			`{"a": {"b": {}}, "c": {"d": 1}}`,
+			"c",
+			"e",
+			[]gojsonlex.TokenGeneric{
+				gojsonlex.NewTokenGenericFromDelim('{'),
+				gojsonlex.NewTokenGenericFromString("f"),
+				gojsonlex.NewTokenGenericFromNull(),
+				gojsonlex.NewTokenGenericFromDelim('}'),
+			},
+			`{"a":{"b":{}},"c":{"d":1,"e":{"f":null}}}`,
+		},
+		{
+			`[{"a": 1}, {"a": 2}]`,
+			"1",
+			"b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromBool(true)},
+			`[{"a":1},{"a":2,"b":true}]`,
+		},
+		{
+			// arrays are not affected
+			`{"a": []}`,
+			"a",
+			"b",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromBool(true)},
+			`{"a":[]}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := InsertAtPath(out, strings.NewReader(testcase.input),
+			gojsonlex.ParsePath(testcase.path), testcase.key, testcase.value)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+func TestAppendAtPath(t *testing.T) {
+	testcases := []replaceAtPathTestCase{
+		{
+			`[]`,
+			"",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNumber(1)},
+			`[1]`,
+		},
+		{
+			`{"a": [1, [2]], "b": [3]}`,
+			"a",
+			[]gojsonlex.TokenGeneric{
+				gojsonlex.NewTokenGenericFromDelim('['),
+				gojsonlex.NewTokenGenericFromNumber(4),
+				gojsonlex.NewTokenGenericFromDelim(']'),
+			},
+			`{"a":[1,[2],[4]],"b":[3]}`,
+		},
+		{
+			`{"a": [1, [2]], "b": [3]}`,
+			"a.1",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromString("x")},
+			`{"a":[1,[2,"x"]],"b":[3]}`,
+		},
+		{
+			// objects are not affected
+			`{"a": {}}`,
+			"a",
+			[]gojsonlex.TokenGeneric{gojsonlex.NewTokenGenericFromNumber(1)},
+			`{"a":{}}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := AppendAtPath(out, strings.NewReader(testcase.input), gojsonlex.ParsePath(testcase.path), testcase.value)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+type filterArrayTestCase struct {
+	input  string
+	path   string
+	output string
+}
+
+// hasNameMetallica is a predicate accepting objects with the "name" key equal to "Metallica"
+func hasNameMetallica(_ gojsonlex.TokenContext, s gojsonlex.TokenSource) bool {
+	pendingName := false
+
+	for {
+		token, err := s.TokenFast()
+		if err != nil {
+			return false
+		}
+
+		if token.Type() != gojsonlex.LexerTokenTypeString {
+			continue
+		}
+
+		if pendingName && token.String() == "Metallica" {
+			return true
+		}
+
+		pendingName = token.String() == "name"
+	}
+}
+
+func TestFilterArray(t *testing.T) {
+	testcases := []filterArrayTestCase{
+		{
+			`[{"name": "Metallica"}, {"name": "Muse"}, "Metallica", {"name": "Metallica", "x": [1]}]`,
+			"",
+			`[{"name":"Metallica"},{"name":"Metallica","x":[1]}]`,
+		},
+		{
+			`{"bands": [{"name": "Muse"}], "other": [{"name": "Muse"}]}`,
+			"bands",
+			`{"bands":[],"other":[{"name":"Muse"}]}`,
+		},
+		{
+			`{"bands": [[{"name": "Muse"}], [{"name": "Metallica"}]]}`,
+			"bands.1",
+			`{"bands":[[{"name":"Muse"}],[{"name":"Metallica"}]]}`,
+		},
+		{
+			`{"bands": {"name": "Muse"}}`,
+			"bands",
+			`{"bands":{"name":"Muse"}}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := FilterArray(out, strings.NewReader(testcase.input), gojsonlex.ParsePath(testcase.path), hasNameMetallica)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+func TestFilterArrayContext(t *testing.T) {
+	input := `{"bands": [{"name": "Muse"}, "x", [1]]}`
+
+	var contexts []string
+
+	keepOdd := func(ctx gojsonlex.TokenContext, elem gojsonlex.TokenSource) bool {
+		contexts = append(contexts, fmt.Sprintf("%s@%d", ctx, ctx.Offset()))
+		return ctx.Matches(gojsonlex.ParsePath("bands.1"))
+	}
+
+	out := &bytes.Buffer{}
+	if err := FilterArray(out, strings.NewReader(input), gojsonlex.ParsePath("bands"), keepOdd); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if out.String() != `{"bands":["x"]}` {
+		t.Errorf("got '%s'", out.String())
+	}
+
+	expected := []string{"bands.0@11", "bands.1@29", "bands.2@34"}
+	if !reflect.DeepEqual(contexts, expected) {
+		t.Errorf("got contexts %v, expected %v", contexts, expected)
+	}
+}
+
+type coerceTestCase struct {
+	input     string
+	coercions []PathCoercion
+	output    string
+}
+
+func TestCoerce(t *testing.T) {
+	testcases := []coerceTestCase{
+		{
+			`{"id": "253", "ip": 127, "valid": "true", "deleted": "false", "bad": "yes"}`,
+			[]PathCoercion{
+				{gojsonlex.ParsePath("id"), CoerceToNumber},
+				{gojsonlex.ParsePath("ip"), CoerceToString},
+				{gojsonlex.ParsePath("valid"), CoerceToBool},
+				{gojsonlex.ParsePath("deleted"), CoerceToBool},
+				{gojsonlex.ParsePath("bad"), CoerceToBool},
+			},
+			`{"id":253,"ip":"127","valid":true,"deleted":false,"bad":"yes"}`,
+		},
+		{
+			`[{"v": "1.5"}, {"v": "abc"}, {"v": {"x": "1"}}, {"v": true}, {"v": "NaN"}]`,
+			[]PathCoercion{
+				{gojsonlex.ParsePath("0.v"), CoerceToNumber},
+				{gojsonlex.ParsePath("1.v"), CoerceToNumber},
+				{gojsonlex.ParsePath("2.v"), CoerceToNumber},
+				{gojsonlex.ParsePath("3.v"), CoerceToString},
+				{gojsonlex.ParsePath("4.v"), CoerceToNumber},
+			},
+			`[{"v":1.5},{"v":"abc"},{"v":{"x":"1"}},{"v":"true"},{"v":"NaN"}]`,
+		},
+		{
+			`{"v": 3.14} {"v": 1e21}`,
+			[]PathCoercion{{gojsonlex.ParsePath("v"), CoerceToString}},
+			"{\"v\":\"3.14\"}\n{\"v\":\"1e21\"}",
+		},
+		{
+			`{"a": 12345678901234567890, "b": "9007199254740993", "c": "-0.10"}`,
+			[]PathCoercion{
+				{gojsonlex.ParsePath("a"), CoerceToString},
+				{gojsonlex.ParsePath("b"), CoerceToNumber},
+				{gojsonlex.ParsePath("c"), CoerceToNumber},
+			},
+			`{"a":"12345678901234567890","b":9007199254740993,"c":-0.10}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		err := Coerce(out, strings.NewReader(testcase.input), testcase.coercions)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+type transformValuesTestCase struct {
+	input        string
+	transformers []PathTransformer
+	output       string
+}
+
+func TestTransformValues(t *testing.T) {
+	var paths []string
+
+	anonymize := func(ctx gojsonlex.TokenContext, tok gojsonlex.TokenGeneric) (gojsonlex.TokenGeneric, bool) {
+		paths = append(paths, ctx.String())
+
+		if tok.Type() != gojsonlex.LexerTokenTypeString {
+			return tok, false
+		}
+
+		if i := strings.LastIndexByte(tok.String(), '.'); i >= 0 {
+			return gojsonlex.NewTokenGenericFromString(tok.StringCopy()[:i] + ".0"), true
+		}
+
+		return gojsonlex.NewTokenGenericFromNull(), true
+	}
+
+	testcases := []transformValuesTestCase{
+		{
+			`{"users": [{"ip": "10.1.2.3"}, {"ip": "localhost"}, {"ip": 7}, {"ip": {"v4": "1.2.3.4"}}]}`,
+			[]PathTransformer{{gojsonlex.ParsePath("users.*.ip"), anonymize}},
+			`{"users":[{"ip":"10.1.2.0"},{"ip":null},{"ip":7},{"ip":{"v4":"1.2.3.4"}}]}`,
+		},
+		{
+			`{"a.b": "x.y", "c": ["p.q"]}`,
+			[]PathTransformer{
+				{gojsonlex.ParsePath("c.0"), anonymize},
+				{gojsonlex.ParsePath("*"), anonymize},
+				{gojsonlex.ParsePath("c.*"), func(gojsonlex.TokenContext, gojsonlex.TokenGeneric) (gojsonlex.TokenGeneric, bool) {
+					return gojsonlex.NewTokenGenericFromBool(true), true
+				}},
+			},
+			`{"a.b":"x.0","c":["p.0"]}`,
+		},
+	}
+
+	expectedPaths := [][]string{
+		{"users.0.ip", "users.1.ip", "users.2.ip"},
+		{`a\.b`, "c.0"},
+	}
+
+	for i, testcase := range testcases {
+		out := &bytes.Buffer{}
+		paths = nil
+
+		err := TransformValues(out, strings.NewReader(testcase.input), testcase.transformers)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+
+		if !reflect.DeepEqual(paths, expectedPaths[i]) {
+			t.Errorf("testcase '%s': got paths %q, expected %q",
+				testcase.input, paths, expectedPaths[i])
+		}
+	}
+}
+
+type stripNullsTestCase struct {
+	input  string
+	flags  StripFlags
+	output string
+}
+
+func TestStripNulls(t *testing.T) {
+	testcases := []stripNullsTestCase{
+		{`[null]`, 0, `[null]`},
+		{`{"a": null}`, 0, `{}`},
+		{`{"a": null, "b": 1, "c": null}`, 0, `{"b":1}`},
+		{`{"a": [null, 1, null], "b": {"c": null}}`, 0, `{"a":[null,1,null],"b":{}}`},
+		{`{"a": [], "b": {}, "c": {"d": null}}`, 0, `{"a":[],"b":{},"c":{}}`},
+		{`{"a": [], "b": {}, "c": {"d": null}}`, StripEmptyContainers, `{}`},
+		{
+			`{"a": {"b": {"c": null}, "d": 1}, "e": [{}, []], "f": {"g": {"h": {}}}}`,
+			StripEmptyContainers,
+			`{"a":{"d":1},"e":[{},[]]}`,
+		},
+		{`[{}, {"a": null}]`, StripEmptyContainers, `[{},{}]`},
+		{`{"a": null} {"b": {"c": {}}} {"d": 1}`, StripEmptyContainers, "{}\n{}\n{\"d\":1}"},
+		{`{"a": null, "b": 9007199254740993, "c": -1.50e3}`, 0, `{"b":9007199254740993,"c":-1.50e3}`},
+	}
+
+	for _, testcase := range testcases {
+		out := &bytes.Buffer{}
+
+		if err := StripNulls(out, strings.NewReader(testcase.input), testcase.flags); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
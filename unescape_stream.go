@@ -0,0 +1,106 @@
+package gojsonlex
+
+import (
+	"io"
+)
+
+type unescapingReader struct {
+	r   io.Reader
+	u   bytesUnescaper
+	err error // sticky error returned once all unescaped data is consumed
+
+	chunk  []byte // raw data read from r
+	out    []byte // unescaped data not yet returned to the caller
+	outPos int
+}
+
+// NewUnescapingReader returns a reader that reads escaped JSON string contents (without
+// the surrounding quotes) from r and returns them unescaped. Escape sequences may be split
+// across reads arbitrarily, so payloads of any size can be decoded with constant memory.
+// Close releases the memory held by the reader, it does not close r.
+func NewUnescapingReader(r io.Reader) io.ReadCloser {
+	return &unescapingReader{
+		r:     r,
+		chunk: make([]byte, defaultBufSize),
+	}
+}
+
+func (ur *unescapingReader) Read(p []byte) (int, error) {
+	for ur.outPos >= len(ur.out) {
+		if ur.err != nil {
+			return 0, ur.err
+		}
+
+		ur.fill()
+	}
+
+	n := copy(p, ur.out[ur.outPos:])
+	ur.outPos += n
+
+	return n, nil
+}
+
+func (ur *unescapingReader) Close() error {
+	*ur = unescapingReader{err: ErrClosed}
+	return nil
+}
+
+func (ur *unescapingReader) fill() {
+	n, err := ur.r.Read(ur.chunk)
+
+	ur.u.output = ur.out[:0]
+	ur.outPos = 0
+
+	if uerr := ur.u.unescapeChunk(ur.chunk[:n]); uerr != nil {
+		// the output of the previous chunk must not be returned once again
+		ur.out = ur.out[:0]
+		ur.err = uerr
+		return
+	}
+
+	ur.out = ur.u.output
+
+	if err == io.EOF {
+		if terr := ur.u.terminate(); terr != nil {
+			err = terr
+		}
+	}
+
+	ur.err = err
+}
+
+type unescapingWriter struct {
+	w   io.Writer
+	u   bytesUnescaper
+	out []byte
+}
+
+// NewUnescapingWriter returns a writer that unescapes escaped JSON string contents
+// (without the surrounding quotes) written to it and writes the result to w. Close
+// MUST be called at the end of input in order to detect incomplete escape sequences,
+// it does not close w.
+func NewUnescapingWriter(w io.Writer) io.WriteCloser {
+	return &unescapingWriter{
+		w: w,
+	}
+}
+
+func (uw *unescapingWriter) Write(p []byte) (int, error) {
+	uw.u.output = uw.out[:0]
+
+	if err := uw.u.unescapeChunk(p); err != nil {
+		return 0, err
+	}
+
+	uw.out = uw.u.output
+
+	if _, err := uw.w.Write(uw.out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (uw *unescapingWriter) Close() error {
+	return uw.u.terminate()
+}
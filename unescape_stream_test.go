@@ -0,0 +1,109 @@
+package gojsonlex
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type unescapeStreamTestCase struct {
+	input  string
+	output string
+}
+
+var unescapeStreamTestCases = []unescapeStreamTestCase{
+	{"", ""},
+	{"hello\\nworld", "hello\nworld"},
+	{"\\\"hello world\\\"", "\"hello world\""},
+	{"hello \\u043f\\u0440\\u0438\\u0432\\u0435\\u0442 world", "hello привет world"},
+	{"hello \\UD83D\\UDCA9 world", "hello 💩 world"},
+}
+
+func TestUnescapingReader(t *testing.T) {
+	for _, testcase := range unescapeStreamTestCases {
+		r := NewUnescapingReader(iotest.OneByteReader(strings.NewReader(testcase.input)))
+
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if string(out) != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, string(out), testcase.output)
+		}
+	}
+}
+
+func TestUnescapingReaderFails(t *testing.T) {
+	testcases := []string{
+		"\\",
+		"\\a",
+		"\\u043",
+		"hello \\ud83d\\ufca9 world",
+		"hello \\ud83d world",
+	}
+
+	for _, testcase := range testcases {
+		r := NewUnescapingReader(iotest.OneByteReader(strings.NewReader(testcase)))
+
+		if _, err := ioutil.ReadAll(r); err == nil {
+			t.Errorf("testcase '%s': must have failed", testcase)
+		}
+	}
+}
+
+func TestUnescapingReaderFailsAfterOutput(t *testing.T) {
+	r := NewUnescapingReader(io.MultiReader(strings.NewReader("hello"), strings.NewReader("xx\\q")))
+
+	out, err := ioutil.ReadAll(r)
+	if err == nil {
+		t.Errorf("must have failed")
+	}
+
+	if string(out) != "hello" {
+		t.Errorf("got '%s', expected 'hello'", out)
+	}
+}
+
+func TestUnescapingWriter(t *testing.T) {
+	for _, testcase := range unescapeStreamTestCases {
+		out := &bytes.Buffer{}
+		w := NewUnescapingWriter(out)
+
+		var err error
+		for i := 0; i < len(testcase.input) && err == nil; i++ {
+			_, err = w.Write([]byte{testcase.input[i]})
+		}
+
+		if err == nil {
+			err = w.Close()
+		}
+
+		if err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if out.String() != testcase.output {
+			t.Errorf("testcase '%s': got '%s', expected '%s'",
+				testcase.input, out.String(), testcase.output)
+		}
+	}
+}
+
+func TestUnescapingReaderClose(t *testing.T) {
+	r := NewUnescapingReader(strings.NewReader(`a\nb`))
+
+	if err := r.Close(); err != nil {
+		t.Errorf("could not close reader: %v", err)
+	}
+
+	if _, err := r.Read(make([]byte, 4)); err != ErrClosed {
+		t.Errorf("got %v, expected ErrClosed", err)
+	}
+}
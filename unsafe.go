@@ -0,0 +1,41 @@
+//go:build !purego
+// +build !purego
+
+package gojsonlex
+
+import "unsafe"
+
+// zeroCopyStrings reports whether strings of tokens point into the buffer of the lexer,
+// see the purego build tag
+const zeroCopyStrings = true
+
+// sliceHeader and stringHeader mirror the runtime representation of slices and strings
+// shared by gc and TinyGo, reflect.SliceHeader is not relied upon
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
+
+type stringHeader struct {
+	data unsafe.Pointer
+	len  int
+}
+
+func unsafeStringFromBytes(arr []byte) string {
+	return *(*string)(unsafe.Pointer(&arr))
+}
+
+// unsafeBytesFromString returns a slice pointing into the given string, it MUST NOT be
+// modified
+func unsafeBytesFromString(s string) []byte {
+	str := (*stringHeader)(unsafe.Pointer(&s))
+
+	var arr []byte
+	slice := (*sliceHeader)(unsafe.Pointer(&arr))
+	slice.data = str.data
+	slice.len = str.len
+	slice.cap = str.len
+
+	return arr
+}
@@ -0,0 +1,152 @@
+// Package valueclass provides common classes of string values for
+// gojsonlex.JSONLexer.SetValueClasses. They live in a separate package, so that the
+// core lexer does not depend on the packages needed to match them.
+package valueclass
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gibsn/gojsonlex"
+	"github.com/gibsn/gojsonlex/jsonutil"
+)
+
+var (
+	// Timestamp matches ISO 8601 dates and date-times (RFC 3339 with the time
+	// zone being optional), e.g. "2021-03-04" and "2021-03-04T05:06:07.89Z"
+	Timestamp = gojsonlex.ValueClass{Name: "timestamp", Match: isISO8601Timestamp}
+	// Duration matches ISO 8601 durations, e.g. "P1Y2M3DT4H5M6.5S" and "PT15M"
+	Duration = gojsonlex.ValueClass{Name: "duration", Match: isISO8601Duration}
+	// UUID matches UUIDs in the canonical 8-4-4-4-12 form
+	UUID = gojsonlex.ValueClass{Name: "uuid", Match: isUUID}
+	// IP matches IPv4 and IPv6 addresses
+	IP = gojsonlex.ValueClass{Name: "ip", Match: isIP}
+)
+
+// Regexp returns a class matching strings that match re, anchor the expression with ^
+// and $ to match whole strings
+func Regexp(name string, re *regexp.Regexp) gojsonlex.ValueClass {
+	return gojsonlex.ValueClass{Name: name, Match: re.MatchString}
+}
+
+var timestampLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+}
+
+func isISO8601Timestamp(s string) bool {
+	// cheap check before trying the layouts
+	if len(s) < len("2006-01-02") || s[4] != '-' || s[7] != '-' || leadingDigits(s[:4]) != 4 {
+		return false
+	}
+
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isISO8601Duration reports whether s is PnYnMnWnDTnHnMnS with at least one component,
+// only the last component may have a fraction
+func isISO8601Duration(s string) bool {
+	if len(s) < len("P0D") || s[0] != 'P' {
+		return false
+	}
+
+	designators := "YMWD" // designators that may follow in the current part
+	inTime, components, fraction := false, 0, false
+
+	for i := 1; i < len(s); {
+		if s[i] == 'T' && !inTime {
+			if i+1 == len(s) {
+				return false // 'T' must be followed by a component
+			}
+
+			inTime, designators = true, "HMS"
+			i++
+
+			continue
+		}
+
+		if fraction {
+			return false
+		}
+
+		n := leadingDigits(s[i:])
+		if n == 0 {
+			return false
+		}
+
+		i += n
+
+		if i < len(s) && (s[i] == '.' || s[i] == ',') {
+			n := leadingDigits(s[i+1:])
+			if n == 0 {
+				return false
+			}
+
+			i += 1 + n
+			fraction = true
+		}
+
+		if i == len(s) {
+			return false
+		}
+
+		pos := strings.IndexByte(designators, s[i])
+		if pos < 0 {
+			return false
+		}
+
+		designators = designators[pos+1:]
+		components++
+		i++
+	}
+
+	return components > 0
+}
+
+// leadingDigits returns the number of digits at the beginning of s
+func leadingDigits(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+
+	return n
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !jsonutil.IsHexDigit(rune(s[i])) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isIP(s string) bool {
+	if len(s) < len("::") || len(s) > len("ffff:ffff:ffff:ffff:ffff:ffff:255.255.255.255") {
+		return false
+	}
+
+	return net.ParseIP(s) != nil
+}
@@ -0,0 +1,99 @@
+package valueclass
+
+import (
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gibsn/gojsonlex"
+)
+
+type valueClassTestCase struct {
+	input  string
+	output bool
+}
+
+func TestValueClasses(t *testing.T) {
+	testcases := map[string][]valueClassTestCase{
+		"timestamp": {
+			{"2021-03-04", true},
+			{"2021-03-04T05:06:07Z", true},
+			{"2021-03-04T05:06:07.89+03:00", true},
+			{"2021-03-04T05:06:07", true},
+			{"2021-13-04", false},
+			{"2021-03-04 05:06:07", false},
+			{"21-03-04", false},
+			{"abcd-ef-gh", false},
+		},
+		"duration": {
+			{"P1Y2M3DT4H5M6.5S", true},
+			{"PT15M", true},
+			{"P3W", true},
+			{"P0,5D", true},
+			{"P", false},
+			{"PT", false},
+			{"P1DT", false},
+			{"P1M1Y", false},
+			{"PT1.5H2M", false},
+			{"P1H", false},
+			{"1D", false},
+		},
+		"uuid": {
+			{"123e4567-e89b-12d3-a456-426614174000", true},
+			{"123E4567-E89B-12D3-A456-426614174000", true},
+			{"123e4567e89b12d3a456426614174000", false},
+			{"123e4567-e89b-12d3-a456-42661417400g", false},
+		},
+		"ip": {
+			{"5.61.233.11", true},
+			{"::1", true},
+			{"2001:db8::ff00:42:8329", true},
+			{"5.61.233.256", false},
+			{"localhost", false},
+		},
+	}
+
+	for _, class := range []gojsonlex.ValueClass{Timestamp, Duration, UUID, IP} {
+		for _, testcase := range testcases[class.Name] {
+			if got := class.Match(testcase.input); got != testcase.output {
+				t.Errorf("testcase '%s': got %t from %s, expected %t",
+					testcase.input, got, class.Name, testcase.output)
+			}
+		}
+	}
+}
+
+func TestJSONLexerSetValueClasses(t *testing.T) {
+	input := `{"2021-03-04": "2021-03-04", "ip": "5.61.233.11", "ttl": "PT15M", "order": "A-1234", "n": 1, "s": ""}`
+	expected := []int{-1, 0, -1, 1, -1, 2, -1, 3, -1, -1, -1, -1}
+
+	l, err := gojsonlex.NewJSONLexer(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("could not create lexer: %v", err)
+	}
+
+	order := Regexp("order", regexp.MustCompile(`^[A-Z]-\d+$`))
+	if err := l.SetValueClasses(Timestamp, IP, Duration, order); err != nil {
+		t.Fatalf("could not set value classes: %v", err)
+	}
+
+	var output []int
+
+	for {
+		token, err := l.TokenFast()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		output = append(output, token.Class())
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("got %v, expected %v", output, expected)
+	}
+}
@@ -0,0 +1,100 @@
+package gojsonlex
+
+import "io"
+
+// Handler receives events from Walk. ctx describes the position of the token (see
+// JSONLexer.CurrentPath), strings of tokens are valid only until the method returns.
+// Returning ErrSkipValue from OnKey, OnObjectStart or OnArrayStart skips the value
+// (the rest of the object or the array) without converting its tokens, no events are
+// generated for it, the closing event included. Any other error stops walking and is
+// returned by Walk as is.
+type Handler interface {
+	OnObjectStart(ctx TokenContext) error
+	OnObjectEnd(ctx TokenContext) error
+	OnArrayStart(ctx TokenContext) error
+	OnArrayEnd(ctx TokenContext) error
+	OnKey(ctx TokenContext, key TokenGeneric) error
+	OnValue(ctx TokenContext, value TokenGeneric) error
+}
+
+// NopHandler is a Handler ignoring all events, embedding it allows to implement only
+// the methods of interest
+type NopHandler struct{}
+
+func (NopHandler) OnObjectStart(ctx TokenContext) error { return nil }
+
+func (NopHandler) OnObjectEnd(ctx TokenContext) error { return nil }
+
+func (NopHandler) OnArrayStart(ctx TokenContext) error { return nil }
+
+func (NopHandler) OnArrayEnd(ctx TokenContext) error { return nil }
+
+func (NopHandler) OnKey(ctx TokenContext, key TokenGeneric) error { return nil }
+
+func (NopHandler) OnValue(ctx TokenContext, value TokenGeneric) error { return nil }
+
+// Walk lexes JSON from r driving h with events, see JSONLexer.Walk
+func Walk(r io.Reader, h Handler) error {
+	l, err := NewJSONLexer(r)
+	if err != nil {
+		return err
+	}
+
+	return l.Walk(h)
+}
+
+// Walk reads all tokens from the input and passes them to the corresponding methods of h
+// (a push, SAX-style alternative to the TokenFast loop), scalars are passed to OnValue.
+// It enables path tracking and delimiters (SetTrackPath, SetSkipDelims), other settings
+// (e.g. SetValidateStructure) apply as usual. It returns nil once the input has been
+// exhausted. MUST be called before parsing started.
+func (l *JSONLexer) Walk(h Handler) error {
+	l.SetSkipDelims(false)
+	l.SetTrackPath(true)
+
+	for {
+		t, err := l.TokenFast()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ctx := l.Context()
+
+		switch {
+		case t.key:
+			err = h.OnKey(ctx, t)
+		case t.t != LexerTokenTypeDelim:
+			err = h.OnValue(ctx, t)
+		case t.delim == '{':
+			err = h.OnObjectStart(ctx)
+		case t.delim == '[':
+			err = h.OnArrayStart(ctx)
+		case t.delim == '}':
+			err = h.OnObjectEnd(ctx)
+		case t.delim == ']':
+			err = h.OnArrayEnd(ctx)
+		}
+
+		if err == ErrSkipValue {
+			switch {
+			case t.key:
+				err = l.SkipValue()
+			case t.delim == '{' || t.delim == '[':
+				err = l.skipContainer()
+			default:
+				err = nil
+			}
+
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package gojsonlex
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// walkRecorder records events as "<event> <path>"
+type walkRecorder struct {
+	NopHandler
+
+	events []string
+	skip   string // path of values to skip
+}
+
+func (r *walkRecorder) record(ctx TokenContext, event string) error {
+	r.events = append(r.events, event+" "+ctx.String())
+
+	if r.skip != "" && ctx.String() == r.skip {
+		return ErrSkipValue
+	}
+
+	return nil
+}
+
+func (r *walkRecorder) OnObjectStart(ctx TokenContext) error { return r.record(ctx, "{") }
+
+func (r *walkRecorder) OnObjectEnd(ctx TokenContext) error { return r.record(ctx, "}") }
+
+func (r *walkRecorder) OnArrayStart(ctx TokenContext) error { return r.record(ctx, "[") }
+
+func (r *walkRecorder) OnArrayEnd(ctx TokenContext) error { return r.record(ctx, "]") }
+
+func (r *walkRecorder) OnKey(ctx TokenContext, key TokenGeneric) error {
+	return r.record(ctx, "key:"+key.String())
+}
+
+func (r *walkRecorder) OnValue(ctx TokenContext, value TokenGeneric) error {
+	return r.record(ctx, "value:"+printToken(value))
+}
+
+type walkTestCase struct {
+	input  string
+	skip   string
+	output []string
+}
+
+func TestWalk(t *testing.T) {
+	testcases := []walkTestCase{
+		{
+			`{"a": [1, {"b": null}], "c": "x"}`,
+			"",
+			[]string{"{ ", "key:a a", "[ a", "value:1 a.0", "{ a.1", "key:b a.1.b", "value:<nil> a.1.b",
+				"} a.1", "] a", "key:c c", "value:x c", "} "},
+		},
+		{
+			`{"a": [1, {"b": null}], "c": "x"}`,
+			"a",
+			[]string{"{ ", "key:a a", "key:c c", "value:x c", "} "},
+		},
+		{
+			`[[1, 2], [3]] 4`,
+			"0",
+			[]string{"[ ", "[ 0", "[ 1", "value:3 1.0", "] 1", "] ", "value:4 "},
+		},
+	}
+
+	for _, testcase := range testcases {
+		r := &walkRecorder{skip: testcase.skip}
+
+		if err := Walk(strings.NewReader(testcase.input), r); err != nil {
+			t.Errorf("testcase '%s': %v", testcase.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(r.events, testcase.output) {
+			t.Errorf("testcase '%s' (skipping '%s'): got %q, expected %q", testcase.input,
+				testcase.skip, r.events, testcase.output)
+		}
+	}
+}
+
+type failingHandler struct {
+	NopHandler
+}
+
+func (failingHandler) OnValue(ctx TokenContext, value TokenGeneric) error {
+	return fmt.Errorf("value at %s", ctx.String())
+}
+
+func TestWalkFails(t *testing.T) {
+	if err := Walk(strings.NewReader(`{"a": {"b": 1}}`), failingHandler{}); err == nil || err.Error() != "value at a.b" {
+		t.Errorf("got %v, expected the error of the handler", err)
+	}
+
+	err := Walk(strings.NewReader(`{"a": [1, 2`), &walkRecorder{skip: "a"})
+	if err == nil || errors.Is(err, ErrSkipValue) {
+		t.Errorf("got %v, expected an error", err)
+	}
+}